@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"digital-logbook/database"
+	"digital-logbook/models"
+	"errors"
+	"log"
+	"os"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials stops the chain: the user exists but the password is wrong
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUnknownUser lets the next authenticator in the chain try
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrUnavailable lets the next authenticator try when a backend cannot be reached
+	ErrUnavailable = errors.New("authentication backend unavailable")
+
+	ErrNoMappedRole    = errors.New("no role is mapped for this account's groups")
+	ErrNoMappedSite    = errors.New("no location is mapped for this account's groups")
+	ErrUsernameInUse   = errors.New("username is already used by another account")
+	ErrAccountMissing  = errors.New("account has been removed")
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrNotProvisioned  = errors.New("account has not signed in yet")
+)
+
+// Authenticator verifies a username and password against one credential store
+type Authenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+	// Verify checks the password like Authenticate but never creates or
+	// updates an account, for confirming someone else's approval
+	Verify(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// Chain tries each authenticator in order until one accepts or rejects the user
+type Chain []Authenticator
+
+// Authenticate returns the first user accepted by the chain. Authenticators
+// that do not know the user or cannot be reached defer to the next one.
+func (chain Chain) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	return chain.first(func(authenticator Authenticator) (*models.User, error) {
+		return authenticator.Authenticate(ctx, username, password)
+	})
+}
+
+// Verify returns the first user whose password the chain accepts, without
+// provisioning accounts that have never signed in
+func (chain Chain) Verify(ctx context.Context, username, password string) (*models.User, error) {
+	return chain.first(func(authenticator Authenticator) (*models.User, error) {
+		return authenticator.Verify(ctx, username, password)
+	})
+}
+
+func (chain Chain) first(try func(Authenticator) (*models.User, error)) (*models.User, error) {
+	for _, authenticator := range chain {
+		user, err := try(authenticator)
+		if err == nil {
+			return user, nil
+		}
+		if errors.Is(err, ErrUnknownUser) || errors.Is(err, ErrUnavailable) {
+			continue
+		}
+		return nil, err
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// LocalFallback controls which local accounts may sign in when a directory is configured
+type LocalFallback string
+
+const (
+	LocalFallbackAll   LocalFallback = "all"
+	LocalFallbackAdmin LocalFallback = "admin" // Break-glass admin accounts only
+	LocalFallbackNone  LocalFallback = "none"
+)
+
+// LocalAuthenticator checks bcrypt password hashes stored on local accounts
+type LocalAuthenticator struct {
+	AdminOnly bool
+}
+
+func (a *LocalAuthenticator) Name() string {
+	return "local"
+}
+
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := database.DB.GetUserByUsername(username)
+	if err != nil || !user.IsLocal() {
+		return nil, ErrUnknownUser
+	}
+	if a.AdminOnly && user.Role != models.RoleAdmin {
+		return nil, ErrUnknownUser
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return user, nil
+}
+
+// Verify is Authenticate, which only reads local accounts
+func (a *LocalAuthenticator) Verify(ctx context.Context, username, password string) (*models.User, error) {
+	return a.Authenticate(ctx, username, password)
+}
+
+// localAccount returns true if the username belongs to a local account
+func localAccount(username string) bool {
+	user, err := database.DB.GetUserByUsername(username)
+	return err == nil && user.IsLocal()
+}
+
+// NewChainFromEnv builds the password authenticator chain: the directory
+// first when LDAP is configured, then local accounts per AUTH_LOCAL_FALLBACK
+func NewChainFromEnv() Chain {
+	var chain Chain
+
+	fallback := LocalFallback(os.Getenv("AUTH_LOCAL_FALLBACK"))
+	if cfg, ok := LoadLDAPConfigFromEnv(); ok {
+		if cfg.Cleartext() {
+			log.Printf("Warning: LDAP_URL is ldap:// without LDAP_START_TLS; passwords are sent unencrypted")
+		}
+		chain = append(chain, NewLDAPAuthenticator(cfg))
+		if fallback == "" {
+			fallback = LocalFallbackAdmin
+		}
+	} else if fallback == "" || fallback == LocalFallbackNone {
+		// Without a directory local accounts are the only way in
+		fallback = LocalFallbackAll
+	}
+
+	switch fallback {
+	case LocalFallbackAll:
+		chain = append(chain, &LocalAuthenticator{})
+	case LocalFallbackAdmin:
+		chain = append(chain, &LocalAuthenticator{AdminOnly: true})
+	}
+	return chain
+}
+
+// ProvisionUser creates or refreshes the local record of an externally
+// authenticated user. Role and location follow the identity provider's groups;
+// accounts created over SCIM keep their assigned role when no group is mapped,
+// and any other account without a mapped group is refused.
+func ProvisionUser(provider models.AuthProvider, identity *Identity, mapping GroupMapping) (*models.User, error) {
+	role, locationID, mapped, err := resolveGroups(identity, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := database.DB.GetUserByExternalID(provider, identity.Subject)
+	if err != nil {
+		existing, err := database.DB.GetUserByUsername(identity.Username)
+		if err == nil && existing.AuthProvider == provider && existing.ExternalID == "" {
+			// First login of a pre-provisioned account links it to the subject
+			user = existing
+			user.ExternalID = identity.Subject
+		} else if err == nil {
+			return nil, ErrUsernameInUse
+		}
+	}
+
+	if user == nil {
+		if !mapped {
+			return nil, ErrNoMappedRole
+		}
+		user = &models.User{
+			Username:     identity.Username,
+			Role:         role,
+			FullName:     identity.FullName,
+			Email:        identity.Email,
+			LocationID:   locationID,
+			AuthProvider: provider,
+			ExternalID:   identity.Subject,
+		}
+		if err := database.DB.CreateUser(user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	if !mapped && !user.SCIMCreated {
+		return nil, ErrNoMappedRole
+	}
+
+	if mapped {
+		user.Role = role
+		user.LocationID = locationID
+	}
+	user.FullName = identity.FullName
+	if identity.Email != "" {
+		user.Email = identity.Email
+	}
+	if err := database.DB.UpdateUser(user); err != nil {
+		return nil, ErrAccountMissing
+	}
+	return user, nil
+}
+
+// ProvisionedUser returns the existing record of an externally authenticated
+// user with the role and location its groups map to now, without saving it
+func ProvisionedUser(provider models.AuthProvider, identity *Identity, mapping GroupMapping) (*models.User, error) {
+	stored, err := database.DB.GetUserByExternalID(provider, identity.Subject)
+	if err != nil {
+		return nil, ErrNotProvisioned
+	}
+	if stored.Disabled {
+		return nil, ErrAccountDisabled
+	}
+
+	role, locationID, mapped, err := resolveGroups(identity, mapping)
+	if err != nil {
+		return nil, err
+	}
+	if !mapped && !stored.SCIMCreated {
+		return nil, ErrNoMappedRole
+	}
+	user := *stored
+	if mapped {
+		user.Role = role
+		user.LocationID = locationID
+	}
+	return &user, nil
+}
+
+// resolveGroups maps the identity's groups to a role and location. mapped is
+// false when no group grants access.
+func resolveGroups(identity *Identity, mapping GroupMapping) (role models.UserRole, locationID *uint, mapped bool, err error) {
+	role, locationCode, mapped := mapping.Resolve(identity.Groups)
+	if mapped && locationCode != "" {
+		location, err := database.DB.GetLocationByCode(locationCode)
+		if err != nil {
+			return "", nil, false, ErrNoMappedSite
+		}
+		locationID = &location.ID
+	} else if mapped && role != models.RoleAdmin {
+		// Users without a location see every site, which only admins may do
+		return "", nil, false, ErrNoMappedSite
+	}
+	return role, locationID, mapped, nil
+}
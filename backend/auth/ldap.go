@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"digital-logbook/models"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// LDAPConfig holds the settings for checking passwords against a directory
+type LDAPConfig struct {
+	URL                string // ldap://host:389 or ldaps://host:636
+	StartTLS           bool   // Upgrade ldap:// connections to TLS
+	BindDN             string // Service account used to look users up
+	BindPassword       string
+	BaseDN             string
+	UserAttribute      string // uid, or sAMAccountName for Active Directory
+	UserObjectClass    string // Optional extra filter, e.g. person
+	GroupAttribute     string // memberOf
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+	Mapping            GroupMapping
+}
+
+// LoadLDAPConfigFromEnv reads the directory settings from the environment.
+// ok is false when LDAP authentication is not configured.
+func LoadLDAPConfigFromEnv() (cfg LDAPConfig, ok bool) {
+	cfg = LDAPConfig{
+		URL:                os.Getenv("LDAP_URL"),
+		StartTLS:           os.Getenv("LDAP_START_TLS") == "true",
+		BindDN:             os.Getenv("LDAP_BIND_DN"),
+		BindPassword:       os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:             os.Getenv("LDAP_BASE_DN"),
+		UserAttribute:      os.Getenv("LDAP_USER_ATTR"),
+		UserObjectClass:    os.Getenv("LDAP_USER_OBJECT_CLASS"),
+		GroupAttribute:     os.Getenv("LDAP_GROUP_ATTR"),
+		InsecureSkipVerify: os.Getenv("LDAP_INSECURE_SKIP_VERIFY") == "true",
+		Timeout:            5 * time.Second,
+		Mapping: ParseGroupMapping(
+			os.Getenv("LDAP_ROLE_MAP"),
+			os.Getenv("LDAP_LOCATION_MAP"),
+			os.Getenv("LDAP_DEFAULT_ROLE"),
+		),
+	}
+	if cfg.UserAttribute == "" {
+		cfg.UserAttribute = "uid"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	return cfg, cfg.URL != "" && cfg.BaseDN != ""
+}
+
+// Cleartext returns true if passwords would cross the network unencrypted
+func (cfg LDAPConfig) Cleartext() bool {
+	return strings.HasPrefix(strings.ToLower(cfg.URL), "ldap://") && !cfg.StartTLS
+}
+
+// LDAPAuthenticator verifies passwords with a bind as the user's directory entry
+type LDAPAuthenticator struct {
+	Config LDAPConfig
+}
+
+func NewLDAPAuthenticator(cfg LDAPConfig) *LDAPAuthenticator {
+	return &LDAPAuthenticator{Config: cfg}
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which always succeeds
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	// Local accounts, such as the break-glass admin, win over a directory
+	// entry with the same username
+	if localAccount(username) {
+		return nil, ErrUnknownUser
+	}
+
+	identity, err := a.verify(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return ProvisionUser(models.AuthProviderLDAP, identity, a.Config.Mapping)
+}
+
+// Verify binds as the user like Authenticate, but only accepts accounts that
+// have already been provisioned and leaves them as they are
+func (a *LDAPAuthenticator) Verify(ctx context.Context, username, password string) (*models.User, error) {
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	if localAccount(username) {
+		return nil, ErrUnknownUser
+	}
+
+	identity, err := a.verify(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return ProvisionedUser(models.AuthProviderLDAP, identity, a.Config.Mapping)
+}
+
+// verify finds the user's entry with the service account, then binds as the user
+func (a *LDAPAuthenticator) verify(ctx context.Context, username, password string) (*Identity, error) {
+	timeout := a.Config.Timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
+	conn, err := dialLDAP(a.Config.URL, timeout, &tls.Config{InsecureSkipVerify: a.Config.InsecureSkipVerify}, a.Config.StartTLS)
+	if err != nil {
+		log.Printf("LDAP connection failed: %v", err)
+		return nil, ErrUnavailable
+	}
+	defer conn.close()
+
+	if a.Config.BindDN != "" {
+		if err := conn.bind(a.Config.BindDN, a.Config.BindPassword); err != nil {
+			log.Printf("LDAP service bind failed: %v", err)
+			return nil, ErrUnavailable
+		}
+	}
+
+	attributes := []string{"cn", "displayName", "mail", "entryUUID", "objectGUID", a.Config.GroupAttribute}
+	filter := equalityFilter(a.Config.UserAttribute, username, a.Config.UserObjectClass)
+	entries, err := conn.search(a.Config.BaseDN, filter, attributes, 2)
+	if err != nil {
+		// Size limit exceeded means the username is ambiguous
+		var resultErr *ldapResultError
+		if errors.As(err, &resultErr) && resultErr.Code == ldapResultSizeLimit {
+			return nil, ErrInvalidCredentials
+		}
+		log.Printf("LDAP search failed: %v", err)
+		return nil, ErrUnavailable
+	}
+	if len(entries) == 0 {
+		return nil, ErrUnknownUser
+	}
+	if len(entries) > 1 {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry := entries[0]
+	if err := conn.bind(entry.DN, password); err != nil {
+		if errors.Is(err, errLDAPInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		log.Printf("LDAP user bind failed: %v", err)
+		return nil, ErrUnavailable
+	}
+
+	identity := &Identity{
+		Subject:  entry.DN,
+		Username: username,
+		FullName: entry.first("displayName"),
+		Email:    entry.first("mail"),
+	}
+	if uuid := entry.first("entryUUID"); uuid != "" {
+		identity.Subject = uuid
+	} else if guid := entry.first("objectGUID"); guid != "" {
+		identity.Subject = hex.EncodeToString([]byte(guid))
+	}
+	if identity.FullName == "" {
+		identity.FullName = entry.first("cn")
+	}
+	if identity.FullName == "" {
+		identity.FullName = username
+	}
+
+	// Groups can be mapped by full DN or by their common name
+	for _, group := range entry.values(a.Config.GroupAttribute) {
+		identity.Groups = append(identity.Groups, group)
+		if cn := commonName(group); cn != "" {
+			identity.Groups = append(identity.Groups, cn)
+		}
+	}
+	return identity, nil
+}
+
+// first returns the first value of an attribute, matching the name case-insensitively
+func (e ldapEntry) first(name string) string {
+	if values := e.values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (e ldapEntry) values(name string) []string {
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// commonName extracts the value of a leading CN= component from a DN
+func commonName(dn string) string {
+	first := strings.SplitN(dn, ",", 2)[0]
+	parts := strings.SplitN(first, "=", 2)
+	if len(parts) != 2 || !strings.EqualFold(strings.TrimSpace(parts[0]), "cn") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,563 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"digital-logbook/database"
+	"digital-logbook/models"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ldapStubEntry is a directory entry served by ldapStub
+type ldapStubEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// ldapStub is an in-process LDAP server answering simple binds and
+// equality searches, enough for LDAPAuthenticator
+type ldapStub struct {
+	listener net.Listener
+	entries  []ldapStubEntry
+	tls      *tls.Config // Accepts StartTLS when set
+
+	mu    sync.Mutex
+	binds []string // DNs bound as, in order
+}
+
+func newLDAPStub(t *testing.T, entries ...ldapStubEntry) *ldapStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &ldapStub{listener: listener, entries: entries}
+	go stub.serve()
+	t.Cleanup(func() { listener.Close() })
+	return stub
+}
+
+func (s *ldapStub) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStub) boundDNs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *ldapStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// readLDAPMessage reads one LDAPMessage and returns its ID and operation
+func readLDAPMessage(r *bufio.Reader) (int, berElement, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, berElement{}, err
+	}
+	lengthBytes := []byte{header[1]}
+	if header[1] >= 0x80 {
+		extra := make([]byte, int(header[1]&0x7f))
+		if _, err := io.ReadFull(r, extra); err != nil {
+			return 0, berElement{}, err
+		}
+		lengthBytes = append(lengthBytes, extra...)
+	}
+	length, _, err := berDecodeLength(lengthBytes)
+	if err != nil {
+		return 0, berElement{}, err
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, berElement{}, err
+	}
+	parts, err := berParse(body)
+	if err != nil || len(parts) < 2 {
+		return 0, berElement{}, errors.New("malformed message")
+	}
+	return berIntValue(parts[0].Content), parts[1], nil
+}
+
+func ldapStubResult(tag byte, code int) []byte {
+	return berConstructed(tag,
+		berInt(berEnumerated, code),
+		berString(berOctetString, ""),
+		berString(berOctetString, ""),
+	)
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (s *ldapStub) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(id int, op []byte) {
+		conn.Write(berConstructed(berSequence, berInt(berInteger, id), op))
+	}
+
+	for {
+		id, op, err := readLDAPMessage(r)
+		if err != nil {
+			return
+		}
+		switch op.Tag {
+		case ldapBindRequest:
+			dn, password := string(op.Children[1].Content), string(op.Children[2].Content)
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+			code := ldapResultInvalidCrd
+			for _, entry := range s.entries {
+				if entry.DN == dn && entry.Password == password {
+					code = ldapResultSuccess
+				}
+			}
+			reply(id, ldapStubResult(ldapBindResponse, code))
+		case ldapSearchRequest:
+			sizeLimit := berIntValue(op.Children[3].Content)
+			matches := s.search(op.Children[6])
+			code := ldapResultSuccess
+			if sizeLimit > 0 && len(matches) > sizeLimit {
+				matches, code = matches[:sizeLimit], ldapResultSizeLimit
+			}
+			for _, entry := range matches {
+				var attrs [][]byte
+				for name, values := range entry.Attributes {
+					var vals [][]byte
+					for _, v := range values {
+						vals = append(vals, berString(berOctetString, v))
+					}
+					attrs = append(attrs, berConstructed(berSequence,
+						berString(berOctetString, name),
+						berConstructed(berSet, vals...),
+					))
+				}
+				reply(id, berConstructed(ldapSearchEntry,
+					berString(berOctetString, entry.DN),
+					berConstructed(berSequence, attrs...),
+				))
+			}
+			reply(id, ldapStubResult(ldapSearchDone, code))
+		case ldapExtendedRequest:
+			if s.tls == nil || string(op.Children[0].Content) != ldapStartTLSOID {
+				reply(id, ldapStubResult(ldapExtendedResponse, 2)) // protocolError
+				continue
+			}
+			reply(id, ldapStubResult(ldapExtendedResponse, ldapResultSuccess))
+			conn = tls.Server(conn, s.tls)
+			r = bufio.NewReader(conn)
+		case ldapUnbindRequest:
+			return
+		}
+	}
+}
+
+// search returns the entries matching an equality filter or an and of them
+func (s *ldapStub) search(filter berElement) []ldapStubEntry {
+	conditions := []berElement{filter}
+	if filter.Tag == ldapFilterAnd {
+		conditions = filter.Children
+	}
+
+	var matches []ldapStubEntry
+	for _, entry := range s.entries {
+		matched := true
+		for _, cond := range conditions {
+			attr, value := string(cond.Children[0].Content), string(cond.Children[1].Content)
+			if !hasValue(ldapEntry{Attributes: entry.Attributes}.values(attr), value) {
+				matched = false
+			}
+		}
+		if matched {
+			matches = append(matches, entry)
+		}
+	}
+	return matches
+}
+
+func hasValue(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	testServiceDN = "cn=logbook,ou=services,dc=example,dc=com"
+	testGuardsDN  = "cn=Guards,ou=groups,dc=example,dc=com"
+	testAdminsDN  = "cn=Security Admins,ou=groups,dc=example,dc=com"
+	testNightDN   = "cn=Night Shift,ou=groups,dc=example,dc=com"
+)
+
+func testDirectory(t *testing.T) *ldapStub {
+	person := func(uid, password string, groups ...string) ldapStubEntry {
+		return ldapStubEntry{
+			DN:       "uid=" + uid + ",ou=people,dc=example,dc=com",
+			Password: password,
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {uid},
+				"cn":          {strings.ToUpper(uid[:1]) + uid[1:] + " Example"},
+				"mail":        {uid + "@example.com"},
+				"entryUUID":   {"uuid-" + uid},
+				"memberOf":    groups,
+			},
+		}
+	}
+	return newLDAPStub(t,
+		ldapStubEntry{DN: testServiceDN, Password: "service-secret"},
+		person("guard", "guard-pass", testGuardsDN),
+		person("chief", "chief-pass", testGuardsDN, testAdminsDN),
+		person("byDN", "bydn-pass", testNightDN),
+		person("nogroup", "nogroup-pass", "cn=Canteen,ou=groups,dc=example,dc=com"),
+		person("relief", "relief-pass", testGuardsDN, testAdminsDN),
+	)
+}
+
+func testLDAPAuthenticator(stub *ldapStub) *LDAPAuthenticator {
+	return NewLDAPAuthenticator(LDAPConfig{
+		URL:             stub.URL(),
+		BindDN:          testServiceDN,
+		BindPassword:    "service-secret",
+		BaseDN:          "dc=example,dc=com",
+		UserAttribute:   "uid",
+		UserObjectClass: "person",
+		GroupAttribute:  "memberOf",
+		Timeout:         2 * time.Second,
+		Mapping:         testGroupMapping(),
+	})
+}
+
+// testGroupMapping maps groups by common name, plus one by its full DN,
+// which LDAP_ROLE_MAP cannot express as DNs contain commas
+func testGroupMapping() GroupMapping {
+	mapping := ParseGroupMapping("Guards=data_entry,Security Admins=admin", "Guards=NBO-HQ,Night Shift=MBA-PORT", "")
+	mapping.Roles[strings.ToLower(testNightDN)] = models.RoleDashboardVisitor
+	return mapping
+}
+
+func TestLDAPAuthenticateSearchThenBind(t *testing.T) {
+	stub := testDirectory(t)
+	user, err := testLDAPAuthenticator(stub).Authenticate(context.Background(), "guard", "guard-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if got, want := stub.boundDNs(), []string{testServiceDN, "uid=guard,ou=people,dc=example,dc=com"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("binds = %v, want %v", got, want)
+	}
+	if user.Username != "guard" || user.AuthProvider != models.AuthProviderLDAP || user.ExternalID != "uuid-guard" {
+		t.Errorf("user = %s/%s/%s, want guard/ldap/uuid-guard", user.Username, user.AuthProvider, user.ExternalID)
+	}
+	if user.FullName != "Guard Example" || user.Email != "guard@example.com" {
+		t.Errorf("profile = %q %q", user.FullName, user.Email)
+	}
+}
+
+func TestLDAPAuthenticateWrongPassword(t *testing.T) {
+	stub := testDirectory(t)
+	_, err := testLDAPAuthenticator(stub).Authenticate(context.Background(), "guard", "wrong")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLDAPAuthenticateEmptyPassword(t *testing.T) {
+	stub := testDirectory(t)
+	_, err := testLDAPAuthenticator(stub).Authenticate(context.Background(), "guard", "")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+	// The server must never see an unauthenticated bind
+	if binds := stub.boundDNs(); len(binds) != 0 {
+		t.Errorf("binds = %v, want none", binds)
+	}
+}
+
+func TestLDAPAuthenticateMissingUser(t *testing.T) {
+	stub := testDirectory(t)
+	_, err := testLDAPAuthenticator(stub).Authenticate(context.Background(), "nobody", "whatever")
+	if !errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("err = %v, want ErrUnknownUser", err)
+	}
+	if binds := stub.boundDNs(); len(binds) != 1 {
+		t.Errorf("binds = %v, want the service bind only", binds)
+	}
+}
+
+func TestLDAPAuthenticateMapsGroupsToRoles(t *testing.T) {
+	stub := testDirectory(t)
+	authenticator := testLDAPAuthenticator(stub)
+
+	tests := []struct {
+		username, password string
+		role               models.UserRole
+		location           string
+		err                error
+	}{
+		{"guard", "guard-pass", models.RoleDataEntry, "NBO-HQ", nil},
+		// The most privileged mapped role wins; the location comes from the group that maps one
+		{"chief", "chief-pass", models.RoleAdmin, "NBO-HQ", nil},
+		// Groups map by full DN as well as by common name
+		{"byDN", "bydn-pass", models.RoleDashboardVisitor, "MBA-PORT", nil},
+		{"nogroup", "nogroup-pass", "", "", ErrNoMappedRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			user, err := authenticator.Authenticate(context.Background(), tt.username, tt.password)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Role != tt.role {
+				t.Errorf("role = %s, want %s", user.Role, tt.role)
+			}
+			location, err := database.DB.GetLocationByCode(tt.location)
+			if err != nil {
+				t.Fatalf("location %s: %v", tt.location, err)
+			}
+			if user.LocationID == nil || *user.LocationID != location.ID {
+				t.Errorf("location_id = %v, want %d (%s)", user.LocationID, location.ID, tt.location)
+			}
+		})
+	}
+}
+
+func TestLDAPVerifyDoesNotProvision(t *testing.T) {
+	stub := testDirectory(t)
+	authenticator := testLDAPAuthenticator(stub)
+
+	// A correct password is not enough for an account that never signed in
+	if _, err := authenticator.Verify(context.Background(), "relief", "relief-pass"); !errors.Is(err, ErrNotProvisioned) {
+		t.Fatalf("err = %v, want ErrNotProvisioned", err)
+	}
+	if _, err := database.DB.GetUserByUsername("relief"); err == nil {
+		t.Fatal("Verify created an account")
+	}
+
+	if _, err := authenticator.Authenticate(context.Background(), "relief", "relief-pass"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if _, err := authenticator.Verify(context.Background(), "relief", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("err = %v, want ErrInvalidCredentials", err)
+	}
+
+	// The role follows the directory's groups now, but the stored account is left alone
+	authenticator.Config.Mapping = ParseGroupMapping("Guards=data_entry", "Guards=NBO-HQ", "")
+	user, err := authenticator.Verify(context.Background(), "relief", "relief-pass")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if user.Role != models.RoleDataEntry {
+		t.Errorf("role = %s, want %s", user.Role, models.RoleDataEntry)
+	}
+	stored, err := database.DB.GetUserByUsername("relief")
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if stored.Role != models.RoleAdmin {
+		t.Errorf("stored role = %s, want %s", stored.Role, models.RoleAdmin)
+	}
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	stub := testDirectory(t)
+	stub.tls = &tls.Config{Certificates: []tls.Certificate{testCertificate(t)}}
+	authenticator := testLDAPAuthenticator(stub)
+	authenticator.Config.StartTLS = true
+	authenticator.Config.InsecureSkipVerify = true
+
+	if _, err := authenticator.Authenticate(context.Background(), "guard", "guard-pass"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if binds := stub.boundDNs(); len(binds) != 2 {
+		t.Errorf("binds = %v, want the service and user binds", binds)
+	}
+}
+
+func TestLDAPStartTLSRefused(t *testing.T) {
+	stub := testDirectory(t)
+	authenticator := testLDAPAuthenticator(stub)
+	authenticator.Config.StartTLS = true
+
+	// No password is sent in the clear when the upgrade fails
+	if _, err := authenticator.Authenticate(context.Background(), "guard", "guard-pass"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("err = %v, want ErrUnavailable", err)
+	}
+	if binds := stub.boundDNs(); len(binds) != 0 {
+		t.Errorf("binds = %v, want none", binds)
+	}
+}
+
+func TestLDAPConfigCleartext(t *testing.T) {
+	tests := []struct {
+		cfg  LDAPConfig
+		want bool
+	}{
+		{LDAPConfig{URL: "ldap://dc.example.com"}, true},
+		{LDAPConfig{URL: "LDAP://dc.example.com"}, true},
+		{LDAPConfig{URL: "ldap://dc.example.com", StartTLS: true}, false},
+		{LDAPConfig{URL: "ldaps://dc.example.com"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.Cleartext(); got != tt.want {
+			t.Errorf("%+v Cleartext() = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestLDAPReceiveRejectsOversizedMessage(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	// A message claiming 256 MiB must fail before the body is read
+	go server.Write([]byte{berSequence, 0x84, 0x10, 0x00, 0x00, 0x00})
+
+	conn := &ldapConn{conn: client, r: bufio.NewReader(client)}
+	defer client.Close()
+	if _, err := conn.receive(1); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("err = %v, want the size limit", err)
+	}
+}
+
+func TestLDAPLocalAdminWinsUsernameCollision(t *testing.T) {
+	stub := newLDAPStub(t,
+		ldapStubEntry{DN: testServiceDN, Password: "service-secret"},
+		ldapStubEntry{
+			DN:       "uid=admin,ou=people,dc=example,dc=com",
+			Password: "directory-pass",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"admin"},
+				"memberOf":    {testAdminsDN},
+			},
+		},
+	)
+	chain := Chain{testLDAPAuthenticator(stub), &LocalAuthenticator{AdminOnly: true}}
+
+	// The seeded break-glass admin signs in with its local password
+	user, err := chain.Authenticate(context.Background(), "admin", "admin123")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !user.IsLocal() {
+		t.Errorf("auth_provider = %s, want the local account", user.AuthProvider)
+	}
+	if _, err := chain.Verify(context.Background(), "admin", "admin123"); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	// The directory password does not sign in as the local admin
+	if _, err := chain.Authenticate(context.Background(), "admin", "directory-pass"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("err = %v, want ErrInvalidCredentials", err)
+	}
+	if binds := stub.boundDNs(); len(binds) != 0 {
+		t.Errorf("binds = %v, want the directory not consulted", binds)
+	}
+}
+
+func TestLDAPUnmappedAccountIsRefusedUnlessCreatedOverSCIM(t *testing.T) {
+	entry := func(uid string) ldapStubEntry {
+		return ldapStubEntry{
+			DN:       "uid=" + uid + ",ou=people,dc=example,dc=com",
+			Password: uid + "-pass",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {uid},
+				"entryUUID":   {"uuid-" + uid},
+				"memberOf":    {testGuardsDN},
+			},
+		}
+	}
+	stub := newLDAPStub(t,
+		ldapStubEntry{DN: testServiceDN, Password: "service-secret"},
+		entry("leaver"),
+		entry("contractor"),
+	)
+	authenticator := testLDAPAuthenticator(stub)
+
+	// An account created by an earlier login loses access with its groups
+	if _, err := authenticator.Authenticate(context.Background(), "leaver", "leaver-pass"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	// An account created over SCIM keeps the role it was given
+	location, err := database.DB.GetLocationByCode("NBO-HQ")
+	if err != nil {
+		t.Fatalf("location: %v", err)
+	}
+	if err := database.DB.CreateUser(&models.User{
+		Username:     "contractor",
+		Role:         models.RoleDashboardCargo,
+		LocationID:   &location.ID,
+		AuthProvider: models.AuthProviderLDAP,
+		SCIMCreated:  true,
+	}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	authenticator.Config.Mapping = ParseGroupMapping("Canteen=data_entry", "", "")
+	for _, verify := range []bool{false, true} {
+		login := authenticator.Authenticate
+		if verify {
+			login = authenticator.Verify
+		}
+		if _, err := login(context.Background(), "leaver", "leaver-pass"); !errors.Is(err, ErrNoMappedRole) {
+			t.Errorf("verify=%v: leaver err = %v, want ErrNoMappedRole", verify, err)
+		}
+		user, err := login(context.Background(), "contractor", "contractor-pass")
+		if err != nil {
+			t.Fatalf("verify=%v: contractor: %v", verify, err)
+		}
+		if user.Role != models.RoleDashboardCargo {
+			t.Errorf("verify=%v: role = %s, want %s", verify, user.Role, models.RoleDashboardCargo)
+		}
+	}
+}
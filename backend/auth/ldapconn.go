@@ -0,0 +1,420 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Minimal LDAPv3 client (RFC 4511) supporting simple bind and search, which
+// is all password verification needs.
+
+// BER tags used by the LDAP operations below
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+	berSet         = 0x31
+
+	ldapBindRequest      = 0x60
+	ldapBindResponse     = 0x61
+	ldapUnbindRequest    = 0x42
+	ldapSearchRequest    = 0x63
+	ldapSearchEntry      = 0x64
+	ldapSearchDone       = 0x65
+	ldapSearchReference  = 0x73
+	ldapExtendedRequest  = 0x77
+	ldapExtendedResponse = 0x78
+	ldapExtendedName     = 0x80
+	ldapSimpleAuth       = 0x80
+	ldapFilterAnd        = 0xa0
+	ldapFilterEquality   = 0xa3
+	ldapScopeSubtree     = 2
+	ldapResultSuccess    = 0
+	ldapResultSizeLimit  = 4
+	ldapResultInvalidCrd = 49
+
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+
+	// Upper bound on a single response; a user entry with its groups is far smaller
+	ldapMaxMessageSize = 1 << 20
+)
+
+var errLDAPInvalidCredentials = errors.New("ldap: invalid credentials")
+
+// ldapResultError is returned when the server answers with a non-success result code
+type ldapResultError struct {
+	Code    int
+	Message string
+}
+
+func (e *ldapResultError) Error() string {
+	return fmt.Sprintf("ldap: result code %d: %s", e.Code, e.Message)
+}
+
+// berElement is a decoded BER tag-length-value
+type berElement struct {
+	Tag      byte
+	Content  []byte
+	Children []berElement
+}
+
+func berEncodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for n > 0 {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func berTLV(tag byte, content []byte) []byte {
+	out := append([]byte{tag}, berEncodeLength(len(content))...)
+	return append(out, content...)
+}
+
+func berConstructed(tag byte, children ...[]byte) []byte {
+	var content []byte
+	for _, child := range children {
+		content = append(content, child...)
+	}
+	return berTLV(tag, content)
+}
+
+func berInt(tag byte, v int) []byte {
+	// Minimal two's complement encoding; LDAP only needs small non-negative values
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return berTLV(tag, b)
+}
+
+func berString(tag byte, s string) []byte {
+	return berTLV(tag, []byte(s))
+}
+
+func berBool(v bool) []byte {
+	if v {
+		return berTLV(berBoolean, []byte{0xff})
+	}
+	return berTLV(berBoolean, []byte{0x00})
+}
+
+// berParse decodes all elements in data, descending into constructed ones
+func berParse(data []byte) ([]berElement, error) {
+	var elements []berElement
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return nil, errors.New("ber: truncated element")
+		}
+		tag := data[0]
+		length, header, err := berDecodeLength(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + header
+		if start+length > len(data) {
+			return nil, errors.New("ber: element exceeds buffer")
+		}
+
+		element := berElement{Tag: tag, Content: data[start : start+length]}
+		if tag&0x20 != 0 {
+			if element.Children, err = berParse(element.Content); err != nil {
+				return nil, err
+			}
+		}
+		elements = append(elements, element)
+		data = data[start+length:]
+	}
+	return elements, nil
+}
+
+// berDecodeLength returns the length value and how many bytes encoded it
+func berDecodeLength(data []byte) (int, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("ber: missing length")
+	}
+	if data[0] < 0x80 {
+		return int(data[0]), 1, nil
+	}
+	count := int(data[0] & 0x7f)
+	if count == 0 || count > 4 || len(data) < 1+count {
+		return 0, 0, errors.New("ber: unsupported length encoding")
+	}
+	length := 0
+	for _, b := range data[1 : 1+count] {
+		length = length<<8 | int(b)
+	}
+	return length, 1 + count, nil
+}
+
+func berIntValue(content []byte) int {
+	v := 0
+	for _, b := range content {
+		v = v<<8 | int(b)
+	}
+	return v
+}
+
+type ldapEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+type ldapConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	msgID int
+}
+
+// dialLDAP connects to an ldap:// or ldaps:// URL. With startTLS an ldap://
+// connection is upgraded to TLS before anything else is sent.
+func dialLDAP(rawURL string, timeout time.Duration, tlsConfig *tls.Config, startTLS bool) (*ldapConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	dialer := &net.Dialer{Timeout: timeout}
+	cfg := tlsConfig.Clone()
+	if cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "ldaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, cfg)
+	default:
+		return nil, fmt.Errorf("ldap: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	c := &ldapConn{conn: conn, r: bufio.NewReader(conn)}
+	if startTLS && u.Scheme == "ldap" {
+		if err := c.startTLS(cfg); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS asks the server to switch the connection to TLS (RFC 4511 4.14)
+func (c *ldapConn) startTLS(cfg *tls.Config) error {
+	id, err := c.send(berConstructed(ldapExtendedRequest,
+		berString(ldapExtendedName, ldapStartTLSOID),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.Tag != ldapExtendedResponse {
+		return errors.New("ldap: unexpected StartTLS response")
+	}
+	if err := ldapResult(op); err != nil {
+		return fmt.Errorf("ldap: StartTLS refused: %w", err)
+	}
+
+	conn := tls.Client(c.conn, cfg)
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	return nil
+}
+
+func (c *ldapConn) send(op []byte) (int, error) {
+	c.msgID++
+	msg := berConstructed(berSequence, berInt(berInteger, c.msgID), op)
+	_, err := c.conn.Write(msg)
+	return c.msgID, err
+}
+
+// receive reads the next LDAP message and returns its protocol operation
+func (c *ldapConn) receive(msgID int) (berElement, error) {
+	tag, err := c.r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	if tag != berSequence {
+		return berElement{}, errors.New("ldap: unexpected message tag")
+	}
+
+	first, err := c.r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	lengthBytes := []byte{first}
+	if first >= 0x80 {
+		extra := make([]byte, int(first&0x7f))
+		if _, err := io.ReadFull(c.r, extra); err != nil {
+			return berElement{}, err
+		}
+		lengthBytes = append(lengthBytes, extra...)
+	}
+	length, _, err := berDecodeLength(lengthBytes)
+	if err != nil {
+		return berElement{}, err
+	}
+	if length > ldapMaxMessageSize {
+		return berElement{}, fmt.Errorf("ldap: %d byte message exceeds the %d byte limit", length, ldapMaxMessageSize)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return berElement{}, err
+	}
+	parts, err := berParse(body)
+	if err != nil {
+		return berElement{}, err
+	}
+	if len(parts) < 2 || parts[0].Tag != berInteger || berIntValue(parts[0].Content) != msgID {
+		return berElement{}, errors.New("ldap: unexpected message id")
+	}
+	return parts[1], nil
+}
+
+// ldapResult converts an LDAPResult operation into an error
+func ldapResult(op berElement) error {
+	if len(op.Children) < 3 {
+		return errors.New("ldap: malformed result")
+	}
+	code := berIntValue(op.Children[0].Content)
+	switch code {
+	case ldapResultSuccess:
+		return nil
+	case ldapResultInvalidCrd:
+		return errLDAPInvalidCredentials
+	}
+	return &ldapResultError{Code: code, Message: string(op.Children[2].Content)}
+}
+
+// bind performs a simple bind. Callers must reject empty passwords, which
+// servers treat as an unauthenticated bind that always succeeds.
+func (c *ldapConn) bind(dn, password string) error {
+	id, err := c.send(berConstructed(ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(berOctetString, dn),
+		berString(ldapSimpleAuth, password),
+	))
+	if err != nil {
+		return err
+	}
+
+	op, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if op.Tag != ldapBindResponse {
+		return errors.New("ldap: unexpected bind response")
+	}
+	return ldapResult(op)
+}
+
+// equalityFilter builds (attr=value), optionally and-ed with (objectClass=class)
+func equalityFilter(attr, value, objectClass string) []byte {
+	match := berConstructed(ldapFilterEquality,
+		berString(berOctetString, attr),
+		berString(berOctetString, value),
+	)
+	if objectClass == "" {
+		return match
+	}
+	return berConstructed(ldapFilterAnd,
+		berConstructed(ldapFilterEquality,
+			berString(berOctetString, "objectClass"),
+			berString(berOctetString, objectClass),
+		),
+		match,
+	)
+}
+
+// search runs a subtree search and returns at most sizeLimit entries
+func (c *ldapConn) search(baseDN string, filter []byte, attributes []string, sizeLimit int) ([]ldapEntry, error) {
+	attrs := make([][]byte, 0, len(attributes))
+	for _, attr := range attributes {
+		attrs = append(attrs, berString(berOctetString, attr))
+	}
+
+	id, err := c.send(berConstructed(ldapSearchRequest,
+		berString(berOctetString, baseDN),
+		berInt(berEnumerated, ldapScopeSubtree),
+		berInt(berEnumerated, 0), // never deref aliases
+		berInt(berInteger, sizeLimit),
+		berInt(berInteger, 0),
+		berBool(false),
+		filter,
+		berConstructed(berSequence, attrs...),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ldapEntry
+	for {
+		op, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Tag {
+		case ldapSearchEntry:
+			if len(op.Children) < 2 {
+				return nil, errors.New("ldap: malformed search entry")
+			}
+			entry := ldapEntry{DN: string(op.Children[0].Content), Attributes: make(map[string][]string)}
+			for _, attr := range op.Children[1].Children {
+				if len(attr.Children) < 2 {
+					continue
+				}
+				name := string(attr.Children[0].Content)
+				for _, val := range attr.Children[1].Children {
+					entry.Attributes[name] = append(entry.Attributes[name], string(val.Content))
+				}
+			}
+			entries = append(entries, entry)
+		case ldapSearchReference:
+			// Referrals to other servers are not followed
+		case ldapSearchDone:
+			return entries, ldapResult(op)
+		default:
+			return nil, errors.New("ldap: unexpected search response")
+		}
+	}
+}
+
+func (c *ldapConn) close() {
+	c.send(berTLV(ldapUnbindRequest, nil))
+	c.conn.Close()
+}
@@ -0,0 +1,15 @@
+package auth
+
+import (
+	"digital-logbook/database"
+	"os"
+	"testing"
+)
+
+// TestMain seeds the in-memory database that provisioning writes to
+func TestMain(m *testing.M) {
+	if err := database.Initialize(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
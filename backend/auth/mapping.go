@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"digital-logbook/models"
+	"strings"
+)
+
+// Identity is a user as asserted by an external identity provider
+type Identity struct {
+	Subject  string   `json:"subject"`
+	Username string   `json:"username"`
+	FullName string   `json:"full_name"`
+	Email    string   `json:"email"`
+	Groups   []string `json:"groups"`
+}
+
+// rolePriority orders roles so the most privileged mapped role wins
+var rolePriority = map[models.UserRole]int{
+	models.RoleDataEntry:        1,
+	models.RoleDashboardCargo:   2,
+	models.RoleDashboardVisitor: 3,
+	models.RoleAdmin:            4,
+}
+
+// GroupMapping translates identity provider groups into roles and locations
+type GroupMapping struct {
+	Roles       map[string]models.UserRole // Group name to role
+	Locations   map[string]string          // Group name to location code
+	DefaultRole models.UserRole            // Used when no group maps to a role; empty denies access
+}
+
+// ParseGroupMapping builds a mapping from "group=value" pairs separated by commas,
+// e.g. "Guards=data_entry,Security Admins=admin"
+func ParseGroupMapping(roles, locations, defaultRole string) GroupMapping {
+	mapping := GroupMapping{
+		Roles:       make(map[string]models.UserRole),
+		Locations:   make(map[string]string),
+		DefaultRole: models.UserRole(strings.TrimSpace(defaultRole)),
+	}
+	for group, role := range parsePairs(roles) {
+		mapping.Roles[group] = models.UserRole(role)
+	}
+	for group, code := range parsePairs(locations) {
+		mapping.Locations[group] = code
+	}
+	return mapping
+}
+
+func parsePairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		val := strings.TrimSpace(parts[1])
+		if key != "" && val != "" {
+			pairs[key] = val
+		}
+	}
+	return pairs
+}
+
+// Resolve returns the role and location code for a set of groups.
+// ok is false when none of the groups grant access and there is no default role.
+func (m GroupMapping) Resolve(groups []string) (role models.UserRole, locationCode string, ok bool) {
+	for _, group := range groups {
+		key := strings.ToLower(strings.TrimSpace(group))
+		if mapped, exists := m.Roles[key]; exists && rolePriority[mapped] > rolePriority[role] {
+			role = mapped
+		}
+		if code, exists := m.Locations[key]; exists && locationCode == "" {
+			locationCode = code
+		}
+	}
+
+	if role == "" {
+		role = m.DefaultRole
+	}
+	if _, valid := rolePriority[role]; !valid {
+		return "", "", false
+	}
+	return role, locationCode, true
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "logbook"
+	testKeyID    = "key-1"
+	testCode     = "auth-code"
+)
+
+// oidcStub is an identity provider serving discovery, JWKS and a token
+// endpoint that checks the PKCE verifier against the challenge it was given
+type oidcStub struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string
+	nonce     string
+	// claims builds the ID token's claims for the login's nonce
+	claims func(nonce string) jwt.MapClaims
+	// signWith signs the ID token; the issuer's key unless a test forges one
+	signWith *rsa.PrivateKey
+}
+
+func newOIDCStub(t *testing.T) *oidcStub {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	stub := &oidcStub{key: key, signWith: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                stub.server.URL,
+			AuthorizationEndpoint: stub.server.URL + "/authorize",
+			TokenEndpoint:         stub.server.URL + "/token",
+			JWKSURI:               stub.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kid: testKeyID,
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", stub.token)
+	stub.server = httptest.NewServer(mux)
+	t.Cleanup(stub.server.Close)
+
+	stub.claims = func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                stub.server.URL,
+			"aud":                testClientID,
+			"sub":                "subject-1",
+			"preferred_username": "jwanjiru",
+			"name":               "Jane Wanjiru",
+			"email":              "jwanjiru@example.com",
+			"groups":             []string{"Guards"},
+			"nonce":              nonce,
+			"iat":                time.Now().Unix(),
+			"exp":                time.Now().Add(5 * time.Minute).Unix(),
+		}
+	}
+	return stub
+}
+
+func (s *oidcStub) token(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fail := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code") != testCode {
+		fail("invalid_grant")
+		return
+	}
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifier[:]) != s.challenge {
+		fail("invalid_grant")
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, s.claims(s.nonce))
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(s.signWith)
+	if err != nil {
+		fail("server_error")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+// startLogin runs AuthCodeURL and hands the challenge and nonce to the
+// stub, as the browser's visit to the authorization endpoint would
+func startLogin(t *testing.T, stub *oidcStub, provider *OIDCProvider) string {
+	t.Helper()
+	raw, state, err := provider.AuthCodeURL(context.Background())
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	query := u.Query()
+	if query.Get("state") != state {
+		t.Fatalf("state = %q, want %q", query.Get("state"), state)
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	stub.mu.Lock()
+	stub.challenge = query.Get("code_challenge")
+	stub.nonce = query.Get("nonce")
+	stub.mu.Unlock()
+	return query.Get("state")
+}
+
+func testOIDCProvider(stub *oidcStub) *OIDCProvider {
+	return NewOIDCProvider(OIDCConfig{
+		IssuerURL:   stub.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/api/auth/oidc/callback",
+		Scopes:      []string{"openid"},
+		GroupsClaim: "groups",
+	})
+}
+
+func TestOIDCExchangeValidToken(t *testing.T) {
+	stub := newOIDCStub(t)
+	provider := testOIDCProvider(stub)
+
+	state := startLogin(t, stub, provider)
+	identity, err := provider.Exchange(context.Background(), state, testCode)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != "subject-1" || identity.Username != "jwanjiru" || identity.Email != "jwanjiru@example.com" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if len(identity.Groups) != 1 || identity.Groups[0] != "Guards" {
+		t.Errorf("groups = %v, want [Guards]", identity.Groups)
+	}
+
+	// The state is single use
+	if _, err := provider.Exchange(context.Background(), state, testCode); err == nil {
+		t.Error("replayed state was accepted")
+	}
+}
+
+func TestOIDCExchangeRejectsBadTokens(t *testing.T) {
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(stub *oidcStub)
+		want    string
+	}{
+		{"forged signature", func(stub *oidcStub) {
+			stub.signWith = forger
+		}, "invalid id_token"},
+		{"wrong audience", func(stub *oidcStub) {
+			claims := stub.claims
+			stub.claims = func(nonce string) jwt.MapClaims {
+				c := claims(nonce)
+				c["aud"] = "another-client"
+				return c
+			}
+		}, "invalid id_token"},
+		{"wrong issuer", func(stub *oidcStub) {
+			claims := stub.claims
+			stub.claims = func(nonce string) jwt.MapClaims {
+				c := claims(nonce)
+				c["iss"] = "https://evil.example.com"
+				return c
+			}
+		}, "invalid id_token"},
+		{"expired", func(stub *oidcStub) {
+			claims := stub.claims
+			stub.claims = func(nonce string) jwt.MapClaims {
+				c := claims(nonce)
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+				return c
+			}
+		}, "invalid id_token"},
+		{"nonce mismatch", func(stub *oidcStub) {
+			claims := stub.claims
+			stub.claims = func(string) jwt.MapClaims {
+				return claims("another-login")
+			}
+		}, "nonce mismatch"},
+		{"wrong PKCE verifier", func(stub *oidcStub) {
+			stub.challenge = "not-the-challenge"
+		}, "token exchange failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newOIDCStub(t)
+			provider := testOIDCProvider(stub)
+			state := startLogin(t, stub, provider)
+
+			stub.mu.Lock()
+			tt.prepare(stub)
+			stub.mu.Unlock()
+
+			identity, err := provider.Exchange(context.Background(), state, testCode)
+			if err == nil {
+				t.Fatalf("accepted %+v", identity)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeUnknownState(t *testing.T) {
+	stub := newOIDCStub(t)
+	provider := testOIDCProvider(stub)
+	startLogin(t, stub, provider)
+
+	if _, err := provider.Exchange(context.Background(), "made-up-state", testCode); err == nil {
+		t.Error("unknown state was accepted")
+	}
+}
@@ -0,0 +1,160 @@
+// Package badgeprint renders temporary visitor badges for printing, as PDF
+// for office printers and ZPL for Zebra card and label printers. Each badge
+// carries a QR code with a signed reference to the visit, so scanning it at
+// the exit signs the visitor out.
+package badgeprint
+
+import (
+	"bytes"
+	"digital-logbook/qr"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Badge is what is printed on a visitor badge
+type Badge struct {
+	Location    string
+	VisitorName string
+	Company     string
+	Host        string
+	Area        string
+	BadgeNumber string
+	ValidUntil  time.Time
+	Code        string // Signed code for the QR symbol, from Signer.Sign
+}
+
+// validUntilLayout is how the end of the visit is written on the badge
+const validUntilLayout = "02 Jan 2006 15:04"
+
+// lines returns the text lines below the heading, skipping empty fields
+func (b *Badge) lines() []string {
+	var lines []string
+	if b.Company != "" {
+		lines = append(lines, b.Company)
+	}
+	if b.Host != "" {
+		lines = append(lines, "Host: "+b.Host)
+	}
+	if b.Area != "" {
+		lines = append(lines, "Area: "+b.Area)
+	}
+	return lines
+}
+
+// footer returns the validity and badge number printed along the bottom
+func (b *Badge) footer() string {
+	footer := "Valid until " + b.ValidUntil.Format(validUntilLayout)
+	if b.BadgeNumber != "" {
+		footer += "  Badge " + b.BadgeNumber
+	}
+	return footer
+}
+
+// fit shortens s to at most n characters
+func fit(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
+
+// Card size: CR80, landscape
+const (
+	cardWidth  = 243.0 // Points
+	cardHeight = 153.0
+	cardMargin = 10.0
+	qrWidth    = 92.0 // Including the quiet zone
+)
+
+// PDF renders the badge as a one page PDF the size of an ID card
+func (b *Badge) PDF() ([]byte, error) {
+	code, err := qr.Encode([]byte(b.Code))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &page{width: cardWidth, height: cardHeight}
+	top := cardHeight - cardMargin
+
+	// Heading band
+	p.rect(0, cardHeight-34, cardWidth, 34)
+	p.fill()
+	p.content.WriteString("1 g\n")
+	p.text(fontBold, 16, cardMargin, top-16, "VISITOR")
+	p.text(fontRegular, 8, cardMargin+80, top-15, fit(b.Location, 28))
+	p.content.WriteString("0 g\n")
+
+	// Text column left of the QR code, at an average of half an em per character
+	x, y := cardMargin, top-44
+	p.text(fontBold, 12, x, y, fit(b.VisitorName, 20))
+	for _, line := range b.lines() {
+		y -= 13
+		p.text(fontRegular, 8, x, y, fit(line, 30))
+	}
+	p.text(fontRegular, 7, x, cardMargin, b.footer())
+
+	// QR code with a quiet zone of 4 modules, joining runs of dark modules
+	module := qrWidth / float64(code.Size+8)
+	qx := cardWidth - cardMargin - qrWidth + 4*module
+	qy := cardMargin + 8 + 4*module
+	for row := 0; row < code.Size; row++ {
+		for col := 0; col < code.Size; {
+			if !code.Black(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < code.Size && code.Black(col, row) {
+				col++
+			}
+			p.rect(qx+float64(start)*module, qy+float64(code.Size-1-row)*module, float64(col-start)*module, module)
+		}
+	}
+	p.fill()
+
+	return p.bytes(), nil
+}
+
+// Card size at the 203 dpi of most Zebra printers
+const (
+	zplWidth  = 685 // Dots
+	zplHeight = 431
+)
+
+// ZPL renders the badge as a ZPL II label. The printer draws the QR code
+// itself from the signed code.
+func (b *Badge) ZPL() []byte {
+	var out bytes.Buffer
+	field := func(x, y, height, width int, s string) {
+		fmt.Fprintf(&out, "^FO%d,%d^A0N,%d^FB%d,1,0,L^FD%s^FS\n", x, y, height, width, zplText(s))
+	}
+
+	fmt.Fprintf(&out, "^XA\n^CI28\n^PW%d\n^LL%d\n", zplWidth, zplHeight)
+	out.WriteString("^FO0,0^GB685,90,90^FS\n")
+	out.WriteString("^FO28,22^A0N,52^FR^FDVISITOR^FS\n")
+	fmt.Fprintf(&out, "^FO260,36^A0N,26^FB400,1,0,L^FR^FD%s^FS\n", zplText(b.Location))
+
+	field(28, 118, 40, 420, b.VisitorName)
+	y := 170
+	for _, line := range b.lines() {
+		field(28, y, 26, 420, line)
+		y += 36
+	}
+	field(28, 392, 22, 640, b.footer())
+
+	fmt.Fprintf(&out, "^FO455,105^BQN,2,5^FDMA,%s^FS\n", zplText(b.Code))
+	out.WriteString("^XZ\n")
+	return out.Bytes()
+}
+
+// zplText removes the characters that ZPL treats as commands
+func zplText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '^' || r == '~' || r < 0x20 {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,87 @@
+package badgeprint
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors from Verify
+var (
+	ErrMalformed = errors.New("not a visitor badge code")
+	ErrForged    = errors.New("badge code signature is invalid")
+	ErrExpired   = errors.New("badge code has expired")
+)
+
+// codePrefix marks the codes and versions their format
+const codePrefix = "LB1"
+
+// Signer signs and checks the codes printed on badges. A code reads
+// LB1.<visit ID>.<expiry as Unix time>.<signature>, so it stays small enough
+// for a low-version QR code.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a signer using key, which should be at least 32 bytes
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// SignerFromEnv uses BADGE_CODE_SECRET. Without it a random key is made,
+// and badges printed before a restart can no longer be scanned.
+func SignerFromEnv() *Signer {
+	if secret := os.Getenv("BADGE_CODE_SECRET"); secret != "" {
+		return NewSigner([]byte(secret))
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to generate badge code key: %v", err)
+	}
+	log.Println("BADGE_CODE_SECRET is not set; printed badges stop scanning when the server restarts")
+	return NewSigner(key)
+}
+
+func (s *Signer) signature(message string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Sign returns the code for a visit, valid until expires
+func (s *Signer) Sign(visitID uint, expires time.Time) string {
+	message := codePrefix + "." + strconv.FormatUint(uint64(visitID), 10) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return message + "." + s.signature(message)
+}
+
+// Verify checks a scanned code and returns the visit it was printed for
+func (s *Signer) Verify(code string, now time.Time) (uint, error) {
+	parts := strings.Split(strings.TrimSpace(code), ".")
+	if len(parts) != 4 || parts[0] != codePrefix {
+		return 0, ErrMalformed
+	}
+	visitID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+
+	message := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(s.signature(message))) {
+		return 0, ErrForged
+	}
+	if now.Unix() > expires {
+		return uint(visitID), ErrExpired
+	}
+	return uint(visitID), nil
+}
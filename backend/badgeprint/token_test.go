@@ -0,0 +1,81 @@
+package badgeprint
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	code := signer.Sign(42, testNow.Add(time.Hour))
+
+	if !strings.HasPrefix(code, "LB1.42.") {
+		t.Errorf("code = %q, want the LB1 prefix and visit ID", code)
+	}
+	visitID, err := signer.Verify(code, testNow)
+	if err != nil || visitID != 42 {
+		t.Fatalf("Verify = %d, %v; want 42", visitID, err)
+	}
+	// Scanners may add surrounding whitespace
+	if _, err := signer.Verify(" "+code+"\n", testNow); err != nil {
+		t.Errorf("Verify with whitespace: %v", err)
+	}
+}
+
+func TestSignerExpiry(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	expires := testNow.Add(time.Hour)
+	code := signer.Sign(42, expires)
+
+	if _, err := signer.Verify(code, expires); err != nil {
+		t.Errorf("at expiry: %v", err)
+	}
+	visitID, err := signer.Verify(code, expires.Add(time.Second))
+	if !errors.Is(err, ErrExpired) {
+		t.Fatalf("err = %v, want ErrExpired", err)
+	}
+	// The visit is still named so the caller can point to it
+	if visitID != 42 {
+		t.Errorf("visit ID = %d, want 42", visitID)
+	}
+}
+
+func TestSignerRejectsForgeries(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	code := signer.Sign(42, testNow.Add(time.Hour))
+	parts := strings.Split(code, ".")
+
+	tests := map[string]string{
+		"other key":       NewSigner([]byte("fedcba9876543210fedcba9876543210")).Sign(42, testNow.Add(time.Hour)),
+		"other visit":     strings.Join([]string{parts[0], "43", parts[2], parts[3]}, "."),
+		"later expiry":    strings.Join([]string{parts[0], parts[1], "9999999999", parts[3]}, "."),
+		"truncated mac":   code[:len(code)-1],
+		"empty signature": strings.Join(parts[:3], ".") + ".",
+	}
+	for name, forged := range tests {
+		if _, err := signer.Verify(forged, testNow); !errors.Is(err, ErrForged) {
+			t.Errorf("%s: err = %v, want ErrForged", name, err)
+		}
+	}
+}
+
+func TestSignerRejectsMalformedCodes(t *testing.T) {
+	signer := NewSigner([]byte("0123456789abcdef0123456789abcdef"))
+	for _, code := range []string{
+		"",
+		"B001",
+		"LB1.42.1792401526",
+		"LB2.42.1792401526.SKUHhyFEWjJE82XbvhqpcA",
+		"LB1.x.1792401526.SKUHhyFEWjJE82XbvhqpcA",
+		"LB1.42.soon.SKUHhyFEWjJE82XbvhqpcA",
+		"LB1.99999999999.1792401526.SKUHhyFEWjJE82XbvhqpcA",
+	} {
+		if _, err := signer.Verify(code, testNow); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Verify(%q) err = %v, want ErrMalformed", code, err)
+		}
+	}
+}
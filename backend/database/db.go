@@ -3,7 +3,9 @@ package database
 import (
 	"digital-logbook/models"
 	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,17 +16,59 @@ import (
 var (
 	users    = make(map[uint]*models.User)
 	visitors = make(map[uint]*models.Visitor)
+	visits   = make(map[uint]*models.Visit)
 	cargo    = make(map[uint]*models.Cargo)
 	fitness       = make(map[uint]*models.FitnessAttendance)
 	fitnessMembers = make(map[uint]*models.FitnessMember)
 	locations     = make(map[uint]*models.Location)
+	serviceAccounts = make(map[uint]*models.ServiceAccount)
+	apiKeys         = make(map[uint]*models.APIKey)
+	sessions        = make(map[uint]*models.Session)
+	watchlist       = make(map[uint]*models.WatchlistEntry)
+	auditLogs       = make(map[uint]*models.AuditLog)
+	badges          = make(map[uint]*models.Badge)
+	alerts          = make(map[uint]*models.Alert)
+	drills          = make(map[uint]*models.Drill)
+	hosts           = make(map[uint]*models.Host)
+	groups          = make(map[uint]*models.VisitGroup)
+	visitImages     = make(map[uint]*models.VisitImage)
+	orphanedBlobs   []string // Blob keys of deleted images and signatures, see TakeOrphanedBlobs
+	ackDocuments    = make(map[uint]*models.AckDocument)
+	ackVersions     = make(map[uint][]*models.AckDocumentVersion) // By document ID, oldest first
+	acknowledgements = make(map[uint]*models.Acknowledgement)
+	declaredItems   = make(map[uint]*models.DeclaredItem)
+	parkingBays     = make(map[uint]*models.ParkingBay)
+	areas           = make(map[uint]*models.Area)
+	escortAssignments = make(map[uint]*models.EscortAssignment)
+	statusChanges   = make(map[uint]*models.VisitStatusChange)
+	sweepReports    = make(map[uint]*models.SweepReport)
 
 	userID          uint = 1
 	visitorID       uint = 1
+	visitID         uint = 1
 	cargoID         uint = 1
 	fitnessID       uint = 1
 	fitnessMemberID uint = 1
 	locationID      uint = 1
+	serviceAccountID uint = 1
+	apiKeyID         uint = 1
+	sessionID        uint = 1
+	watchlistID      uint = 1
+	auditLogID       uint = 1
+	badgeID          uint = 1
+	alertID          uint = 1
+	sweepReportID    uint = 1
+	drillID          uint = 1
+	hostID           uint = 1
+	groupID          uint = 1
+	visitImageID     uint = 1
+	ackDocumentID    uint = 1
+	acknowledgementID uint = 1
+	declaredItemID   uint = 1
+	parkingBayID     uint = 1
+	areaID           uint = 1
+	escortAssignmentID uint = 1
+	statusChangeID   uint = 1
 
 	mu sync.RWMutex // Mutex for thread-safe operations
 )
@@ -76,6 +120,7 @@ func seedDefaultData() {
 		PasswordHash: string(hashedPassword),
 		Role:         models.RoleAdmin,
 		FullName:     "System Administrator",
+		AuthProvider: models.AuthProviderLocal,
 		CreatedAt:    time.Now(),
 	}
 	users[userID] = admin
@@ -90,24 +135,19 @@ func seedDefaultData() {
 		Role:         models.RoleDataEntry,
 		FullName:     "Data Entry Operator",
 		LocationID:   &loc1.ID,
+		AuthProvider: models.AuthProviderLocal,
 		CreatedAt:    time.Now(),
 	}
 	users[userID] = dataEntry
 	userID++
 
-	// Create sample visitors
+	// Create sample visitors and their visits
 	visitor1 := &models.Visitor{
 		ID:          visitorID,
 		Name:        "John Doe",
 		IDNumber:    "12345678",
-		AreaOfVisit: "Terminal A",
 		CompanyFrom: "ABC Logistics",
-		Purpose:     "Cargo inspection",
-		Status:      models.StatusSignedIn,
-		BadgeNumber: "B001",
-		SignInTime:  time.Now().Add(-2 * time.Hour),
-		LocationID:  loc1.ID,
-		CreatedAt:   time.Now().Add(-2 * time.Hour),
+		CreatedAt:   time.Now().Add(-26 * time.Hour),
 	}
 	visitors[visitorID] = visitor1
 	visitorID++
@@ -116,19 +156,105 @@ func seedDefaultData() {
 		ID:          visitorID,
 		Name:        "Jane Smith",
 		IDNumber:    "87654321",
-		AreaOfVisit: "Terminal B",
 		CompanyFrom: "XYZ Airlines",
-		Purpose:     "Meeting with staff",
-		Status:      models.StatusSignedOut,
-		BadgeNumber: "B002",
-		SignInTime:  time.Now().Add(-5 * time.Hour),
-		SignOutTime: &[]time.Time{time.Now().Add(-3 * time.Hour)}[0],
-		LocationID:  loc2.ID,
 		CreatedAt:   time.Now().Add(-5 * time.Hour),
 	}
 	visitors[visitorID] = visitor2
 	visitorID++
 
+	sampleVisits := []*models.Visit{
+		{
+			VisitorID:   visitor1.ID,
+			AreaOfVisit: "Terminal A",
+			Purpose:     "Cargo inspection",
+			Status:      models.StatusSignedOut,
+			BadgeNumber: "B003",
+			SignInTime:  time.Now().Add(-26 * time.Hour),
+			SignOutTime: &[]time.Time{time.Now().Add(-24 * time.Hour)}[0],
+			LocationID:  loc1.ID,
+		},
+		{
+			VisitorID:   visitor1.ID,
+			AreaOfVisit: "Terminal A",
+			Purpose:     "Cargo inspection",
+			Status:      models.StatusSignedIn,
+			BadgeNumber: "B001",
+			SignInTime:  time.Now().Add(-2 * time.Hour),
+			LocationID:  loc1.ID,
+		},
+		{
+			VisitorID:   visitor2.ID,
+			AreaOfVisit: "Terminal B",
+			Purpose:     "Meeting with staff",
+			Status:      models.StatusSignedOut,
+			BadgeNumber: "B002",
+			SignInTime:  time.Now().Add(-5 * time.Hour),
+			SignOutTime: &[]time.Time{time.Now().Add(-3 * time.Hour)}[0],
+			LocationID:  loc2.ID,
+		},
+	}
+	// Create the host directory
+	sampleHosts := []*models.Host{
+		{Name: "Mary Wanjiku", Department: "Cargo Operations", Email: "mary.wanjiku@example.com", Phone: "+254700000001", LocationID: loc1.ID},
+		{Name: "Peter Kamau", Department: "IT", Email: "peter.kamau@example.com", Phone: "+254700000002", LocationID: loc1.ID},
+		{Name: "Aisha Mohamed", Department: "Port Security", Email: "aisha.mohamed@example.com", Phone: "+254700000003", LocationID: loc2.ID},
+	}
+	for _, host := range sampleHosts {
+		host.ID = hostID
+		host.Active = true
+		host.CreatedAt = time.Now()
+		hosts[hostID] = host
+		hostID++
+	}
+
+	// Create badge inventory for each location
+	for _, loc := range []*models.Location{loc1, loc2} {
+		for n := 1; n <= 10; n++ {
+			badges[badgeID] = &models.Badge{
+				ID:         badgeID,
+				LocationID: loc.ID,
+				Number:     fmt.Sprintf("B%03d", n),
+				Type:       "visitor",
+				Status:     models.BadgeAvailable,
+				CreatedAt:  time.Now(),
+			}
+			badgeID++
+		}
+	}
+
+	// Create visitor parking bays for each location
+	for _, loc := range []*models.Location{loc1, loc2} {
+		for n := 1; n <= 5; n++ {
+			parkingBays[parkingBayID] = &models.ParkingBay{
+				ID:         parkingBayID,
+				LocationID: loc.ID,
+				Number:     fmt.Sprintf("P%02d", n),
+				Zone:       "Visitor car park",
+				Status:     models.BayAvailable,
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
+			}
+			parkingBayID++
+		}
+	}
+
+	for _, visit := range sampleVisits {
+		visit.ID = visitID
+		visit.CreatedAt = visit.SignInTime
+		departure := visit.SignInTime.Add(models.DefaultVisitDuration)
+		visit.ExpectedDeparture = &departure
+		if badge := findBadgeLocked(visit.LocationID, visit.BadgeNumber); badge != nil {
+			visit.BadgeID = &badge.ID
+			if visit.IsActive() {
+				badge.Status = models.BadgeIssued
+				badge.VisitID = &visit.ID
+				badge.IssuedAt = &visit.SignInTime
+			}
+		}
+		visits[visitID] = visit
+		visitID++
+	}
+
 	// Create sample cargo
 	cargo1 := &models.Cargo{
 		ID:                  cargoID,
@@ -141,6 +267,7 @@ func seedDefaultData() {
 		VehicleRegistration: "ABC-1234",
 		SealNumber:          "SEAL001",
 		LocationID:          loc1.ID,
+		TimeIn:              time.Now().Add(-1 * time.Hour),
 		CreatedAt:           time.Now().Add(-1 * time.Hour),
 	}
 	cargo[cargoID] = cargo1
@@ -181,6 +308,19 @@ func (db *MockDB) GetUserByUsername(username string) (*models.User, error) {
 	return nil, errors.New("user not found")
 }
 
+// GetUserByExternalID finds a user provisioned from an external identity provider
+func (db *MockDB) GetUserByExternalID(provider models.AuthProvider, externalID string) (*models.User, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, user := range users {
+		if user.AuthProvider == provider && user.ExternalID == externalID {
+			return user, nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
 func (db *MockDB) GetUserByID(id uint) (*models.User, error) {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -243,6 +383,7 @@ func (db *MockDB) CreateVisitor(visitor *models.Visitor) error {
 	return nil
 }
 
+// GetVisitorByID returns the visitor profile with their latest visit
 func (db *MockDB) GetVisitorByID(id uint) (*models.Visitor, error) {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -251,32 +392,75 @@ func (db *MockDB) GetVisitorByID(id uint) (*models.Visitor, error) {
 	if !exists {
 		return nil, errors.New("visitor not found")
 	}
-	return visitor, nil
+	return withCurrentVisit(visitor, 0), nil
+}
+
+// GetVisitorAtLocation returns a visitor who has visited the location, with
+// their latest visit there. Location 0 finds visitors anywhere.
+func (db *MockDB) GetVisitorAtLocation(id, locationID uint) (*models.Visitor, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	visitor, exists := visitors[id]
+	if !exists {
+		return nil, errors.New("visitor not found")
+	}
+	profile := withCurrentVisit(visitor, locationID)
+	if locationID != 0 && profile.CurrentVisit == nil {
+		return nil, errors.New("visitor not found")
+	}
+	return profile, nil
 }
 
+// GetAllVisitors returns visitor profiles. The location_id filter keeps
+// visitors who have visited that location, and status matches their latest
+// visit there.
 func (db *MockDB) GetAllVisitors(filters map[string]interface{}) []*models.Visitor {
 	mu.RLock()
 	defer mu.RUnlock()
 
+	locationID, _ := filters["location_id"].(uint)
+
 	result := make([]*models.Visitor, 0, len(visitors))
 	for _, visitor := range visitors {
+		profile := withCurrentVisit(visitor, locationID)
+		if locationID != 0 && profile.CurrentVisit == nil {
+			continue
+		}
 		// Apply filters if provided
 		if status, ok := filters["status"].(string); ok {
-			if string(visitor.Status) != status {
+			if profile.CurrentVisit == nil || string(profile.CurrentVisit.Status) != status {
 				continue
 			}
 		}
-		if locationID, ok := filters["location_id"].(uint); ok {
-			if visitor.LocationID != locationID {
-				continue
-			}
+		result = append(result, profile)
+	}
+	return result
+}
+
+// GetVisitorsByIDNumber returns every profile recorded under the ID number,
+// most recently visited first
+func (db *MockDB) GetVisitorsByIDNumber(idNumber string) []*models.Visitor {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	want := models.NormalizeIDNumber(idNumber)
+	result := make([]*models.Visitor, 0)
+	for _, visitor := range visitors {
+		if models.NormalizeIDNumber(visitor.IDNumber) == want {
+			result = append(result, withCurrentVisit(visitor, 0))
 		}
-		// Populate location data
-		if loc, exists := locations[visitor.LocationID]; exists {
-			visitor.Location = loc
+	}
+
+	lastSeen := func(v *models.Visitor) time.Time {
+		if v.CurrentVisit != nil {
+			return v.CurrentVisit.SignInTime
 		}
-		result = append(result, visitor)
+		return v.CreatedAt
 	}
+	sort.Slice(result, func(i, j int) bool {
+		return lastSeen(result[i]).After(lastSeen(result[j]))
+	})
 	return result
 }
 
@@ -287,10 +471,13 @@ func (db *MockDB) UpdateVisitor(visitor *models.Visitor) error {
 	if _, exists := visitors[visitor.ID]; !exists {
 		return errors.New("visitor not found")
 	}
-	visitors[visitor.ID] = visitor
+	stored := *visitor
+	stored.CurrentVisit = nil
+	visitors[visitor.ID] = &stored
 	return nil
 }
 
+// DeleteVisitor removes the visitor and their visit history
 func (db *MockDB) DeleteVisitor(id uint) error {
 	mu.Lock()
 	defer mu.Unlock()
@@ -299,6 +486,18 @@ func (db *MockDB) DeleteVisitor(id uint) error {
 		return errors.New("visitor not found")
 	}
 	delete(visitors, id)
+	for visitID, visit := range visits {
+		if visit.VisitorID == id {
+			delete(visits, visitID)
+			detachBadgesLocked(visitID)
+			detachBaysLocked(visitID)
+			dropVisitImagesLocked(visitID)
+			dropDeclaredItemsLocked(visitID)
+			dropEscortsLocked(visitID)
+			dropStatusChangesLocked(visitID)
+		}
+	}
+	dropAcknowledgementsLocked(id)
 	return nil
 }
 
@@ -332,11 +531,21 @@ func (db *MockDB) GetAllCargo(filters map[string]interface{}) []*models.Cargo {
 	result := make([]*models.Cargo, 0, len(cargo))
 	for _, c := range cargo {
 		// Apply filters if provided
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if c.LocationID != locationID {
+				continue
+			}
+		}
 		if category, ok := filters["category"].(string); ok {
 			if string(c.Category) != category {
 				continue
 			}
 		}
+		if released, ok := filters["released"].(bool); ok {
+			if c.IsReleased() != released {
+				continue
+			}
+		}
 		result = append(result, c)
 	}
 	return result
@@ -466,6 +675,16 @@ func (db *MockDB) GetAllFitnessAttendance(filters map[string]interface{}) []*mod
 				continue
 			}
 		}
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if f.LocationID == nil || *f.LocationID != locationID {
+				continue
+			}
+		}
+		if open, ok := filters["open"].(bool); ok {
+			if (f.CheckOut == nil) != open {
+				continue
+			}
+		}
 		// Populate member data
 		if member, exists := fitnessMembers[f.MemberID]; exists {
 			f.Member = member
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+func ackDocumentCopy(doc *models.AckDocument) *models.AckDocument {
+	d := *doc
+	d.Location = nil
+	if loc, exists := locations[d.LocationID]; exists {
+		d.Location = loc
+	}
+	return &d
+}
+
+func acknowledgementCopy(ack *models.Acknowledgement) *models.Acknowledgement {
+	a := *ack
+	a.Document = nil
+	if doc, exists := ackDocuments[a.DocumentID]; exists {
+		a.Document = ackDocumentCopy(doc)
+		a.Document.Location = nil
+	}
+	if visitor, exists := visitors[a.VisitorID]; exists {
+		a.VisitorName = visitor.Name
+	}
+	a.HasSignature = a.SignatureKey != ""
+	return &a
+}
+
+// dropAcknowledgementsLocked deletes the visitor's acknowledgements and queues
+// their signatures for removal; callers must hold mu
+func dropAcknowledgementsLocked(visitorID uint) {
+	for id, ack := range acknowledgements {
+		if ack.VisitorID != visitorID {
+			continue
+		}
+		if ack.SignatureKey != "" {
+			orphanedBlobs = append(orphanedBlobs, ack.SignatureKey)
+		}
+		delete(acknowledgements, id)
+	}
+}
+
+// Acknowledgement document operations
+
+// CreateAckDocument stores the document as version 1
+func (db *MockDB) CreateAckDocument(doc *models.AckDocument) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := locations[doc.LocationID]; !exists {
+		return errors.New("location not found")
+	}
+	now := time.Now()
+	doc.ID = ackDocumentID
+	doc.Version = 1
+	doc.CreatedAt = now
+	doc.UpdatedAt = now
+	stored := *doc
+	stored.Location = nil
+	ackDocuments[ackDocumentID] = &stored
+	ackVersions[doc.ID] = []*models.AckDocumentVersion{{
+		DocumentID:  doc.ID,
+		Version:     1,
+		Title:       doc.Title,
+		Body:        doc.Body,
+		PublishedBy: doc.CreatedBy,
+		PublishedAt: now,
+	}}
+	ackDocumentID++
+	return nil
+}
+
+func (db *MockDB) GetAckDocumentByID(id uint) (*models.AckDocument, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	doc, exists := ackDocuments[id]
+	if !exists {
+		return nil, errors.New("acknowledgement document not found")
+	}
+	return ackDocumentCopy(doc), nil
+}
+
+// GetAllAckDocuments filters by location_id, kind, active and area_of_visit
+// (documents that apply to that area, including location-wide ones)
+func (db *MockDB) GetAllAckDocuments(filters map[string]interface{}) []*models.AckDocument {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.AckDocument, 0)
+	for _, doc := range ackDocuments {
+		if locationID, ok := filters["location_id"].(uint); ok && doc.LocationID != locationID {
+			continue
+		}
+		if kind, ok := filters["kind"].(models.AckDocumentKind); ok && doc.Kind != kind {
+			continue
+		}
+		if active, ok := filters["active"].(bool); ok && doc.Active != active {
+			continue
+		}
+		if area, ok := filters["area_of_visit"].(string); ok && !doc.AppliesTo(doc.LocationID, area) {
+			continue
+		}
+		result = append(result, ackDocumentCopy(doc))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// UpdateAckDocument saves the document. A changed title or body is published
+// as a new version by publishedBy; the return value tells whether it was.
+func (db *MockDB) UpdateAckDocument(doc *models.AckDocument, publishedBy uint) (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current, exists := ackDocuments[doc.ID]
+	if !exists {
+		return false, errors.New("acknowledgement document not found")
+	}
+
+	now := time.Now()
+	doc.Version = current.Version
+	doc.CreatedAt = current.CreatedAt
+	doc.UpdatedAt = now
+	published := doc.Title != current.Title || doc.Body != current.Body
+	if published {
+		doc.Version++
+		ackVersions[doc.ID] = append(ackVersions[doc.ID], &models.AckDocumentVersion{
+			DocumentID:  doc.ID,
+			Version:     doc.Version,
+			Title:       doc.Title,
+			Body:        doc.Body,
+			PublishedBy: publishedBy,
+			PublishedAt: now,
+		})
+	}
+	stored := *doc
+	stored.Location = nil
+	ackDocuments[doc.ID] = &stored
+	return published, nil
+}
+
+// GetAckDocumentVersions returns every published version of the document, oldest first
+func (db *MockDB) GetAckDocumentVersions(documentID uint) []*models.AckDocumentVersion {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.AckDocumentVersion, 0, len(ackVersions[documentID]))
+	for _, v := range ackVersions[documentID] {
+		version := *v
+		result = append(result, &version)
+	}
+	return result
+}
+
+// GetAckDocumentVersion returns the text of one published version
+func (db *MockDB) GetAckDocumentVersion(documentID uint, version int) (*models.AckDocumentVersion, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, v := range ackVersions[documentID] {
+		if v.Version == version {
+			found := *v
+			return &found, nil
+		}
+	}
+	return nil, errors.New("document version not found")
+}
+
+// MissingAcknowledgements returns the active documents for the area that the
+// visitor has no current acceptance of. New visitors, with ID 0, need them all.
+func (db *MockDB) MissingAcknowledgements(visitorID, locationID uint, area string, now time.Time) []*models.AckDocument {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	missing := make([]*models.AckDocument, 0)
+	for _, doc := range ackDocuments {
+		if !doc.Active || !doc.AppliesTo(locationID, area) {
+			continue
+		}
+		covered := false
+		if visitorID != 0 {
+			for _, ack := range acknowledgements {
+				if ack.VisitorID == visitorID && ack.Covers(doc, now) {
+					covered = true
+					break
+				}
+			}
+		}
+		if !covered {
+			missing = append(missing, ackDocumentCopy(doc))
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].ID < missing[j].ID
+	})
+	return missing
+}
+
+// Acknowledgement operations
+
+// CreateAcknowledgements records acceptances together, failing if a visitor
+// or document was deleted meanwhile
+func (db *MockDB) CreateAcknowledgements(acks []*models.Acknowledgement) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, ack := range acks {
+		if _, exists := visitors[ack.VisitorID]; !exists {
+			return errors.New("visitor not found")
+		}
+		if _, exists := ackDocuments[ack.DocumentID]; !exists {
+			return errors.New("acknowledgement document not found")
+		}
+	}
+	for _, ack := range acks {
+		ack.ID = acknowledgementID
+		stored := *ack
+		stored.Document = nil
+		acknowledgements[acknowledgementID] = &stored
+		acknowledgementID++
+	}
+	return nil
+}
+
+func (db *MockDB) GetAcknowledgementByID(id uint) (*models.Acknowledgement, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	ack, exists := acknowledgements[id]
+	if !exists {
+		return nil, errors.New("acknowledgement not found")
+	}
+	return acknowledgementCopy(ack), nil
+}
+
+// GetAllAcknowledgements returns acknowledgements, newest first. Filters:
+// visitor_id, document_id, visit_id, location_id, and from/to bounding the
+// acceptance time.
+func (db *MockDB) GetAllAcknowledgements(filters map[string]interface{}) []*models.Acknowledgement {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Acknowledgement, 0)
+	for _, ack := range acknowledgements {
+		if visitorID, ok := filters["visitor_id"].(uint); ok && ack.VisitorID != visitorID {
+			continue
+		}
+		if documentID, ok := filters["document_id"].(uint); ok && ack.DocumentID != documentID {
+			continue
+		}
+		if visitID, ok := filters["visit_id"].(uint); ok && (ack.VisitID == nil || *ack.VisitID != visitID) {
+			continue
+		}
+		if locationID, ok := filters["location_id"].(uint); ok && ack.LocationID != locationID {
+			continue
+		}
+		if from, ok := filters["from"].(time.Time); ok && ack.AcceptedAt.Before(from) {
+			continue
+		}
+		if to, ok := filters["to"].(time.Time); ok && !ack.AcceptedAt.Before(to) {
+			continue
+		}
+		result = append(result, acknowledgementCopy(ack))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AcceptedAt.After(result[j].AcceptedAt)
+	})
+	return result
+}
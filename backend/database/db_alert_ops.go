@@ -0,0 +1,202 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Alert operations
+func (db *MockDB) CreateAlert(alert *models.Alert) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	alert.ID = alertID
+	alert.CreatedAt = time.Now()
+	stored := *alert
+	alerts[alertID] = &stored
+	alertID++
+	return nil
+}
+
+func (db *MockDB) GetAlertByID(id uint) (*models.Alert, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	alert, exists := alerts[id]
+	if !exists {
+		return nil, errors.New("alert not found")
+	}
+	a := *alert
+	return &a, nil
+}
+
+// GetAllAlerts returns alerts, newest first. Filters: location_id, type, acknowledged.
+func (db *MockDB) GetAllAlerts(filters map[string]interface{}) []*models.Alert {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if alert.LocationID != locationID {
+				continue
+			}
+		}
+		if alertType, ok := filters["type"].(string); ok {
+			if string(alert.Type) != alertType {
+				continue
+			}
+		}
+		if acknowledged, ok := filters["acknowledged"].(bool); ok {
+			if (alert.AcknowledgedAt != nil) != acknowledged {
+				continue
+			}
+		}
+		a := *alert
+		result = append(result, &a)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
+
+// AcknowledgeAlert records who dealt with an alert; acknowledging twice keeps the first
+func (db *MockDB) AcknowledgeAlert(id, userID uint) (*models.Alert, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	alert, exists := alerts[id]
+	if !exists {
+		return nil, errors.New("alert not found")
+	}
+	if alert.AcknowledgedAt == nil {
+		now := time.Now()
+		alert.AcknowledgedAt = &now
+		alert.AcknowledgedBy = &userID
+	}
+	a := *alert
+	return &a, nil
+}
+
+// End-of-day sweep operations
+
+// SweepLocationVisits auto signs out every visit still open at a location and
+// moves the badges they hold to unreturned. Kiosk submissions nobody confirmed
+// are cancelled. The report's LocationID, Date,
+// Trigger and TriggeredBy are taken from the argument. Scheduled sweeps run at
+// most once per location and date; the second return value is false if one
+// already has, in which case the earlier report is returned.
+func (db *MockDB) SweepLocationVisits(sweep *models.SweepReport, now time.Time) (*models.SweepReport, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if sweep.Trigger == models.SweepScheduled {
+		for _, report := range sweepReports {
+			if report.LocationID == sweep.LocationID && report.Date == sweep.Date && report.Trigger == models.SweepScheduled {
+				r := *report
+				return &r, false
+			}
+		}
+	}
+
+	locationID := sweep.LocationID
+	report := &models.SweepReport{
+		ID:               sweepReportID,
+		LocationID:       locationID,
+		Date:             sweep.Date,
+		Trigger:          sweep.Trigger,
+		TriggeredBy:      sweep.TriggeredBy,
+		RanAt:            now,
+		SignedOutVisits:  []uint{},
+		UnreturnedBadges: []models.UnreturnedBadge{},
+	}
+	for _, visit := range visits {
+		if visit.LocationID == locationID && visit.IsPending() {
+			visit.Cancel(models.CancelReasonUnconfirmed, now, sweep.TriggeredBy)
+			visit.UpdatedAt = now
+			recordStatusChangeLocked(visit, models.StatusPending)
+			continue
+		}
+		if visit.LocationID != locationID || !visit.IsActive() {
+			continue
+		}
+		visit.AutoSignOut(now, sweep.TriggeredBy)
+		visit.UpdatedAt = now
+		recordStatusChangeLocked(visit, models.StatusSignedIn)
+		report.SignedOutVisits = append(report.SignedOutVisits, visit.ID)
+		if visit.ParkingBayID != nil {
+			releaseBayLocked(*visit.ParkingBayID, visit.ID)
+		}
+		endEscortLocked(visit.ID, now)
+
+		if visit.BadgeID == nil {
+			continue
+		}
+		badge, exists := badges[*visit.BadgeID]
+		if !exists || badge.Status != models.BadgeIssued || badge.VisitID == nil || *badge.VisitID != visit.ID {
+			continue
+		}
+		badge.Status = models.BadgeUnreturned
+		badge.UpdatedAt = now
+
+		entry := models.UnreturnedBadge{
+			BadgeID:   badge.ID,
+			Number:    badge.Number,
+			VisitID:   visit.ID,
+			VisitorID: visit.VisitorID,
+		}
+		if visitor, exists := visitors[visit.VisitorID]; exists {
+			entry.VisitorName = visitor.Name
+		}
+		report.UnreturnedBadges = append(report.UnreturnedBadges, entry)
+	}
+	sort.Slice(report.SignedOutVisits, func(i, j int) bool {
+		return report.SignedOutVisits[i] < report.SignedOutVisits[j]
+	})
+	sort.Slice(report.UnreturnedBadges, func(i, j int) bool {
+		return report.UnreturnedBadges[i].Number < report.UnreturnedBadges[j].Number
+	})
+
+	sweepReports[sweepReportID] = report
+	sweepReportID++
+	r := *report
+	return &r, true
+}
+
+func (db *MockDB) GetSweepReportByID(id uint) (*models.SweepReport, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	report, exists := sweepReports[id]
+	if !exists {
+		return nil, errors.New("sweep report not found")
+	}
+	r := *report
+	return &r, nil
+}
+
+// GetAllSweepReports returns sweep reports, newest first. Filters: location_id.
+func (db *MockDB) GetAllSweepReports(filters map[string]interface{}) []*models.SweepReport {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.SweepReport, 0, len(sweepReports))
+	for _, report := range sweepReports {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if report.LocationID != locationID {
+				continue
+			}
+		}
+		r := *report
+		result = append(result, &r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RanAt.After(result[j].RanAt)
+	})
+	return result
+}
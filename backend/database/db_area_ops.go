@@ -0,0 +1,237 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAreaFull        = errors.New("area is at maximum occupancy")
+	ErrBadgeNotForArea = errors.New("badge does not grant access to the area")
+	ErrAreaNameInUse   = errors.New("area name already exists at this location")
+)
+
+// findAreaLocked looks up an area by name, ignoring case; callers must hold mu
+func findAreaLocked(locationID uint, name string) *models.Area {
+	for _, area := range areas {
+		if area.LocationID == locationID && models.SameAreaName(area.Name, name) {
+			return area
+		}
+	}
+	return nil
+}
+
+// areaOnSiteLocked counts the visitors signed in to the area; callers must hold mu
+func areaOnSiteLocked(areaID uint) int {
+	onSite := 0
+	for _, visit := range visits {
+		if visit.AreaID != nil && *visit.AreaID == areaID && visit.IsActive() {
+			onSite++
+		}
+	}
+	return onSite
+}
+
+// areaHasRoomLocked fails if the area cannot take the arriving visitors; callers must hold mu
+func areaHasRoomLocked(areaID uint, arriving int) error {
+	area, exists := areas[areaID]
+	if !exists {
+		return nil
+	}
+	if area.IsFull(areaOnSiteLocked(areaID) + arriving - 1) {
+		return ErrAreaFull
+	}
+	return nil
+}
+
+// areaAdmitsLocked fails if the visit's badge does not open its area; callers must hold mu
+func areaAdmitsLocked(visit *models.Visit, badge *models.Badge) error {
+	if visit.AreaID == nil {
+		return nil
+	}
+	if area, exists := areas[*visit.AreaID]; exists && !area.Admits(badge) {
+		return ErrBadgeNotForArea
+	}
+	return nil
+}
+
+func areaCopy(area *models.Area) *models.Area {
+	a := *area
+	a.Location = nil
+	if loc, exists := locations[a.LocationID]; exists {
+		a.Location = loc
+	}
+	a.BadgeTypes = append([]string{}, area.BadgeTypes...)
+	a.OnSite = areaOnSiteLocked(a.ID)
+	return &a
+}
+
+// Area operations
+func (db *MockDB) CreateArea(area *models.Area) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	area.Name = strings.TrimSpace(area.Name)
+	if findAreaLocked(area.LocationID, area.Name) != nil {
+		return ErrAreaNameInUse
+	}
+
+	now := time.Now()
+	area.ID = areaID
+	area.CreatedAt = now
+	area.UpdatedAt = now
+	stored := *area
+	stored.Location = nil
+	stored.OnSite = 0
+	areas[areaID] = &stored
+	areaID++
+	return nil
+}
+
+func (db *MockDB) GetAreaByID(id uint) (*models.Area, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	area, exists := areas[id]
+	if !exists {
+		return nil, errors.New("area not found")
+	}
+	return areaCopy(area), nil
+}
+
+// GetAreaByName finds a location's area by name, ignoring case
+func (db *MockDB) GetAreaByName(locationID uint, name string) (*models.Area, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	area := findAreaLocked(locationID, name)
+	if area == nil {
+		return nil, errors.New("area not found")
+	}
+	return areaCopy(area), nil
+}
+
+// GetAllAreas returns areas sorted by location and name, each with the
+// visitors on site. Filters: location_id, access_level and active.
+func (db *MockDB) GetAllAreas(filters map[string]interface{}) []*models.Area {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Area, 0, len(areas))
+	for _, area := range areas {
+		if locationID, ok := filters["location_id"].(uint); ok && area.LocationID != locationID {
+			continue
+		}
+		if level, ok := filters["access_level"].(models.AreaAccessLevel); ok && area.AccessLevel != level {
+			continue
+		}
+		if active, ok := filters["active"].(bool); ok && area.Active != active {
+			continue
+		}
+		result = append(result, areaCopy(area))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LocationID != result[j].LocationID {
+			return result[i].LocationID < result[j].LocationID
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// HasAreas returns true if the location has active managed areas, in which
+// case visits must be signed in to one of them
+func (db *MockDB) HasAreas(locationID uint) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, area := range areas {
+		if area.LocationID == locationID && area.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAreaOccupancy reports the visitors signed in to each active area. Filters: location_id.
+func (db *MockDB) GetAreaOccupancy(filters map[string]interface{}) []*models.AreaOccupancy {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	onSite := make(map[uint]int)
+	for _, visit := range visits {
+		if visit.AreaID != nil && visit.IsActive() {
+			onSite[*visit.AreaID]++
+		}
+	}
+
+	result := make([]*models.AreaOccupancy, 0)
+	for _, area := range areas {
+		if !area.Active {
+			continue
+		}
+		if locationID, ok := filters["location_id"].(uint); ok && area.LocationID != locationID {
+			continue
+		}
+		occupancy := &models.AreaOccupancy{
+			AreaID:       area.ID,
+			Name:         area.Name,
+			LocationID:   area.LocationID,
+			AccessLevel:  area.AccessLevel,
+			OnSite:       onSite[area.ID],
+			MaxOccupancy: area.MaxOccupancy,
+			Full:         area.IsFull(onSite[area.ID]),
+		}
+		if area.MaxOccupancy > 0 {
+			available := area.MaxOccupancy - occupancy.OnSite
+			if available < 0 {
+				available = 0
+			}
+			occupancy.Available = &available
+		}
+		result = append(result, occupancy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LocationID != result[j].LocationID {
+			return result[i].LocationID < result[j].LocationID
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (db *MockDB) UpdateArea(area *models.Area) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current, exists := areas[area.ID]
+	if !exists {
+		return errors.New("area not found")
+	}
+	area.Name = strings.TrimSpace(area.Name)
+	if existing := findAreaLocked(area.LocationID, area.Name); existing != nil && existing.ID != area.ID {
+		return ErrAreaNameInUse
+	}
+	area.CreatedAt = current.CreatedAt
+	area.UpdatedAt = time.Now()
+	stored := *area
+	stored.Location = nil
+	stored.OnSite = 0
+	areas[area.ID] = &stored
+	return nil
+}
+
+func (db *MockDB) DeleteArea(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := areas[id]; !exists {
+		return errors.New("area not found")
+	}
+	delete(areas, id)
+	return nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"sort"
+	"time"
+)
+
+// Audit log operations. Entries are append-only.
+func (db *MockDB) CreateAuditLog(entry *models.AuditLog) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entry.ID = auditLogID
+	entry.CreatedAt = time.Now()
+	auditLogs[auditLogID] = entry
+	auditLogID++
+	return nil
+}
+
+// GetAllAuditLogs returns entries newest first. Filters: action, entity_type,
+// entity_id, location_id, and from/to bounding the creation time.
+func (db *MockDB) GetAllAuditLogs(filters map[string]interface{}) []*models.AuditLog {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.AuditLog, 0, len(auditLogs))
+	for _, entry := range auditLogs {
+		if action, ok := filters["action"].(string); ok {
+			if string(entry.Action) != action {
+				continue
+			}
+		}
+		if entityType, ok := filters["entity_type"].(string); ok {
+			if entry.EntityType != entityType {
+				continue
+			}
+		}
+		if entityID, ok := filters["entity_id"].(uint); ok {
+			if entry.EntityID != entityID {
+				continue
+			}
+		}
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if entry.LocationID == nil || *entry.LocationID != locationID {
+				continue
+			}
+		}
+		if from, ok := filters["from"].(time.Time); ok {
+			if entry.CreatedAt.Before(from) {
+				continue
+			}
+		}
+		if to, ok := filters["to"].(time.Time); ok {
+			if !entry.CreatedAt.Before(to) {
+				continue
+			}
+		}
+		result = append(result, entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
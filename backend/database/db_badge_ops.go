@@ -0,0 +1,215 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBadgeNotInInventory = errors.New("badge is not in this location's inventory")
+	ErrBadgeUnavailable    = errors.New("badge is not available")
+	ErrBadgeNumberInUse    = errors.New("badge number already exists at this location")
+)
+
+// findBadgeLocked looks up a badge by number; callers must hold mu
+func findBadgeLocked(locationID uint, number string) *models.Badge {
+	number = models.NormalizeBadgeNumber(number)
+	for _, badge := range badges {
+		if badge.LocationID == locationID && badge.Number == number {
+			return badge
+		}
+	}
+	return nil
+}
+
+// issueBadgeLocked hands the visit's badge out if it opens the visit's area;
+// callers must hold mu
+func issueBadgeLocked(visit *models.Visit) error {
+	badge := findBadgeLocked(visit.LocationID, visit.BadgeNumber)
+	if badge == nil {
+		return ErrBadgeNotInInventory
+	}
+	if badge.Status != models.BadgeAvailable {
+		return ErrBadgeUnavailable
+	}
+	if err := areaAdmitsLocked(visit, badge); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	badge.Status = models.BadgeIssued
+	badge.VisitID = &visit.ID
+	badge.IssuedAt = &now
+	badge.LostAt = nil
+	badge.UpdatedAt = now
+
+	visit.BadgeID = &badge.ID
+	visit.BadgeNumber = badge.Number
+	return nil
+}
+
+// Badge operations
+func (db *MockDB) CreateBadge(badge *models.Badge) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	badge.Number = models.NormalizeBadgeNumber(badge.Number)
+	if findBadgeLocked(badge.LocationID, badge.Number) != nil {
+		return ErrBadgeNumberInUse
+	}
+
+	badge.ID = badgeID
+	badge.CreatedAt = time.Now()
+	if badge.Status == "" {
+		badge.Status = models.BadgeAvailable
+	}
+	badges[badgeID] = badge
+	badgeID++
+	return nil
+}
+
+// populateBadge fills in the location and the visit holding the badge
+func populateBadge(badge *models.Badge) *models.Badge {
+	b := *badge
+	if loc, exists := locations[b.LocationID]; exists {
+		b.Location = loc
+	}
+	b.Visit = nil
+	if b.VisitID != nil {
+		if visit, exists := visits[*b.VisitID]; exists {
+			b.Visit = visitCopy(visit, true)
+		}
+	}
+	return &b
+}
+
+func (db *MockDB) GetBadgeByID(id uint) (*models.Badge, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	badge, exists := badges[id]
+	if !exists {
+		return nil, errors.New("badge not found")
+	}
+	return populateBadge(badge), nil
+}
+
+func (db *MockDB) GetBadgeByNumber(locationID uint, number string) (*models.Badge, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	badge := findBadgeLocked(locationID, number)
+	if badge == nil {
+		return nil, errors.New("badge not found")
+	}
+	return populateBadge(badge), nil
+}
+
+// GetAllBadges filters by location_id, type and status; status may be a
+// single models.BadgeStatus or a slice of them
+func (db *MockDB) GetAllBadges(filters map[string]interface{}) []*models.Badge {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Badge, 0, len(badges))
+	for _, badge := range badges {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if badge.LocationID != locationID {
+				continue
+			}
+		}
+		if badgeType, ok := filters["type"].(string); ok {
+			if badge.Type != badgeType {
+				continue
+			}
+		}
+		if status, ok := filters["status"].(models.BadgeStatus); ok {
+			if badge.Status != status {
+				continue
+			}
+		}
+		if statuses, ok := filters["status"].([]models.BadgeStatus); ok {
+			matched := false
+			for _, status := range statuses {
+				if badge.Status == status {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		result = append(result, populateBadge(badge))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LocationID != result[j].LocationID {
+			return result[i].LocationID < result[j].LocationID
+		}
+		return result[i].Number < result[j].Number
+	})
+	return result
+}
+
+func (db *MockDB) UpdateBadge(badge *models.Badge) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := badges[badge.ID]; !exists {
+		return errors.New("badge not found")
+	}
+	stored := *badge
+	stored.Number = models.NormalizeBadgeNumber(stored.Number)
+	if existing := findBadgeLocked(stored.LocationID, stored.Number); existing != nil && existing.ID != stored.ID {
+		return ErrBadgeNumberInUse
+	}
+	stored.Location = nil
+	stored.Visit = nil
+	stored.UpdatedAt = time.Now()
+	badges[badge.ID] = &stored
+	return nil
+}
+
+func (db *MockDB) DeleteBadge(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := badges[id]; !exists {
+		return errors.New("badge not found")
+	}
+	delete(badges, id)
+	return nil
+}
+
+// ReleaseBadge returns a badge to inventory if it is still issued to the visit
+func (db *MockDB) ReleaseBadge(badgeID, visitID uint) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	badge, exists := badges[badgeID]
+	if !exists || badge.Status != models.BadgeIssued || badge.VisitID == nil || *badge.VisitID != visitID {
+		return
+	}
+	badge.Status = models.BadgeAvailable
+	badge.VisitID = nil
+	badge.IssuedAt = nil
+	badge.UpdatedAt = time.Now()
+}
+
+// detachBadgesLocked returns badges held by a deleted visit to inventory; callers must hold mu
+func detachBadgesLocked(visitID uint) {
+	for _, badge := range badges {
+		if badge.VisitID == nil || *badge.VisitID != visitID {
+			continue
+		}
+		if badge.Status == models.BadgeIssued {
+			badge.Status = models.BadgeAvailable
+			badge.IssuedAt = nil
+		}
+		badge.VisitID = nil
+		badge.UpdatedAt = time.Now()
+	}
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrDrillInProgress = errors.New("a drill is already in progress at this location")
+	ErrDrillEnded      = errors.New("drill has ended")
+)
+
+// drillCopy returns a copy of the drill that shares nothing with the stored one
+func drillCopy(drill *models.Drill) *models.Drill {
+	d := *drill
+	d.Marks = append([]models.DrillMark{}, drill.Marks...)
+	d.Unaccounted = append([]models.MusterPerson(nil), drill.Unaccounted...)
+	return &d
+}
+
+// Drill operations
+
+// CreateDrill starts a drill; only one can run per location at a time
+func (db *MockDB) CreateDrill(drill *models.Drill) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, existing := range drills {
+		if existing.LocationID == drill.LocationID && existing.IsActive() {
+			return ErrDrillInProgress
+		}
+	}
+
+	drill.ID = drillID
+	drill.StartedAt = time.Now()
+	drill.Marks = []models.DrillMark{}
+	drills[drillID] = drillCopy(drill)
+	drillID++
+	return nil
+}
+
+func (db *MockDB) GetDrillByID(id uint) (*models.Drill, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	drill, exists := drills[id]
+	if !exists {
+		return nil, errors.New("drill not found")
+	}
+	return drillCopy(drill), nil
+}
+
+// GetActiveDrill returns the drill running at a location
+func (db *MockDB) GetActiveDrill(locationID uint) (*models.Drill, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, drill := range drills {
+		if drill.LocationID == locationID && drill.IsActive() {
+			return drillCopy(drill), nil
+		}
+	}
+	return nil, errors.New("drill not found")
+}
+
+// GetAllDrills returns drills, newest first. Filters: location_id.
+func (db *MockDB) GetAllDrills(filters map[string]interface{}) []*models.Drill {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Drill, 0, len(drills))
+	for _, drill := range drills {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if drill.LocationID != locationID {
+				continue
+			}
+		}
+		result = append(result, drillCopy(drill))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.After(result[j].StartedAt)
+	})
+	return result
+}
+
+// MarkAccounted records or clears that a person was accounted for during a drill
+func (db *MockDB) MarkAccounted(drillID uint, mark models.DrillMark, accounted bool) (*models.Drill, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	drill, exists := drills[drillID]
+	if !exists {
+		return nil, errors.New("drill not found")
+	}
+	if !drill.IsActive() {
+		return nil, ErrDrillEnded
+	}
+
+	marks := make([]models.DrillMark, 0, len(drill.Marks)+1)
+	for _, existing := range drill.Marks {
+		if existing.Type == mark.Type && existing.RecordID == mark.RecordID {
+			// Keep the first time someone was accounted for
+			if accounted {
+				return drillCopy(drill), nil
+			}
+			continue
+		}
+		marks = append(marks, existing)
+	}
+	if accounted {
+		marks = append(marks, mark)
+	}
+	drill.Marks = marks
+	return drillCopy(drill), nil
+}
+
+// EndDrill closes a drill, keeping the final count and who was still missing
+func (db *MockDB) EndDrill(drillID, userID uint, total int, unaccounted []models.MusterPerson) (*models.Drill, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	drill, exists := drills[drillID]
+	if !exists {
+		return nil, errors.New("drill not found")
+	}
+	if !drill.IsActive() {
+		return nil, ErrDrillEnded
+	}
+
+	now := time.Now()
+	drill.EndedAt = &now
+	drill.EndedBy = &userID
+	drill.Total = total
+	drill.Unaccounted = append([]models.MusterPerson{}, unaccounted...)
+	return drillCopy(drill), nil
+}
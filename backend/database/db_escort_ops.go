@@ -0,0 +1,135 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrVisitNotActive = errors.New("visit is not signed in")
+	ErrSameEscort     = errors.New("escort is already assigned to the visit")
+)
+
+// endEscortLocked ends the visit's current escort assignment at the given
+// time; callers must hold mu
+func endEscortLocked(visitID uint, at time.Time) {
+	for _, assignment := range escortAssignments {
+		if assignment.VisitID == visitID && assignment.IsCurrent() {
+			ended := at
+			assignment.EndedAt = &ended
+		}
+	}
+}
+
+// dropEscortsLocked deletes the visit's escort assignments; callers must hold mu
+func dropEscortsLocked(visitID uint) {
+	for id, assignment := range escortAssignments {
+		if assignment.VisitID == visitID {
+			delete(escortAssignments, id)
+		}
+	}
+}
+
+// Escort operations
+
+// CreateEscortAssignment records the escort a visitor was signed in with
+func (db *MockDB) CreateEscortAssignment(assignment *models.EscortAssignment) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	visit, exists := visits[assignment.VisitID]
+	if !exists {
+		return errors.New("visit not found")
+	}
+	assignment.ID = escortAssignmentID
+	assignment.LocationID = visit.LocationID
+	stored := *assignment
+	stored.Visit = nil
+	escortAssignments[escortAssignmentID] = &stored
+	escortAssignmentID++
+	return nil
+}
+
+// HandOverEscort ends the visit's current escort assignment and starts one
+// for the new escort in the same step, updating the visit's escort
+func (db *MockDB) HandOverEscort(assignment *models.EscortAssignment) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	visit, exists := visits[assignment.VisitID]
+	if !exists {
+		return errors.New("visit not found")
+	}
+	if !visit.IsActive() {
+		return ErrVisitNotActive
+	}
+	if visit.EscortID != nil && *visit.EscortID == assignment.EscortID {
+		return ErrSameEscort
+	}
+
+	endEscortLocked(visit.ID, assignment.StartedAt)
+	assignment.ID = escortAssignmentID
+	assignment.LocationID = visit.LocationID
+	stored := *assignment
+	stored.Visit = nil
+	escortAssignments[escortAssignmentID] = &stored
+	escortAssignmentID++
+
+	escortID := assignment.EscortID
+	visit.EscortID = &escortID
+	visit.EscortName = assignment.EscortName
+	visit.UpdatedAt = assignment.StartedAt
+	return nil
+}
+
+// EndEscort ends the visit's current escort assignment, at sign-out
+func (db *MockDB) EndEscort(visitID uint, at time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	endEscortLocked(visitID, at)
+}
+
+// GetEscortAssignments returns assignments, oldest first, each with its visit
+// and visitor. Filters: visit_id, escort_id, location_id, current (only
+// assignments not ended) and at (assignments covering that time).
+func (db *MockDB) GetEscortAssignments(filters map[string]interface{}) []*models.EscortAssignment {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.EscortAssignment, 0)
+	for _, assignment := range escortAssignments {
+		if visitID, ok := filters["visit_id"].(uint); ok && assignment.VisitID != visitID {
+			continue
+		}
+		if escortID, ok := filters["escort_id"].(uint); ok && assignment.EscortID != escortID {
+			continue
+		}
+		if locationID, ok := filters["location_id"].(uint); ok && assignment.LocationID != locationID {
+			continue
+		}
+		if current, ok := filters["current"].(bool); ok && assignment.IsCurrent() != current {
+			continue
+		}
+		if at, ok := filters["at"].(time.Time); ok && !assignment.Covers(at) {
+			continue
+		}
+
+		a := *assignment
+		a.Visit = nil
+		if visit, exists := visits[a.VisitID]; exists {
+			a.Visit = visitCopy(visit, true)
+			a.Visit.Items = nil
+		}
+		result = append(result, &a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].StartedAt.Equal(result[j].StartedAt) {
+			return result[i].StartedAt.Before(result[j].StartedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+// groupCopy returns a copy of the group with its lead, location and member visits populated
+func groupCopy(group *models.VisitGroup) *models.VisitGroup {
+	g := *group
+	g.LeadVisitor = nil
+	if loc, exists := locations[g.LocationID]; exists {
+		g.Location = loc
+	}
+	if lead, exists := visitors[g.LeadVisitorID]; exists {
+		profile := *lead
+		profile.CurrentVisit = nil
+		g.LeadVisitor = &profile
+	}
+
+	g.Members = make([]*models.Visit, 0)
+	g.OnSite = 0
+	for _, visit := range visits {
+		if visit.GroupID == nil || *visit.GroupID != g.ID {
+			continue
+		}
+		member := visitCopy(visit, true)
+		member.Location = nil
+		g.Members = append(g.Members, member)
+		if visit.IsActive() {
+			g.OnSite++
+		}
+	}
+	sort.Slice(g.Members, func(i, j int) bool {
+		return g.Members[i].ID < g.Members[j].ID
+	})
+	return &g
+}
+
+// Visit group operations
+
+// CreateVisitGroup registers a group and signs in all of its members in one
+// step: new visitor profiles are created and every badge and parking bay is
+// assigned, or nothing is stored at all. groupVisits[i] belongs to members[i];
+// members with a zero ID are new profiles, and lead is the index of the
+// group's lead.
+func (db *MockDB) CreateVisitGroup(group *models.VisitGroup, members []*models.Visitor, groupVisits []*models.Visit, lead int) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(members) != len(groupVisits) || lead < 0 || lead >= len(members) {
+		return errors.New("invalid group")
+	}
+
+	// Check everything before changing anything
+	if group.AreaID != nil {
+		if err := areaHasRoomLocked(*group.AreaID, len(groupVisits)); err != nil {
+			return err
+		}
+	}
+	issued := make(map[string]bool)
+	parked := make(map[string]bool)
+	for i, visit := range groupVisits {
+		if members[i].ID != 0 {
+			if _, exists := visitors[members[i].ID]; !exists {
+				return errors.New("visitor not found")
+			}
+		}
+		visit.VisitorID = members[i].ID
+		if err := checkTransitionLocked(nil, visit); err != nil {
+			return err
+		}
+		if visit.ParkingBay != "" {
+			bay, err := availableBayLocked(group.LocationID, visit.ParkingBay)
+			if err != nil {
+				return err
+			}
+			if parked[bay.Number] {
+				return ErrBayUnavailable
+			}
+			parked[bay.Number] = true
+		}
+		if visit.BadgeNumber == "" {
+			continue
+		}
+		badge := findBadgeLocked(group.LocationID, visit.BadgeNumber)
+		if badge == nil {
+			return ErrBadgeNotInInventory
+		}
+		if badge.Status != models.BadgeAvailable || issued[badge.Number] {
+			return ErrBadgeUnavailable
+		}
+		if err := areaAdmitsLocked(visit, badge); err != nil {
+			return err
+		}
+		issued[badge.Number] = true
+	}
+
+	now := time.Now()
+	for _, visitor := range members {
+		if visitor.ID != 0 {
+			continue
+		}
+		visitor.ID = visitorID
+		visitor.CreatedAt = now
+		visitors[visitorID] = visitor
+		visitorID++
+	}
+
+	group.ID = groupID
+	group.LeadVisitorID = members[lead].ID
+	group.CreatedAt = now
+	groupID++
+
+	for i, visit := range groupVisits {
+		visit.ID = visitID
+		visit.VisitorID = members[i].ID
+		visit.LocationID = group.LocationID
+		visit.GroupID = &group.ID
+		if visit.BadgeNumber != "" {
+			issueBadgeLocked(visit)
+		}
+		if visit.ParkingBay != "" {
+			assignBayLocked(visit)
+		}
+		visit.CreatedAt = now
+		storeDeclaredItemsLocked(visit)
+		stored := *visit
+		stored.Visitor = nil
+		stored.Location = nil
+		stored.Host = nil
+		stored.Items = nil
+		visits[visitID] = &stored
+		visitID++
+		recordStatusChangeLocked(&stored, "")
+	}
+
+	stored := *group
+	stored.LeadVisitor = nil
+	stored.Location = nil
+	stored.Members = nil
+	groups[group.ID] = &stored
+	return nil
+}
+
+func (db *MockDB) GetVisitGroupByID(id uint) (*models.VisitGroup, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	group, exists := groups[id]
+	if !exists {
+		return nil, errors.New("visit group not found")
+	}
+	return groupCopy(group), nil
+}
+
+// GetAllVisitGroups returns groups, newest first. Filters: location_id, on_site
+// (whether any member is still signed in), and from/to bounding the creation time.
+func (db *MockDB) GetAllVisitGroups(filters map[string]interface{}) []*models.VisitGroup {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.VisitGroup, 0, len(groups))
+	for _, group := range groups {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if group.LocationID != locationID {
+				continue
+			}
+		}
+		if from, ok := filters["from"].(time.Time); ok {
+			if group.CreatedAt.Before(from) {
+				continue
+			}
+		}
+		if to, ok := filters["to"].(time.Time); ok {
+			if !group.CreatedAt.Before(to) {
+				continue
+			}
+		}
+		g := groupCopy(group)
+		if onSite, ok := filters["on_site"].(bool); ok {
+			if (g.OnSite > 0) != onSite {
+				continue
+			}
+		}
+		result = append(result, g)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
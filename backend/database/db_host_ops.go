@@ -0,0 +1,108 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Host directory operations
+func (db *MockDB) CreateHost(host *models.Host) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	host.ID = hostID
+	host.CreatedAt = time.Now()
+	stored := *host
+	stored.Location = nil
+	hosts[hostID] = &stored
+	hostID++
+	return nil
+}
+
+// hostCopy returns a copy of the host with its location populated
+func hostCopy(host *models.Host) *models.Host {
+	h := *host
+	if loc, exists := locations[h.LocationID]; exists {
+		h.Location = loc
+	}
+	return &h
+}
+
+func (db *MockDB) GetHostByID(id uint) (*models.Host, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	host, exists := hosts[id]
+	if !exists {
+		return nil, errors.New("host not found")
+	}
+	return hostCopy(host), nil
+}
+
+// GetAllHosts returns hosts sorted by name. Filters: location_id, department,
+// active, and q matching name, department or email.
+func (db *MockDB) GetAllHosts(filters map[string]interface{}) []*models.Host {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Host, 0, len(hosts))
+	for _, host := range hosts {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if host.LocationID != locationID {
+				continue
+			}
+		}
+		if department, ok := filters["department"].(string); ok {
+			if !strings.EqualFold(host.Department, department) {
+				continue
+			}
+		}
+		if active, ok := filters["active"].(bool); ok {
+			if host.Active != active {
+				continue
+			}
+		}
+		if q, ok := filters["q"].(string); ok {
+			q = strings.ToLower(q)
+			if !strings.Contains(strings.ToLower(host.Name), q) &&
+				!strings.Contains(strings.ToLower(host.Department), q) &&
+				!strings.Contains(strings.ToLower(host.Email), q) {
+				continue
+			}
+		}
+		result = append(result, hostCopy(host))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (db *MockDB) UpdateHost(host *models.Host) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := hosts[host.ID]; !exists {
+		return errors.New("host not found")
+	}
+	host.UpdatedAt = time.Now()
+	stored := *host
+	stored.Location = nil
+	hosts[host.ID] = &stored
+	return nil
+}
+
+func (db *MockDB) DeleteHost(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := hosts[id]; !exists {
+		return errors.New("host not found")
+	}
+	delete(hosts, id)
+	return nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+// dropVisitImagesLocked deletes the visit's image records and queues their
+// blobs for removal by whoever holds the blob store; callers must hold mu
+func dropVisitImagesLocked(visitID uint) {
+	for id, img := range visitImages {
+		if img.VisitID != visitID {
+			continue
+		}
+		orphanedBlobs = append(orphanedBlobs, img.BlobKey)
+		if img.ThumbnailKey != "" {
+			orphanedBlobs = append(orphanedBlobs, img.ThumbnailKey)
+		}
+		delete(visitImages, id)
+	}
+}
+
+// Visit image operations
+
+// CreateVisitImage records an uploaded image, failing if the visit was deleted meanwhile
+func (db *MockDB) CreateVisitImage(img *models.VisitImage) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := visits[img.VisitID]; !exists {
+		return errors.New("visit not found")
+	}
+	img.ID = visitImageID
+	img.CreatedAt = time.Now()
+	stored := *img
+	visitImages[visitImageID] = &stored
+	visitImageID++
+	return nil
+}
+
+func (db *MockDB) GetVisitImageByID(id uint) (*models.VisitImage, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	img, exists := visitImages[id]
+	if !exists {
+		return nil, errors.New("visit image not found")
+	}
+	i := *img
+	return &i, nil
+}
+
+// GetVisitImages returns the visit's images, oldest first
+func (db *MockDB) GetVisitImages(visitID uint) []*models.VisitImage {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.VisitImage, 0)
+	for _, img := range visitImages {
+		if img.VisitID == visitID {
+			i := *img
+			result = append(result, &i)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// DeleteVisitImage deletes the record and queues its blobs for removal
+func (db *MockDB) DeleteVisitImage(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	img, exists := visitImages[id]
+	if !exists {
+		return errors.New("visit image not found")
+	}
+	orphanedBlobs = append(orphanedBlobs, img.BlobKey)
+	if img.ThumbnailKey != "" {
+		orphanedBlobs = append(orphanedBlobs, img.ThumbnailKey)
+	}
+	delete(visitImages, id)
+	return nil
+}
+
+// TakeOrphanedBlobs returns the keys of blobs no record refers to any more
+// and clears the queue
+func (db *MockDB) TakeOrphanedBlobs() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	keys := orphanedBlobs
+	orphanedBlobs = nil
+	return keys
+}
+
+// RequeueOrphanedBlobs puts back keys whose blobs could not be removed
+func (db *MockDB) RequeueOrphanedBlobs(keys []string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	orphanedBlobs = append(orphanedBlobs, keys...)
+}
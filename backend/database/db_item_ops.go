@@ -0,0 +1,127 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+// storeDeclaredItemsLocked saves the items declared with a visit being
+// signed in; callers must hold mu and have assigned the visit's ID
+func storeDeclaredItemsLocked(visit *models.Visit) {
+	now := time.Now()
+	for _, item := range visit.Items {
+		item.ID = declaredItemID
+		item.VisitID = visit.ID
+		item.LocationID = visit.LocationID
+		item.Status = models.ItemDeclared
+		item.CreatedAt = now
+		stored := *item
+		stored.Visit = nil
+		declaredItems[declaredItemID] = &stored
+		declaredItemID++
+	}
+}
+
+// visitItemsLocked returns copies of the visit's items, oldest first; callers must hold mu
+func visitItemsLocked(visitID uint) []*models.DeclaredItem {
+	var items []*models.DeclaredItem
+	for _, item := range declaredItems {
+		if item.VisitID == visitID {
+			i := *item
+			items = append(items, &i)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// dropDeclaredItemsLocked deletes the visit's items; callers must hold mu
+func dropDeclaredItemsLocked(visitID uint) {
+	for id, item := range declaredItems {
+		if item.VisitID == visitID {
+			delete(declaredItems, id)
+		}
+	}
+}
+
+// Declared item operations
+
+// checkItemsOutLocked saves the exit check of a visit's declared items and
+// records the undeclared items leaving with the visitor; callers must hold mu
+func checkItemsOutLocked(visit *models.Visit, checked []*models.DeclaredItem, undeclared []*models.DeclaredItem) error {
+	for _, item := range checked {
+		if stored, exists := declaredItems[item.ID]; !exists || stored.VisitID != visit.ID {
+			return errors.New("declared item not found")
+		}
+	}
+
+	for _, item := range checked {
+		stored := *item
+		stored.Visit = nil
+		declaredItems[item.ID] = &stored
+	}
+	now := time.Now()
+	for _, item := range undeclared {
+		item.ID = declaredItemID
+		item.VisitID = visit.ID
+		item.LocationID = visit.LocationID
+		item.Status = models.ItemUndeclared
+		item.CreatedAt = now
+		stored := *item
+		stored.Visit = nil
+		declaredItems[declaredItemID] = &stored
+		declaredItemID++
+	}
+	return nil
+}
+
+// GetDeclaredItems returns items with their visit, newest first. Filters:
+// location_id, visit_id, status, serial_number, outstanding (declared in
+// but not checked out, or found missing) and on_site (whether the visit is
+// still open).
+func (db *MockDB) GetDeclaredItems(filters map[string]interface{}) []*models.DeclaredItem {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.DeclaredItem, 0)
+	for _, item := range declaredItems {
+		visit, exists := visits[item.VisitID]
+		if !exists {
+			continue
+		}
+		if locationID, ok := filters["location_id"].(uint); ok && item.LocationID != locationID {
+			continue
+		}
+		if visitID, ok := filters["visit_id"].(uint); ok && item.VisitID != visitID {
+			continue
+		}
+		if status, ok := filters["status"].(models.ItemStatus); ok && item.Status != status {
+			continue
+		}
+		if serial, ok := filters["serial_number"].(string); ok && models.NormalizeIDNumber(item.SerialNumber) != models.NormalizeIDNumber(serial) {
+			continue
+		}
+		if outstanding, ok := filters["outstanding"].(bool); ok {
+			isOutstanding := item.Status == models.ItemDeclared || item.Status == models.ItemMissing
+			if isOutstanding != outstanding {
+				continue
+			}
+		}
+		if onSite, ok := filters["on_site"].(bool); ok && visit.IsActive() != onSite {
+			continue
+		}
+
+		i := *item
+		i.Visit = visitCopy(visit, true)
+		i.Visit.Items = nil
+		result = append(result, &i)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID > result[j].ID
+	})
+	return result
+}
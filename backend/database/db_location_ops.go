@@ -3,6 +3,7 @@ package database
 import (
 	"digital-logbook/models"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -61,3 +62,15 @@ func (db *MockDB) DeleteLocation(id uint) error {
 	delete(locations, id)
 	return nil
 }
+
+func (db *MockDB) GetLocationByCode(code string) (*models.Location, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, loc := range locations {
+		if strings.EqualFold(loc.Code, code) {
+			return loc, nil
+		}
+	}
+	return nil, errors.New("location not found")
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBayNotInInventory = errors.New("parking bay is not in this location's inventory")
+	ErrBayUnavailable    = errors.New("parking bay is occupied or out of service")
+	ErrBayNumberInUse    = errors.New("parking bay number already exists at this location")
+)
+
+// findBayLocked looks up a parking bay by number; callers must hold mu
+func findBayLocked(locationID uint, number string) *models.ParkingBay {
+	number = models.NormalizeBayNumber(number)
+	for _, bay := range parkingBays {
+		if bay.LocationID == locationID && bay.Number == number {
+			return bay
+		}
+	}
+	return nil
+}
+
+// availableBayLocked returns the bay if a vehicle can be parked in it; callers must hold mu
+func availableBayLocked(locationID uint, number string) (*models.ParkingBay, error) {
+	bay := findBayLocked(locationID, number)
+	if bay == nil {
+		return nil, ErrBayNotInInventory
+	}
+	if bay.Status != models.BayAvailable {
+		return nil, ErrBayUnavailable
+	}
+	return bay, nil
+}
+
+// assignBayLocked parks the visit's vehicle in its bay; callers must hold mu
+func assignBayLocked(visit *models.Visit) error {
+	bay, err := availableBayLocked(visit.LocationID, visit.ParkingBay)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	bay.Status = models.BayOccupied
+	bay.VisitID = &visit.ID
+	bay.OccupiedAt = &now
+	bay.UpdatedAt = now
+
+	visit.ParkingBayID = &bay.ID
+	visit.ParkingBay = bay.Number
+	return nil
+}
+
+// releaseBayLocked frees the bay if the visit's vehicle still holds it; callers must hold mu
+func releaseBayLocked(bayID, visitID uint) {
+	bay, exists := parkingBays[bayID]
+	if !exists || bay.Status != models.BayOccupied || bay.VisitID == nil || *bay.VisitID != visitID {
+		return
+	}
+	bay.Status = models.BayAvailable
+	bay.VisitID = nil
+	bay.OccupiedAt = nil
+	bay.UpdatedAt = time.Now()
+}
+
+// detachBaysLocked frees bays held by a deleted visit; callers must hold mu
+func detachBaysLocked(visitID uint) {
+	for _, bay := range parkingBays {
+		if bay.VisitID != nil && *bay.VisitID == visitID {
+			releaseBayLocked(bay.ID, visitID)
+		}
+	}
+}
+
+// Parking bay operations
+func (db *MockDB) CreateParkingBay(bay *models.ParkingBay) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	bay.Number = models.NormalizeBayNumber(bay.Number)
+	if findBayLocked(bay.LocationID, bay.Number) != nil {
+		return ErrBayNumberInUse
+	}
+
+	now := time.Now()
+	bay.ID = parkingBayID
+	bay.CreatedAt = now
+	bay.UpdatedAt = now
+	if bay.Status == "" {
+		bay.Status = models.BayAvailable
+	}
+	stored := *bay
+	stored.Location = nil
+	parkingBays[parkingBayID] = &stored
+	parkingBayID++
+	return nil
+}
+
+// populateBay fills in the location and the visit parked in the bay
+func populateBay(bay *models.ParkingBay) *models.ParkingBay {
+	b := *bay
+	b.Location = nil
+	if loc, exists := locations[b.LocationID]; exists {
+		b.Location = loc
+	}
+	b.Visit = nil
+	if b.VisitID != nil {
+		if visit, exists := visits[*b.VisitID]; exists {
+			b.Visit = visitCopy(visit, true)
+			b.Visit.Items = nil
+		}
+	}
+	return &b
+}
+
+func (db *MockDB) GetParkingBayByID(id uint) (*models.ParkingBay, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	bay, exists := parkingBays[id]
+	if !exists {
+		return nil, errors.New("parking bay not found")
+	}
+	return populateBay(bay), nil
+}
+
+func (db *MockDB) GetParkingBayByNumber(locationID uint, number string) (*models.ParkingBay, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	bay := findBayLocked(locationID, number)
+	if bay == nil {
+		return nil, errors.New("parking bay not found")
+	}
+	return populateBay(bay), nil
+}
+
+// GetAllParkingBays filters by location_id, zone and status
+func (db *MockDB) GetAllParkingBays(filters map[string]interface{}) []*models.ParkingBay {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.ParkingBay, 0, len(parkingBays))
+	for _, bay := range parkingBays {
+		if locationID, ok := filters["location_id"].(uint); ok && bay.LocationID != locationID {
+			continue
+		}
+		if zone, ok := filters["zone"].(string); ok && bay.Zone != zone {
+			continue
+		}
+		if status, ok := filters["status"].(models.ParkingBayStatus); ok && bay.Status != status {
+			continue
+		}
+		result = append(result, populateBay(bay))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LocationID != result[j].LocationID {
+			return result[i].LocationID < result[j].LocationID
+		}
+		return result[i].Number < result[j].Number
+	})
+	return result
+}
+
+// GetParkingOccupancy counts bays by status for each location, or only the
+// location in the location_id filter
+func (db *MockDB) GetParkingOccupancy(filters map[string]interface{}) []*models.ParkingOccupancy {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	byLocation := make(map[uint]*models.ParkingOccupancy)
+	for _, bay := range parkingBays {
+		if locationID, ok := filters["location_id"].(uint); ok && bay.LocationID != locationID {
+			continue
+		}
+		occupancy, exists := byLocation[bay.LocationID]
+		if !exists {
+			occupancy = &models.ParkingOccupancy{LocationID: bay.LocationID}
+			if loc, exists := locations[bay.LocationID]; exists {
+				occupancy.LocationCode = loc.Code
+			}
+			byLocation[bay.LocationID] = occupancy
+		}
+		occupancy.Total++
+		switch bay.Status {
+		case models.BayAvailable:
+			occupancy.Available++
+		case models.BayOccupied:
+			occupancy.Occupied++
+		case models.BayOutOfService:
+			occupancy.OutOfService++
+		}
+	}
+
+	result := make([]*models.ParkingOccupancy, 0, len(byLocation))
+	for _, occupancy := range byLocation {
+		if inService := occupancy.Total - occupancy.OutOfService; inService > 0 {
+			occupancy.Rate = float64(occupancy.Occupied) / float64(inService)
+		}
+		result = append(result, occupancy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LocationID < result[j].LocationID
+	})
+	return result
+}
+
+func (db *MockDB) UpdateParkingBay(bay *models.ParkingBay) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := parkingBays[bay.ID]; !exists {
+		return errors.New("parking bay not found")
+	}
+	stored := *bay
+	stored.Number = models.NormalizeBayNumber(stored.Number)
+	if existing := findBayLocked(stored.LocationID, stored.Number); existing != nil && existing.ID != stored.ID {
+		return ErrBayNumberInUse
+	}
+	stored.Location = nil
+	stored.Visit = nil
+	stored.UpdatedAt = time.Now()
+	parkingBays[bay.ID] = &stored
+	bay.Number = stored.Number
+	bay.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (db *MockDB) DeleteParkingBay(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := parkingBays[id]; !exists {
+		return errors.New("parking bay not found")
+	}
+	delete(parkingBays, id)
+	return nil
+}
+
+// ReleaseParkingBay frees a bay if the visit's vehicle still holds it
+func (db *MockDB) ReleaseParkingBay(bayID, visitID uint) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	releaseBayLocked(bayID, visitID)
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"time"
+)
+
+// Service account operations
+func (db *MockDB) CreateServiceAccount(sa *models.ServiceAccount) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, existing := range serviceAccounts {
+		if existing.Name == sa.Name {
+			return errors.New("service account with this name already exists")
+		}
+	}
+
+	sa.ID = serviceAccountID
+	sa.CreatedAt = time.Now()
+	serviceAccounts[serviceAccountID] = sa
+	serviceAccountID++
+	return nil
+}
+
+func (db *MockDB) GetServiceAccountByID(id uint) (*models.ServiceAccount, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	sa, exists := serviceAccounts[id]
+	if !exists {
+		return nil, errors.New("service account not found")
+	}
+	return sa, nil
+}
+
+func (db *MockDB) GetAllServiceAccounts() []*models.ServiceAccount {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.ServiceAccount, 0, len(serviceAccounts))
+	for _, sa := range serviceAccounts {
+		result = append(result, sa)
+	}
+	return result
+}
+
+func (db *MockDB) UpdateServiceAccount(sa *models.ServiceAccount) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := serviceAccounts[sa.ID]; !exists {
+		return errors.New("service account not found")
+	}
+	sa.UpdatedAt = time.Now()
+	serviceAccounts[sa.ID] = sa
+	return nil
+}
+
+// DeleteServiceAccount removes the account together with all of its keys
+func (db *MockDB) DeleteServiceAccount(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := serviceAccounts[id]; !exists {
+		return errors.New("service account not found")
+	}
+	for keyID, key := range apiKeys {
+		if key.ServiceAccountID == id {
+			delete(apiKeys, keyID)
+		}
+	}
+	delete(serviceAccounts, id)
+	return nil
+}
+
+// API key operations
+func (db *MockDB) CreateAPIKey(key *models.APIKey) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := serviceAccounts[key.ServiceAccountID]; !exists {
+		return errors.New("service account not found")
+	}
+	for _, existing := range apiKeys {
+		if existing.Prefix == key.Prefix {
+			return errors.New("api key prefix collision")
+		}
+	}
+
+	key.ID = apiKeyID
+	key.CreatedAt = time.Now()
+	apiKeys[apiKeyID] = key
+	apiKeyID++
+	return nil
+}
+
+func (db *MockDB) GetAPIKeyByID(id uint) (*models.APIKey, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	key, exists := apiKeys[id]
+	if !exists {
+		return nil, errors.New("api key not found")
+	}
+	return key, nil
+}
+
+func (db *MockDB) GetAPIKeyByPrefix(prefix string) (*models.APIKey, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, key := range apiKeys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return nil, errors.New("api key not found")
+}
+
+func (db *MockDB) GetAPIKeysForServiceAccount(serviceAccountID uint) []*models.APIKey {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.APIKey, 0)
+	for _, key := range apiKeys {
+		if key.ServiceAccountID == serviceAccountID {
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+func (db *MockDB) RevokeAPIKey(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	key, exists := apiKeys[id]
+	if !exists {
+		return errors.New("api key not found")
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	return nil
+}
+
+// TouchAPIKey records the time and client address of the key's latest use
+func (db *MockDB) TouchAPIKey(id uint, ip string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if key, exists := apiKeys[id]; exists {
+		now := time.Now()
+		key.LastUsedAt = &now
+		key.LastUsedIP = ip
+	}
+}
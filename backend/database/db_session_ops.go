@@ -0,0 +1,96 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"time"
+)
+
+// Session operations
+func (db *MockDB) CreateSession(s *models.Session) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.ID = sessionID
+	sessions[sessionID] = s
+	sessionID++
+	return nil
+}
+
+func (db *MockDB) GetSessionByID(id uint) (*models.Session, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	s, exists := sessions[id]
+	if !exists {
+		return nil, errors.New("session not found")
+	}
+	return s, nil
+}
+
+func (db *MockDB) GetAllSessions(filters map[string]interface{}) []*models.Session {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.Session, 0, len(sessions))
+	for _, s := range sessions {
+		if userID, ok := filters["user_id"].(uint); ok {
+			if s.UserID != userID {
+				continue
+			}
+		}
+		if active, ok := filters["active"].(bool); ok {
+			if s.IsActive() != active {
+				continue
+			}
+		}
+		// Populate user data
+		if user, exists := users[s.UserID]; exists {
+			s.User = user
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// TouchSession records that the session was just used
+func (db *MockDB) TouchSession(id uint, ip string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if s, exists := sessions[id]; exists {
+		s.LastSeenAt = time.Now()
+		s.IPAddress = ip
+	}
+}
+
+// RevokeSession marks a session as revoked so its token is no longer accepted
+func (db *MockDB) RevokeSession(id uint, revokedBy uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, exists := sessions[id]
+	if !exists {
+		return errors.New("session not found")
+	}
+	if s.RevokedAt == nil {
+		now := time.Now()
+		s.RevokedAt = &now
+		s.RevokedBy = &revokedBy
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of a user
+func (db *MockDB) RevokeUserSessions(userID uint, revokedBy uint) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	for _, s := range sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+			s.RevokedBy = &revokedBy
+		}
+	}
+}
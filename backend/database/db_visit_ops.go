@@ -0,0 +1,407 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrVisitNotExpected is returned when signing in a pre-registration or kiosk
+	// submission that is no longer expected or pending
+	ErrVisitNotExpected = errors.New("visit is not an expected pre-registration or kiosk submission")
+	// ErrAlreadySignedIn is returned when signing in a visitor who has an open visit
+	ErrAlreadySignedIn = errors.New("visitor is already signed in")
+	// ErrAlreadyPending is returned when a visitor checks in at a kiosk twice
+	ErrAlreadyPending = errors.New("visitor is already waiting to be signed in")
+)
+
+// Visits and visitor profiles reference each other, so reads return copies
+// with one side populated to keep the stored records free of cycles.
+
+// visitCopy returns a copy of the visit with its location, host and, optionally, visitor populated
+func visitCopy(visit *models.Visit, withVisitor bool) *models.Visit {
+	v := *visit
+	v.Visitor = nil
+	v.Host = nil
+	v.Items = visitItemsLocked(v.ID)
+	if loc, exists := locations[v.LocationID]; exists {
+		v.Location = loc
+	}
+	if v.HostID != nil {
+		if host, exists := hosts[*v.HostID]; exists {
+			h := *host
+			v.Host = &h
+		}
+	}
+	if withVisitor {
+		if visitor, exists := visitors[v.VisitorID]; exists {
+			profile := *visitor
+			profile.CurrentVisit = nil
+			v.Visitor = &profile
+		}
+	}
+	return &v
+}
+
+// withCurrentVisit returns a copy of the visitor with their latest visit,
+// limited to one location when locationID is non-zero
+func withCurrentVisit(visitor *models.Visitor, locationID uint) *models.Visitor {
+	profile := *visitor
+	profile.CurrentVisit = nil
+
+	var latest *models.Visit
+	for _, visit := range visits {
+		if visit.VisitorID != visitor.ID {
+			continue
+		}
+		if locationID != 0 && visit.LocationID != locationID {
+			continue
+		}
+		if latest == nil || visit.SignInTime.After(latest.SignInTime) {
+			latest = visit
+		}
+	}
+	if latest != nil {
+		profile.CurrentVisit = visitCopy(latest, false)
+	}
+	return &profile
+}
+
+// Visit operations
+
+// CreateVisit records a visit and, in the same step, issues its badge from
+// the location's inventory so a badge can never be handed out twice, and
+// checks its area has room
+func (db *MockDB) CreateVisit(visit *models.Visit) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := visitors[visit.VisitorID]; !exists {
+		return errors.New("visitor not found")
+	}
+	if err := checkTransitionLocked(nil, visit); err != nil {
+		return err
+	}
+
+	visit.ID = visitID
+	if visit.AreaID != nil && visit.IsActive() {
+		if err := areaHasRoomLocked(*visit.AreaID, 1); err != nil {
+			return err
+		}
+	}
+	if visit.ParkingBay != "" {
+		if _, err := availableBayLocked(visit.LocationID, visit.ParkingBay); err != nil {
+			return err
+		}
+	}
+	if visit.BadgeNumber != "" {
+		if err := issueBadgeLocked(visit); err != nil {
+			return err
+		}
+	}
+	if visit.ParkingBay != "" {
+		assignBayLocked(visit)
+	}
+	visit.CreatedAt = time.Now()
+	storeDeclaredItemsLocked(visit)
+	stored := *visit
+	stored.Visitor = nil
+	stored.Location = nil
+	stored.Host = nil
+	stored.Items = nil
+	visits[visitID] = &stored
+	visitID++
+	recordStatusChangeLocked(&stored, "")
+	return nil
+}
+
+// StartExpectedVisit signs in a pre-registered visit, or one submitted at a
+// kiosk, in place, issuing its badge in the same step. It fails if the visit
+// was cancelled or converted meanwhile, for instance by the lapse job.
+func (db *MockDB) StartExpectedVisit(visit *models.Visit) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stored, exists := visits[visit.ID]
+	if !exists {
+		return errors.New("visit not found")
+	}
+	if !stored.IsExpected() && !stored.IsPending() {
+		return ErrVisitNotExpected
+	}
+	if err := checkTransitionLocked(stored, visit); err != nil {
+		return err
+	}
+
+	if visit.AreaID != nil && visit.IsActive() {
+		if err := areaHasRoomLocked(*visit.AreaID, 1); err != nil {
+			return err
+		}
+	}
+	if visit.ParkingBay != "" {
+		if _, err := availableBayLocked(visit.LocationID, visit.ParkingBay); err != nil {
+			return err
+		}
+	}
+	if visit.BadgeNumber != "" {
+		if err := issueBadgeLocked(visit); err != nil {
+			return err
+		}
+	}
+	if visit.ParkingBay != "" {
+		assignBayLocked(visit)
+	}
+	storeDeclaredItemsLocked(visit)
+	updated := *visit
+	updated.Visitor = nil
+	updated.Location = nil
+	updated.Host = nil
+	updated.Items = nil
+	updated.UpdatedAt = time.Now()
+	visits[visit.ID] = &updated
+	visit.UpdatedAt = updated.UpdatedAt
+	recordStatusChangeLocked(&updated, stored.Status)
+	return nil
+}
+
+func (db *MockDB) GetVisitByID(id uint) (*models.Visit, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	visit, exists := visits[id]
+	if !exists {
+		return nil, errors.New("visit not found")
+	}
+	return visitCopy(visit, true), nil
+}
+
+// GetOpenVisit returns the visitor's visit that has not been signed out yet
+func (db *MockDB) GetOpenVisit(visitorID uint) (*models.Visit, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, visit := range visits {
+		if visit.VisitorID == visitorID && visit.IsActive() {
+			return visitCopy(visit, true), nil
+		}
+	}
+	return nil, errors.New("visit not found")
+}
+
+// GetAllVisits returns visits, newest first. Filters: visitor_id, location_id,
+// status, overdue, area_id, vehicle_registration (ignoring spaces and dashes), and
+// from/to bounding the sign-in time.
+func (db *MockDB) GetAllVisits(filters map[string]interface{}) []*models.Visit {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	now := time.Now()
+	result := make([]*models.Visit, 0, len(visits))
+	for _, visit := range visits {
+		if visitorID, ok := filters["visitor_id"].(uint); ok {
+			if visit.VisitorID != visitorID {
+				continue
+			}
+		}
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if visit.LocationID != locationID {
+				continue
+			}
+		}
+		if status, ok := filters["status"].(string); ok {
+			if string(visit.Status) != status {
+				continue
+			}
+		}
+		if overdue, ok := filters["overdue"].(bool); ok {
+			if visit.IsOverdue(now) != overdue {
+				continue
+			}
+		}
+		if areaID, ok := filters["area_id"].(uint); ok {
+			if visit.AreaID == nil || *visit.AreaID != areaID {
+				continue
+			}
+		}
+		if registration, ok := filters["vehicle_registration"].(string); ok {
+			if models.NormalizeIDNumber(visit.VehicleRegistration) != models.NormalizeIDNumber(registration) {
+				continue
+			}
+		}
+		if from, ok := filters["from"].(time.Time); ok {
+			if visit.SignInTime.Before(from) {
+				continue
+			}
+		}
+		if to, ok := filters["to"].(time.Time); ok {
+			if !visit.SignInTime.Before(to) {
+				continue
+			}
+		}
+		result = append(result, visitCopy(visit, true))
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SignInTime.After(result[j].SignInTime)
+	})
+	return result
+}
+
+// UpdateVisit stores changes to a visit. A status change must be allowed by
+// the transition table from the stored status, so a visit changed meanwhile,
+// for instance signed out twice, is refused with a *models.TransitionError.
+func (db *MockDB) UpdateVisit(visit *models.Visit) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	previous, err := visitForUpdateLocked(visit)
+	if err != nil {
+		return err
+	}
+	storeVisitLocked(visit, previous)
+	return nil
+}
+
+// CloseVisit saves a visit being signed out together with the exit check of
+// its declared items, so neither is saved if the other is refused
+func (db *MockDB) CloseVisit(visit *models.Visit, checked []*models.DeclaredItem, undeclared []*models.DeclaredItem) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	previous, err := visitForUpdateLocked(visit)
+	if err != nil {
+		return err
+	}
+	if err := checkItemsOutLocked(visit, checked, undeclared); err != nil {
+		return err
+	}
+	storeVisitLocked(visit, previous)
+	return nil
+}
+
+// visitForUpdateLocked returns the stored visit if the change to visit is
+// allowed; callers must hold mu
+func visitForUpdateLocked(visit *models.Visit) (*models.Visit, error) {
+	previous, exists := visits[visit.ID]
+	if !exists {
+		return nil, errors.New("visit not found")
+	}
+	if err := checkTransitionLocked(previous, visit); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// storeVisitLocked saves the visit and records its status change; callers must hold mu
+func storeVisitLocked(visit, previous *models.Visit) {
+	stored := *visit
+	stored.Visitor = nil
+	stored.Location = nil
+	stored.Host = nil
+	stored.Items = nil
+	stored.UpdatedAt = time.Now()
+	visits[visit.ID] = &stored
+	visit.UpdatedAt = stored.UpdatedAt
+	recordStatusChangeLocked(&stored, previous.Status)
+}
+
+func (db *MockDB) DeleteVisit(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := visits[id]; !exists {
+		return errors.New("visit not found")
+	}
+	delete(visits, id)
+	detachBadgesLocked(id)
+	detachBaysLocked(id)
+	dropVisitImagesLocked(id)
+	dropDeclaredItemsLocked(id)
+	dropEscortsLocked(id)
+	dropStatusChangesLocked(id)
+	return nil
+}
+
+// PurgeVisits deletes visits that ended before the cutoff, with their images,
+// under the retention policy. Visits still open, expected or pending are kept,
+// and so are visitor profiles. It returns the number of visits deleted.
+func (db *MockDB) PurgeVisits(before time.Time) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	purged := 0
+	for id, visit := range visits {
+		if visit.IsActive() || visit.IsExpected() || visit.IsPending() {
+			continue
+		}
+		ended := visit.UpdatedAt
+		if visit.SignOutTime != nil {
+			ended = *visit.SignOutTime
+		}
+		if !ended.Before(before) {
+			continue
+		}
+		delete(visits, id)
+		detachBadgesLocked(id)
+		detachBaysLocked(id)
+		dropVisitImagesLocked(id)
+		dropDeclaredItemsLocked(id)
+		dropEscortsLocked(id)
+		dropStatusChangesLocked(id)
+		purged++
+	}
+
+	// Drop groups with no visits left
+	for id := range groups {
+		empty := true
+		for _, visit := range visits {
+			if visit.GroupID != nil && *visit.GroupID == id {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			delete(groups, id)
+		}
+	}
+	return purged
+}
+
+// MarkOverdueVisits flags open visits that have passed their expected departure
+// and returns the newly flagged ones, so each visit is only reported once
+func (db *MockDB) MarkOverdueVisits(now time.Time) []*models.Visit {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var flagged []*models.Visit
+	for _, visit := range visits {
+		if visit.OverdueAt != nil || !visit.IsOverdue(now) {
+			continue
+		}
+		visit.OverdueAt = &now
+		visit.UpdatedAt = now
+		flagged = append(flagged, visitCopy(visit, true))
+	}
+	return flagged
+}
+
+// LapseExpectedVisits cancels pre-registrations whose arrival window has
+// passed and returns them
+func (db *MockDB) LapseExpectedVisits(now time.Time) []*models.Visit {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var lapsed []*models.Visit
+	for _, visit := range visits {
+		if !visit.IsExpected() || visit.ExpectedUntil == nil || !now.After(*visit.ExpectedUntil) {
+			continue
+		}
+		visit.Cancel(models.CancelReasonLapsed, now, nil)
+		visit.UpdatedAt = now
+		recordStatusChangeLocked(visit, models.StatusExpected)
+		lapsed = append(lapsed, visitCopy(visit, true))
+	}
+	return lapsed
+}
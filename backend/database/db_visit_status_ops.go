@@ -0,0 +1,109 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"sort"
+	"time"
+)
+
+// sameTime returns true if both times are unset or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// visitorHasVisitLocked returns true if the visitor has a visit in the given
+// status other than exceptID; callers must hold mu
+func visitorHasVisitLocked(visitorID, exceptID uint, status models.VisitorStatus) bool {
+	for id, visit := range visits {
+		if id != exceptID && visit.VisitorID == visitorID && visit.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransitionLocked validates the status change from the stored visit
+// (nil for a new one) to the visit about to replace it. The visit's own status
+// methods check the change against the status the caller read; this repeats
+// the check against the stored status, so a visit changed meanwhile is refused.
+// Callers must hold mu.
+func checkTransitionLocked(stored, visit *models.Visit) error {
+	var from models.VisitorStatus
+	var changedAt *time.Time
+	if stored != nil {
+		from = stored.Status
+		changedAt = stored.StatusChangedAt
+	}
+	if visit.Status == from && sameTime(visit.StatusChangedAt, changedAt) {
+		return nil
+	}
+	if !from.CanBecome(visit.Status) {
+		return &models.TransitionError{From: from, To: visit.Status}
+	}
+	switch visit.Status {
+	case models.StatusSignedIn, models.StatusPending:
+		if visitorHasVisitLocked(visit.VisitorID, visit.ID, models.StatusSignedIn) {
+			return ErrAlreadySignedIn
+		}
+	}
+	if visit.Status == models.StatusPending && visitorHasVisitLocked(visit.VisitorID, visit.ID, models.StatusPending) {
+		return ErrAlreadyPending
+	}
+	return nil
+}
+
+// recordStatusChangeLocked adds the visit's latest status change to its
+// history, if its status moved on from the given one; callers must hold mu
+func recordStatusChangeLocked(visit *models.Visit, from models.VisitorStatus) {
+	if visit.Status == from {
+		return
+	}
+	changedAt := visit.UpdatedAt
+	if visit.StatusChangedAt != nil {
+		changedAt = *visit.StatusChangedAt
+	} else if changedAt.IsZero() {
+		changedAt = visit.CreatedAt
+	}
+	statusChanges[statusChangeID] = &models.VisitStatusChange{
+		ID:        statusChangeID,
+		VisitID:   visit.ID,
+		From:      from,
+		To:        visit.Status,
+		ChangedAt: changedAt,
+		ChangedBy: visit.StatusChangedBy,
+		Reason:    visit.StatusReason(),
+	}
+	statusChangeID++
+}
+
+// Visit status operations
+
+// GetVisitStatusHistory returns the visit's status changes, oldest first
+func (db *MockDB) GetVisitStatusHistory(visitID uint) []*models.VisitStatusChange {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	history := make([]*models.VisitStatusChange, 0)
+	for _, change := range statusChanges {
+		if change.VisitID == visitID {
+			c := *change
+			history = append(history, &c)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].ID < history[j].ID
+	})
+	return history
+}
+
+// dropStatusChangesLocked deletes the visit's status history; callers must hold mu
+func dropStatusChangesLocked(visitID uint) {
+	for id, change := range statusChanges {
+		if change.VisitID == visitID {
+			delete(statusChanges, id)
+		}
+	}
+}
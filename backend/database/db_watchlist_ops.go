@@ -0,0 +1,99 @@
+package database
+
+import (
+	"digital-logbook/models"
+	"errors"
+	"time"
+)
+
+// Watchlist operations
+func (db *MockDB) CreateWatchlistEntry(entry *models.WatchlistEntry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entry.ID = watchlistID
+	entry.CreatedAt = time.Now()
+	watchlist[watchlistID] = entry
+	watchlistID++
+	return nil
+}
+
+func (db *MockDB) GetWatchlistEntryByID(id uint) (*models.WatchlistEntry, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	entry, exists := watchlist[id]
+	if !exists {
+		return nil, errors.New("watchlist entry not found")
+	}
+	return entry, nil
+}
+
+// GetAllWatchlistEntries filters by location_id (entries covering that
+// location, including global ones) and active
+func (db *MockDB) GetAllWatchlistEntries(filters map[string]interface{}) []*models.WatchlistEntry {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]*models.WatchlistEntry, 0, len(watchlist))
+	for _, entry := range watchlist {
+		if locationID, ok := filters["location_id"].(uint); ok {
+			if !entry.AppliesTo(locationID) {
+				continue
+			}
+		}
+		if active, ok := filters["active"].(bool); ok {
+			if entry.IsActive() != active {
+				continue
+			}
+		}
+		// Populate location data
+		if entry.LocationID != nil {
+			if loc, exists := locations[*entry.LocationID]; exists {
+				entry.Location = loc
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// ScreenWatchlist returns the active entries covering the location that match the visitor
+func (db *MockDB) ScreenWatchlist(name, idNumber string, locationID uint) []models.WatchlistMatch {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var matches []models.WatchlistMatch
+	for _, entry := range watchlist {
+		if !entry.IsActive() || !entry.AppliesTo(locationID) {
+			continue
+		}
+		if matchedOn, ok := entry.Match(name, idNumber); ok {
+			matches = append(matches, models.WatchlistMatch{Entry: entry, MatchedOn: matchedOn})
+		}
+	}
+	return matches
+}
+
+func (db *MockDB) UpdateWatchlistEntry(entry *models.WatchlistEntry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := watchlist[entry.ID]; !exists {
+		return errors.New("watchlist entry not found")
+	}
+	entry.UpdatedAt = time.Now()
+	watchlist[entry.ID] = entry
+	return nil
+}
+
+func (db *MockDB) DeleteWatchlistEntry(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, exists := watchlist[id]; !exists {
+		return errors.New("watchlist entry not found")
+	}
+	delete(watchlist, id)
+	return nil
+}
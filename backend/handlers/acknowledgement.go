@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"digital-logbook/database"
+	"digital-logbook/imaging"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSignatureSize is the largest signature image accepted, in bytes
+const maxSignatureSize = 512 << 10
+
+type CreateAckDocumentRequest struct {
+	Kind              models.AckDocumentKind `json:"kind" binding:"required"`
+	Title             string                 `json:"title" binding:"required"`
+	Body              string                 `json:"body" binding:"required"`
+	LocationID        uint                   `json:"location_id"`
+	AreaOfVisit       string                 `json:"area_of_visit"` // Omit for the whole location
+	SignatureRequired bool                   `json:"signature_required"`
+	ValidDays         int                    `json:"valid_days"`
+}
+
+// UpdateAckDocumentRequest changes a document; a new title or body publishes a new version
+type UpdateAckDocumentRequest struct {
+	Title             string `json:"title" binding:"required"`
+	Body              string `json:"body" binding:"required"`
+	AreaOfVisit       string `json:"area_of_visit"`
+	SignatureRequired bool   `json:"signature_required"`
+	ValidDays         int    `json:"valid_days"`
+	Active            *bool  `json:"active"` // False retires the document
+}
+
+// AcknowledgementRequest is a visitor's acceptance of a document at sign-in
+type AcknowledgementRequest struct {
+	DocumentID uint `json:"document_id"`
+	Version    int  `json:"version"` // The version shown to the visitor
+	// Signature is the drawn signature as a base64 PNG or JPEG, optionally as a data URL
+	Signature string `json:"signature"`
+}
+
+// AcknowledgementRecord is an acknowledgement with the text the visitor accepted
+type AcknowledgementRecord struct {
+	*models.Acknowledgement
+	AcceptedVersion *models.AckDocumentVersion `json:"accepted_version"`
+}
+
+// pendingAck is an acceptance checked at sign-in, recorded once the visit exists
+type pendingAck struct {
+	ack       *models.Acknowledgement
+	signature []byte
+}
+
+// ackProblem explains why the acknowledgements given do not allow sign-in
+type ackProblem struct {
+	status   int
+	message  string
+	missing  []*models.AckDocument // Documents still to be accepted
+	document *models.AckDocument   // The document whose version changed
+}
+
+func (p *ackProblem) response() gin.H {
+	response := gin.H{"error": p.message}
+	if p.missing != nil {
+		response["missing_acknowledgements"] = p.missing
+	}
+	if p.document != nil {
+		response["document"] = p.document
+	}
+	return response
+}
+
+// decodeSignature reads a base64 signature image and returns it with its content type
+func decodeSignature(s string) ([]byte, string, error) {
+	if strings.HasPrefix(s, "data:") {
+		_, data, ok := strings.Cut(s, ",")
+		if !ok {
+			return nil, "", errors.New("invalid data URL")
+		}
+		s = data
+	}
+	if base64.StdEncoding.DecodedLen(len(s)) > maxSignatureSize+3 {
+		return nil, "", errors.New("signature is too large")
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, "", errors.New("signature is not valid base64")
+	}
+	if len(data) > maxSignatureSize {
+		return nil, "", errors.New("signature is too large")
+	}
+	_, format, err := imaging.Decode(data)
+	if err != nil {
+		return nil, "", errors.New("signature must be a PNG or JPEG image")
+	}
+	return data, "image/" + format, nil
+}
+
+// checkAcknowledgements matches the acceptances given at sign-in against the
+// documents required for the area. visitorID is 0 for a new visitor.
+func checkAcknowledgements(user *models.User, visitorID, locationID uint, area string, reqs []AcknowledgementRequest) ([]*pendingAck, *ackProblem) {
+	now := time.Now()
+	var pending []*pendingAck
+	accepted := make(map[uint]bool)
+	for _, r := range reqs {
+		doc, err := database.DB.GetAckDocumentByID(r.DocumentID)
+		if err != nil || !doc.Active || !doc.AppliesTo(locationID, area) {
+			return nil, &ackProblem{status: http.StatusBadRequest, message: fmt.Sprintf("Document %d does not apply to this visit", r.DocumentID)}
+		}
+		if accepted[doc.ID] {
+			return nil, &ackProblem{status: http.StatusBadRequest, message: fmt.Sprintf("%q is acknowledged more than once", doc.Title)}
+		}
+		if r.Version != doc.Version {
+			return nil, &ackProblem{
+				status:   http.StatusConflict,
+				message:  fmt.Sprintf("%q has been updated to version %d; show the visitor the current text", doc.Title, doc.Version),
+				document: doc,
+			}
+		}
+
+		p := &pendingAck{ack: &models.Acknowledgement{
+			DocumentID: doc.ID,
+			Version:    doc.Version,
+			LocationID: locationID,
+			AcceptedAt: now,
+			RecordedBy: actorID(user),
+		}}
+		if r.Signature != "" {
+			data, contentType, err := decodeSignature(r.Signature)
+			if err != nil {
+				return nil, &ackProblem{status: http.StatusBadRequest, message: fmt.Sprintf("%q: %v", doc.Title, err)}
+			}
+			if imageStore == nil {
+				return nil, &ackProblem{status: http.StatusServiceUnavailable, message: "Signature storage is not configured"}
+			}
+			p.signature = data
+			p.ack.SignatureType = contentType
+		} else if doc.SignatureRequired {
+			return nil, &ackProblem{status: http.StatusBadRequest, message: fmt.Sprintf("%q must be signed", doc.Title)}
+		}
+		accepted[doc.ID] = true
+		pending = append(pending, p)
+	}
+
+	var missing []*models.AckDocument
+	for _, doc := range database.DB.MissingAcknowledgements(visitorID, locationID, area, now) {
+		if !accepted[doc.ID] {
+			missing = append(missing, doc)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ackProblem{
+			status:  http.StatusConflict,
+			message: "The visitor must acknowledge the required documents before signing in",
+			missing: missing,
+		}
+	}
+	return pending, nil
+}
+
+// storeSignatures saves the signature images before the visit is created
+func storeSignatures(ctx context.Context, pending []*pendingAck) error {
+	for _, p := range pending {
+		if p.signature == nil {
+			continue
+		}
+		name, err := newBlobName()
+		if err != nil {
+			discardSignatures(ctx, pending)
+			return err
+		}
+		ext := strings.TrimPrefix(p.ack.SignatureType, "image/")
+		key := fmt.Sprintf("acknowledgements/%d/%s.%s", p.ack.DocumentID, name, ext)
+		if err := imageStore.Put(ctx, key, bytes.NewReader(p.signature)); err != nil {
+			discardSignatures(ctx, pending)
+			return err
+		}
+		p.ack.SignatureKey = key
+	}
+	return nil
+}
+
+// discardSignatures removes stored signatures when the sign-in fails after all
+func discardSignatures(ctx context.Context, pending []*pendingAck) {
+	for _, p := range pending {
+		if p.ack.SignatureKey != "" {
+			imageStore.Delete(ctx, p.ack.SignatureKey)
+			p.ack.SignatureKey = ""
+		}
+	}
+}
+
+// recordAcknowledgements saves the acceptances against the visit they were given for
+func recordAcknowledgements(ctx context.Context, pending []*pendingAck, visitorID, visitID uint) {
+	if len(pending) == 0 {
+		return
+	}
+	acks := make([]*models.Acknowledgement, len(pending))
+	for i, p := range pending {
+		p.ack.VisitorID = visitorID
+		p.ack.VisitID = &visitID
+		acks[i] = p.ack
+	}
+	if err := database.DB.CreateAcknowledgements(acks); err != nil {
+		log.Printf("Failed to record acknowledgements for visit %d: %v", visitID, err)
+		discardSignatures(ctx, pending)
+	}
+}
+
+// acceptAcknowledgements checks the acceptances given for a single sign-in
+// and stores their signatures
+func acceptAcknowledgements(c *gin.Context, user *models.User, visitorID, locationID uint, area string, reqs []AcknowledgementRequest) ([]*pendingAck, bool) {
+	pending, problem := checkAcknowledgements(user, visitorID, locationID, area, reqs)
+	if problem != nil {
+		c.JSON(problem.status, problem.response())
+		return nil, false
+	}
+	if err := storeSignatures(c.Request.Context(), pending); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store signature"})
+		return nil, false
+	}
+	return pending, true
+}
+
+// ackDocumentFromParam loads the document in the URL if the user may access its location
+func ackDocumentFromParam(c *gin.Context) (*models.AckDocument, *models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	doc, err := database.DB.GetAckDocumentByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != doc.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return nil, nil, false
+	}
+	return doc, user, true
+}
+
+// ListAckDocuments returns the acknowledgement documents, filtered by
+// location_id, kind, active and area_of_visit
+func ListAckDocuments(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if kind := c.Query("kind"); kind != "" {
+		filters["kind"] = models.AckDocumentKind(kind)
+	}
+	if active := c.Query("active"); active != "" {
+		filters["active"] = active == "true"
+	}
+	if area := c.Query("area_of_visit"); area != "" {
+		filters["area_of_visit"] = area
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllAckDocuments(filters))
+}
+
+// GetAckDocument returns a document with its current text
+func GetAckDocument(c *gin.Context) {
+	doc, _, ok := ackDocumentFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// ListAckDocumentVersions returns every published version of a document
+func ListAckDocumentVersions(c *gin.Context) {
+	doc, _, ok := ackDocumentFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, database.DB.GetAckDocumentVersions(doc.ID))
+}
+
+// RequiredAcknowledgements lists the documents a visitor must accept before
+// signing in to an area. Without visitor_id, it lists what a new visitor needs.
+func RequiredAcknowledgements(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if id, err := strconv.ParseUint(c.Query("location_id"), 10, 32); err == nil {
+		locationID = uint(id)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+
+	var visitorID uint
+	if v := c.Query("visitor_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visitor ID"})
+			return
+		}
+		visitorID = uint(id)
+	}
+
+	c.JSON(http.StatusOK, database.DB.MissingAcknowledgements(visitorID, locationID, c.Query("area_of_visit"), time.Now()))
+}
+
+// CreateAckDocument publishes version 1 of a document (admin only)
+func CreateAckDocument(c *gin.Context) {
+	var req CreateAckDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Kind.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be safety_induction or nda"})
+		return
+	}
+	if req.ValidDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid days cannot be negative"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+
+	doc := &models.AckDocument{
+		Kind:              req.Kind,
+		Title:             req.Title,
+		Body:              req.Body,
+		LocationID:        locationID,
+		AreaOfVisit:       strings.TrimSpace(req.AreaOfVisit),
+		SignatureRequired: req.SignatureRequired,
+		ValidDays:         req.ValidDays,
+		Active:            true,
+		CreatedBy:         user.ID,
+	}
+	if err := database.DB.CreateAckDocument(doc); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+		return
+	}
+	recordAudit(c, models.AuditAckDocumentPublished, "ack_document", doc.ID, &doc.LocationID, map[string]interface{}{
+		"title":   doc.Title,
+		"version": doc.Version,
+	})
+
+	c.JSON(http.StatusCreated, doc)
+}
+
+// UpdateAckDocument changes a document (admin only). A new title or body is
+// published as the next version, and visitors have to accept it again.
+func UpdateAckDocument(c *gin.Context) {
+	doc, user, ok := ackDocumentFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAckDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ValidDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid days cannot be negative"})
+		return
+	}
+
+	doc.Title = req.Title
+	doc.Body = req.Body
+	doc.AreaOfVisit = strings.TrimSpace(req.AreaOfVisit)
+	doc.SignatureRequired = req.SignatureRequired
+	doc.ValidDays = req.ValidDays
+	if req.Active != nil {
+		doc.Active = *req.Active
+	}
+
+	published, err := database.DB.UpdateAckDocument(doc, user.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	if published {
+		recordAudit(c, models.AuditAckDocumentPublished, "ack_document", doc.ID, &doc.LocationID, map[string]interface{}{
+			"title":   doc.Title,
+			"version": doc.Version,
+		})
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// ListAcknowledgements returns acknowledgements for audits (admin only).
+// Filters: visitor_id, document_id, visit_id, location_id, from and to.
+func ListAcknowledgements(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	for _, name := range []string{"visitor_id", "document_id", "visit_id"} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ReplaceAll(name, "_", " ")})
+				return
+			}
+			filters[name] = uint(id)
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		t, ok := parseTimeParam(from)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+		filters["from"] = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, ok := parseTimeParam(to)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
+		}
+		// A bare date includes the whole day
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		filters["to"] = t
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllAcknowledgements(filters))
+}
+
+// acknowledgementFromParam loads the acknowledgement in the URL if the admin may access its location
+func acknowledgementFromParam(c *gin.Context) (*models.Acknowledgement, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	ack, err := database.DB.GetAcknowledgementByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != ack.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Acknowledgement not found"})
+		return nil, false
+	}
+	return ack, true
+}
+
+// GetAcknowledgement returns an acknowledgement with the exact text accepted (admin only)
+func GetAcknowledgement(c *gin.Context) {
+	ack, ok := acknowledgementFromParam(c)
+	if !ok {
+		return
+	}
+
+	record := AcknowledgementRecord{Acknowledgement: ack}
+	if version, err := database.DB.GetAckDocumentVersion(ack.DocumentID, ack.Version); err == nil {
+		record.AcceptedVersion = version
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// GetAcknowledgementSignature streams the signature drawn by the visitor (admin only)
+func GetAcknowledgementSignature(c *gin.Context) {
+	ack, ok := acknowledgementFromParam(c)
+	if !ok {
+		return
+	}
+	if ack.SignatureKey == "" || imageStore == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Acknowledgement has no signature"})
+		return
+	}
+
+	blob, err := imageStore.Get(c.Request.Context(), ack.SignatureKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Acknowledgement has no signature"})
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, ack.SignatureType, blob, nil)
+}
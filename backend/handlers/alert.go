@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/jobs"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// locationFilter restricts a listing to the user's location, or to the
+// location_id query parameter for users who are not bound to one
+func locationFilter(c *gin.Context, user *models.User) map[string]interface{} {
+	filters := make(map[string]interface{})
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	} else if locID := c.Query("location_id"); locID != "" {
+		if id, err := strconv.ParseUint(locID, 10, 32); err == nil {
+			filters["location_id"] = uint(id)
+		}
+	}
+	return filters
+}
+
+// ListAlerts returns overdue-visit and unreturned-badge alerts, newest first
+func ListAlerts(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if alertType := c.Query("type"); alertType != "" {
+		filters["type"] = alertType
+	}
+	if acknowledged := c.Query("acknowledged"); acknowledged != "" {
+		filters["acknowledged"] = acknowledged == "true"
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllAlerts(filters))
+}
+
+// AcknowledgeAlert marks an alert as dealt with by the current user
+func AcknowledgeAlert(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	alert, err := database.DB.GetAlertByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != alert.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+
+	alert, err = database.DB.AcknowledgeAlert(alert.ID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// ListSweepReports returns end-of-day sweep reports, newest first
+func ListSweepReports(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllSweepReports(locationFilter(c, user)))
+}
+
+// GetSweepReport returns a specific sweep report by ID
+func GetSweepReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	report, err := database.DB.GetSweepReportByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != report.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sweep report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// SweepLocation runs the end-of-day sweep of a location now (admin only)
+func SweepLocation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	location, err := database.DB.GetLocationByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != location.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return
+	}
+
+	report, _ := jobs.SweepLocation(location, models.SweepManual, &user.ID, time.Now())
+	c.JSON(http.StatusOK, report)
+}
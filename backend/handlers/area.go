@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAreaRequest struct {
+	Name           string                 `json:"name" binding:"required"`
+	AccessZone     string                 `json:"access_zone"`
+	AccessLevel    models.AreaAccessLevel `json:"access_level"` // Defaults to public
+	BadgeTypes     []string               `json:"badge_types"`
+	EscortRequired bool                   `json:"escort_required"`
+	MaxOccupancy   int                    `json:"max_occupancy"`
+	LocationID     uint                   `json:"location_id"`
+}
+
+type UpdateAreaRequest struct {
+	Name           string                 `json:"name" binding:"required"`
+	AccessZone     string                 `json:"access_zone"`
+	AccessLevel    models.AreaAccessLevel `json:"access_level"`
+	BadgeTypes     []string               `json:"badge_types"`
+	EscortRequired bool                   `json:"escort_required"`
+	MaxOccupancy   int                    `json:"max_occupancy"`
+	Active         *bool                  `json:"active"` // Optional; false retires the area
+}
+
+// resolveArea picks the area a visitor is signed in to, by ID or by name.
+// Locations without managed areas still take a free-text area, returned as a
+// nil area; once a location has areas, only those can be picked.
+func resolveArea(c *gin.Context, locationID, areaID uint, name string) (*models.Area, bool) {
+	if areaID != 0 {
+		area, err := database.DB.GetAreaByID(areaID)
+		if err != nil || area.LocationID != locationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Area not found at this location"})
+			return nil, false
+		}
+		if !area.Active {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Area is no longer in use"})
+			return nil, false
+		}
+		return area, true
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Area of visit is required"})
+		return nil, false
+	}
+	if !database.DB.HasAreas(locationID) {
+		return nil, true
+	}
+	area, err := database.DB.GetAreaByName(locationID, name)
+	if err != nil || !area.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown area; pick one of the location's areas by area_id"})
+		return nil, false
+	}
+	return area, true
+}
+
+// areaName is the managed area's name, or the free-text name when there is none
+func areaName(area *models.Area, name string) string {
+	if area == nil {
+		return strings.TrimSpace(name)
+	}
+	return area.Name
+}
+
+// setVisitArea links the visit to a managed area, using its canonical name
+func setVisitArea(visit *models.Visit, area *models.Area, name string) {
+	visit.AreaID = nil
+	if area != nil {
+		visit.AreaID = &area.ID
+	}
+	visit.AreaOfVisit = areaName(area, name)
+}
+
+// areaOrAbort rejects a sign-in early when the area is full or the badge does
+// not open it. CreateVisit repeats both checks atomically.
+func areaOrAbort(c *gin.Context, area *models.Area, badgeNumber string, arriving int) bool {
+	if area == nil {
+		return true
+	}
+	if area.IsFull(area.OnSite + arriving - 1) {
+		respondVisitError(c, database.ErrAreaFull, "")
+		return false
+	}
+	if badgeNumber == "" {
+		return true
+	}
+	if badge, err := database.DB.GetBadgeByNumber(area.LocationID, badgeNumber); err == nil && !area.Admits(badge) {
+		respondVisitError(c, database.ErrBadgeNotForArea, "")
+		return false
+	}
+	return true
+}
+
+// validateAreaRequest checks the access level and occupancy limit of a new or updated area
+func validateAreaRequest(c *gin.Context, level *models.AreaAccessLevel, maxOccupancy int) bool {
+	if *level == "" {
+		*level = models.AccessPublic
+	}
+	if !level.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Access level must be public, restricted or secure"})
+		return false
+	}
+	if maxOccupancy < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum occupancy cannot be negative"})
+		return false
+	}
+	return true
+}
+
+// cleanBadgeTypes trims the badge types and drops blanks
+func cleanBadgeTypes(types []string) []string {
+	cleaned := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return cleaned
+}
+
+// areaFromParam loads the area named in the URL if the user may access its location
+func areaFromParam(c *gin.Context) (*models.Area, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	area, err := database.DB.GetAreaByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != area.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area not found"})
+		return nil, false
+	}
+	return area, true
+}
+
+// ListAreas returns the areas visitors can be signed in to, with the number on site.
+// Query: access_level, active and location_id (super admin only).
+func ListAreas(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if level := c.Query("access_level"); level != "" {
+		filters["access_level"] = models.AreaAccessLevel(level)
+	}
+	if active := c.Query("active"); active != "" {
+		filters["active"] = active == "true"
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllAreas(filters))
+}
+
+// GetAreaOccupancy reports the visitors on site in each active area against its limit
+func GetAreaOccupancy(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAreaOccupancy(locationFilter(c, user)))
+}
+
+// GetArea returns a specific area by ID
+func GetArea(c *gin.Context) {
+	area, ok := areaFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
+// CreateArea adds an area to a location (admin only)
+func CreateArea(c *gin.Context) {
+	var req CreateAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+	if _, err := database.DB.GetLocationByID(locationID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+		return
+	}
+	if !validateAreaRequest(c, &req.AccessLevel, req.MaxOccupancy) {
+		return
+	}
+
+	area := &models.Area{
+		LocationID:     locationID,
+		Name:           req.Name,
+		AccessZone:     strings.TrimSpace(req.AccessZone),
+		AccessLevel:    req.AccessLevel,
+		BadgeTypes:     cleanBadgeTypes(req.BadgeTypes),
+		EscortRequired: req.EscortRequired,
+		MaxOccupancy:   req.MaxOccupancy,
+		Active:         true,
+	}
+
+	if err := database.DB.CreateArea(area); err != nil {
+		if errors.Is(err, database.ErrAreaNameInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Area name already exists at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create area"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, area)
+}
+
+// UpdateArea changes an area's access rules or retires it (admin only).
+// Visitors already on site are not affected.
+func UpdateArea(c *gin.Context) {
+	area, ok := areaFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateAreaRequest(c, &req.AccessLevel, req.MaxOccupancy) {
+		return
+	}
+
+	area.Name = req.Name
+	area.AccessZone = strings.TrimSpace(req.AccessZone)
+	area.AccessLevel = req.AccessLevel
+	area.BadgeTypes = cleanBadgeTypes(req.BadgeTypes)
+	area.EscortRequired = req.EscortRequired
+	area.MaxOccupancy = req.MaxOccupancy
+	if req.Active != nil {
+		area.Active = *req.Active
+	}
+
+	if err := database.DB.UpdateArea(area); err != nil {
+		if errors.Is(err, database.ErrAreaNameInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Area name already exists at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update area"})
+		return
+	}
+
+	c.JSON(http.StatusOK, area)
+}
+
+// DeleteArea removes an area (admin only). Past visits keep its name; areas
+// with visitors on site must be emptied or retired instead.
+func DeleteArea(c *gin.Context) {
+	area, ok := areaFromParam(c)
+	if !ok {
+		return
+	}
+
+	if area.OnSite > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitors are signed in to this area; retire it instead"})
+		return
+	}
+
+	if err := database.DB.DeleteArea(area.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete area"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Area deleted successfully"})
+}
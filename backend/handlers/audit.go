@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit appends an entry to the audit log on behalf of the current user
+func recordAudit(c *gin.Context, action models.AuditAction, entityType string, entityID uint, locationID *uint, details map[string]interface{}) {
+	entry := &models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		LocationID: locationID,
+		Details:    details,
+		IPAddress:  c.ClientIP(),
+	}
+	if user, err := middleware.GetCurrentUser(c); err == nil {
+		entry.ActorName = user.Username
+		if user.ID != 0 {
+			id := user.ID
+			entry.ActorID = &id
+		}
+	}
+
+	if err := database.DB.CreateAuditLog(entry); err != nil {
+		log.Printf("Failed to record audit entry %s: %v", action, err)
+	}
+}
+
+// ListAuditLogs returns audit entries, newest first (admin only)
+func ListAuditLogs(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := make(map[string]interface{})
+
+	// If user is restricted to a location, force that filter
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	} else if locID := c.Query("location_id"); locID != "" {
+		if id, err := strconv.ParseUint(locID, 10, 32); err == nil {
+			filters["location_id"] = uint(id)
+		}
+	}
+
+	if action := c.Query("action"); action != "" {
+		filters["action"] = action
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		filters["entity_type"] = entityType
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		if id, err := strconv.ParseUint(entityID, 10, 32); err == nil {
+			filters["entity_id"] = uint(id)
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		if t, ok := parseTimeParam(from); ok {
+			filters["from"] = t
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if t, ok := parseTimeParam(to); ok {
+			filters["to"] = t
+		}
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllAuditLogs(filters))
+}
@@ -1,15 +1,17 @@
 package handlers
 
 import (
+	"digital-logbook/auth"
 	"digital-logbook/database"
 	"digital-logbook/middleware"
 	"digital-logbook/models"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type LoginRequest struct {
@@ -23,6 +25,14 @@ type LoginResponse struct {
 	ExpiresAt time.Time   `json:"expires_at"`
 }
 
+// authenticators verifies passwords for Login; local accounts only until configured
+var authenticators = auth.Chain{&auth.LocalAuthenticator{}}
+
+// ConfigureAuthenticators sets the password authenticator chain used by Login
+func ConfigureAuthenticators(chain auth.Chain) {
+	authenticators = chain
+}
+
 // Login authenticates a user and returns a JWT token
 func Login(c *gin.Context) {
 	var req LoginRequest
@@ -31,43 +41,91 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by username
-	user, err := database.DB.GetUserByUsername(req.Username)
+	// Verify credentials against the configured authenticators
+	user, err := authenticators.Authenticate(c.Request.Context(), req.Username, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		status := http.StatusUnauthorized
+		message := "Invalid credentials"
+		if errors.Is(err, auth.ErrNoMappedRole) || errors.Is(err, auth.ErrNoMappedSite) || errors.Is(err, auth.ErrUsernameInUse) ||
+			errors.Is(err, auth.ErrAccountDisabled) {
+			status = http.StatusForbidden
+			message = "Access denied: " + err.Error()
+		}
+		c.JSON(status, gin.H{"error": message})
 		return
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	response, err := issueToken(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// issueToken records a new session for the user and signs a JWT bound to it
+func issueToken(c *gin.Context, user *models.User) (*LoginResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(24 * time.Hour)
+
+	session := &models.Session{
+		UserID:     user.ID,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := database.DB.CreateSession(session); err != nil {
+		return nil, err
+	}
+
 	// Generate JWT token
-	expiresAt := time.Now().Add(24 * time.Hour)
 	claims := &middleware.Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     string(user.Role),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        strconv.FormatUint(uint64(session.ID), 10),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(middleware.JWTSecret)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{
+	return &LoginResponse{
 		Token:     tokenString,
 		User:      *user,
 		ExpiresAt: expiresAt,
-	})
+	}, nil
+}
+
+// Logout revokes the session of the current token
+func Logout(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, ok := middleware.GetCurrentSession(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No session to sign out"})
+		return
+	}
+
+	if err := database.DB.RevokeSession(session.ID, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out successfully"})
 }
 
 // GetCurrentUser returns the currently authenticated user
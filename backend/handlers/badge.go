@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateBadgeRequest struct {
+	Number     string `json:"number" binding:"required"`
+	Type       string `json:"type"`
+	AccessZone string `json:"access_zone"`
+	LocationID uint   `json:"location_id"`
+}
+
+type UpdateBadgeRequest struct {
+	Number     string             `json:"number" binding:"required"`
+	Type       string             `json:"type"`
+	AccessZone string             `json:"access_zone"`
+	Status     models.BadgeStatus `json:"status"` // Only available or retired; issuing happens at sign-in
+}
+
+// checkBadgeAvailable rejects a sign-in early when the badge cannot be issued.
+// CreateVisit repeats the check atomically.
+func checkBadgeAvailable(c *gin.Context, locationID uint, number string) bool {
+	badge, err := database.DB.GetBadgeByNumber(locationID, number)
+	if err != nil {
+		respondVisitError(c, database.ErrBadgeNotInInventory, "")
+		return false
+	}
+	if badge.Status != models.BadgeAvailable {
+		respondVisitError(c, database.ErrBadgeUnavailable, "")
+		return false
+	}
+	return true
+}
+
+// badgeFromParam loads the badge named in the URL if the user may access its location
+func badgeFromParam(c *gin.Context) (*models.Badge, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	badge, err := database.DB.GetBadgeByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != badge.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+		return nil, false
+	}
+	return badge, true
+}
+
+// badgeFilters applies location scoping and the type query parameter
+func badgeFilters(c *gin.Context) (map[string]interface{}, bool) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	filters := make(map[string]interface{})
+
+	// If user is restricted to a location, force that filter
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	} else if locID := c.Query("location_id"); locID != "" {
+		if id, err := strconv.ParseUint(locID, 10, 32); err == nil {
+			filters["location_id"] = uint(id)
+		}
+	}
+	if badgeType := c.Query("type"); badgeType != "" {
+		filters["type"] = badgeType
+	}
+	return filters, true
+}
+
+// ListBadges returns the badge inventory with optional filtering
+func ListBadges(c *gin.Context) {
+	filters, ok := badgeFilters(c)
+	if !ok {
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		filters["status"] = models.BadgeStatus(status)
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllBadges(filters))
+}
+
+// ListOutstandingBadges returns badges that are issued, lost or unreturned, with the visit holding them
+func ListOutstandingBadges(c *gin.Context) {
+	filters, ok := badgeFilters(c)
+	if !ok {
+		return
+	}
+	filters["status"] = []models.BadgeStatus{models.BadgeIssued, models.BadgeLost, models.BadgeUnreturned}
+
+	c.JSON(http.StatusOK, database.DB.GetAllBadges(filters))
+}
+
+// GetBadge returns a specific badge by ID
+func GetBadge(c *gin.Context) {
+	badge, ok := badgeFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, badge)
+}
+
+// CreateBadge adds a badge to a location's inventory (admin only)
+func CreateBadge(c *gin.Context) {
+	var req CreateBadgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+	if _, err := database.DB.GetLocationByID(locationID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+		return
+	}
+
+	badge := &models.Badge{
+		LocationID: locationID,
+		Number:     req.Number,
+		Type:       req.Type,
+		AccessZone: req.AccessZone,
+		Status:     models.BadgeAvailable,
+	}
+
+	if err := database.DB.CreateBadge(badge); err != nil {
+		if errors.Is(err, database.ErrBadgeNumberInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Badge number already exists at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create badge"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, badge)
+}
+
+// UpdateBadge changes a badge's details, or retires and reinstates it (admin only)
+func UpdateBadge(c *gin.Context) {
+	badge, ok := badgeFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateBadgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Status != "" && req.Status != badge.Status {
+		if req.Status != models.BadgeAvailable && req.Status != models.BadgeRetired {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status can only be set to available or retired; use the lost and recovered endpoints otherwise"})
+			return
+		}
+		if badge.Status == models.BadgeIssued {
+			c.JSON(http.StatusConflict, gin.H{"error": "Badge is issued; sign the visitor out first"})
+			return
+		}
+		badge.Status = req.Status
+		badge.VisitID = nil
+		badge.LostAt = nil
+	}
+
+	badge.Number = req.Number
+	badge.Type = req.Type
+	badge.AccessZone = req.AccessZone
+
+	if err := database.DB.UpdateBadge(badge); err != nil {
+		if errors.Is(err, database.ErrBadgeNumberInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Badge number already exists at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, badge)
+}
+
+// DeleteBadge removes a badge from inventory (admin only)
+func DeleteBadge(c *gin.Context) {
+	badge, ok := badgeFromParam(c)
+	if !ok {
+		return
+	}
+
+	if badge.Status == models.BadgeIssued {
+		c.JSON(http.StatusConflict, gin.H{"error": "Badge is issued; sign the visitor out first"})
+		return
+	}
+
+	if err := database.DB.DeleteBadge(badge.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete badge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Badge deleted successfully"})
+}
+
+// MarkBadgeLost records that a badge was not returned. The visit that held it is kept for follow-up.
+func MarkBadgeLost(c *gin.Context) {
+	badge, ok := badgeFromParam(c)
+	if !ok {
+		return
+	}
+
+	if badge.Status == models.BadgeLost || badge.Status == models.BadgeRetired {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Badge is already " + string(badge.Status)})
+		return
+	}
+
+	now := time.Now()
+	badge.Status = models.BadgeLost
+	badge.LostAt = &now
+
+	if err := database.DB.UpdateBadge(badge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, badge)
+}
+
+// MarkBadgeRecovered returns a lost or unreturned badge to inventory
+func MarkBadgeRecovered(c *gin.Context) {
+	badge, ok := badgeFromParam(c)
+	if !ok {
+		return
+	}
+
+	if badge.Status != models.BadgeLost && badge.Status != models.BadgeUnreturned {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Badge is not marked as lost or unreturned"})
+		return
+	}
+
+	// A badge lost during a visit that is still open goes back to that visit
+	badge.Status = models.BadgeAvailable
+	if badge.Visit != nil && badge.Visit.IsActive() {
+		badge.Status = models.BadgeIssued
+	} else {
+		badge.VisitID = nil
+		badge.IssuedAt = nil
+	}
+	badge.LostAt = nil
+
+	if err := database.DB.UpdateBadge(badge); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge"})
+		return
+	}
+
+	c.JSON(http.StatusOK, badge)
+}
@@ -97,6 +97,9 @@ func ListCargo(c *gin.Context) {
 	if category != "" {
 		filters["category"] = category
 	}
+	if released := c.Query("released"); released != "" {
+		filters["released"] = released == "true"
+	}
 
 	cargoList := database.DB.GetAllCargo(filters)
 	c.JSON(http.StatusOK, cargoList)
@@ -156,6 +159,42 @@ func UpdateCargo(c *gin.Context) {
 	c.JSON(http.StatusOK, cargo)
 }
 
+// ReleaseCargo records that the cargo was released and its driver has left the site
+func ReleaseCargo(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	cargo, err := database.DB.GetCargoByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != cargo.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cargo not found"})
+		return
+	}
+
+	if cargo.IsReleased() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cargo already released"})
+		return
+	}
+
+	now := time.Now()
+	cargo.TimeOut = &now
+
+	if err := database.DB.UpdateCargo(cargo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release cargo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cargo)
+}
+
 // DeleteCargo deletes a cargo entry (admin only)
 func DeleteCargo(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDeclaredItems limits the items declared for one visit
+const maxDeclaredItems = 50
+
+// DeclaredItemRequest is a laptop, tool or other equipment brought on site
+type DeclaredItemRequest struct {
+	Type         models.ItemType `json:"type"` // Defaults to other
+	Description  string          `json:"description"`
+	SerialNumber string          `json:"serial_number"`
+}
+
+// ItemCheckRequest is the exit check of one declared item
+type ItemCheckRequest struct {
+	ItemID       uint   `json:"item_id"`
+	Present      bool   `json:"present"`       // False when the visitor leaves without it
+	SerialNumber string `json:"serial_number"` // Optional; read off the item to confirm it is the same one
+	Note         string `json:"note"`
+}
+
+// SignOutVisitorRequest checks the visitor's declared items at the exit.
+// It is required when the visit has declared items.
+type SignOutVisitorRequest struct {
+	Items           []ItemCheckRequest    `json:"items"`            // One per declared item
+	UndeclaredItems []DeclaredItemRequest `json:"undeclared_items"` // Items leaving that were not declared
+}
+
+// newDeclaredItems validates the items declared at sign-in
+func newDeclaredItems(reqs []DeclaredItemRequest) ([]*models.DeclaredItem, error) {
+	if len(reqs) > maxDeclaredItems {
+		return nil, fmt.Errorf("at most %d items can be declared", maxDeclaredItems)
+	}
+	items := make([]*models.DeclaredItem, 0, len(reqs))
+	for i, r := range reqs {
+		if r.Type == "" {
+			r.Type = models.ItemOther
+		}
+		if !r.Type.IsValid() {
+			return nil, fmt.Errorf("item %d: type must be laptop, phone, tablet, camera, storage_media, tool or other", i+1)
+		}
+		description := strings.TrimSpace(r.Description)
+		if description == "" {
+			return nil, fmt.Errorf("item %d: a description is required", i+1)
+		}
+		items = append(items, &models.DeclaredItem{
+			Type:         r.Type,
+			Description:  description,
+			SerialNumber: strings.TrimSpace(r.SerialNumber),
+		})
+	}
+	return items, nil
+}
+
+// declaredItemsOrAbort responds 400 if the items declared at sign-in are invalid
+func declaredItemsOrAbort(c *gin.Context, reqs []DeclaredItemRequest) ([]*models.DeclaredItem, bool) {
+	items, err := newDeclaredItems(reqs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Declared items: " + err.Error()})
+		return nil, false
+	}
+	return items, true
+}
+
+// itemCheck is the exit check of a visit's declared items, saved together
+// with the sign-out by closeVisit
+type itemCheck struct {
+	checked    []*models.DeclaredItem
+	undeclared []*models.DeclaredItem
+}
+
+// pendingItems returns the visit's declared items that have not been checked yet
+func pendingItems(visit *models.Visit) []*models.DeclaredItem {
+	var pending []*models.DeclaredItem
+	for _, item := range visit.Items {
+		if item.Status == models.ItemDeclared {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// prepareItemCheck applies the exit check to the visit's declared items and
+// sets the visit's mismatch flag, without saving either. Items already
+// checked are left as they are. It returns a zero status when the visitor
+// may sign out, otherwise the response to send.
+func prepareItemCheck(user *models.User, visit *models.Visit, req *SignOutVisitorRequest) (*itemCheck, int, gin.H) {
+	pending := pendingItems(visit)
+	if len(pending) > 0 && req.Items == nil {
+		return nil, http.StatusConflict, gin.H{
+			"error": "Check the visitor's declared items before signing them out",
+			"items": pending,
+		}
+	}
+
+	checks := make(map[uint]ItemCheckRequest, len(req.Items))
+	for _, check := range req.Items {
+		checks[check.ItemID] = check
+	}
+	now := time.Now()
+	for _, item := range pending {
+		check, ok := checks[item.ID]
+		if !ok {
+			return nil, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d (%s) has not been checked", item.ID, item.Description)}
+		}
+		delete(checks, item.ID)
+		item.Check(check.Present, check.SerialNumber, check.Note, actorID(user), now)
+	}
+	for id := range checks {
+		return nil, http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d is not declared on this visit or was already checked", id)}
+	}
+
+	undeclared, err := newDeclaredItems(req.UndeclaredItems)
+	if err != nil {
+		return nil, http.StatusBadRequest, gin.H{"error": "Undeclared items: " + err.Error()}
+	}
+	for _, item := range undeclared {
+		item.Status = models.ItemUndeclared
+		item.CheckedAt = &now
+		item.CheckedBy = actorID(user)
+	}
+
+	for _, item := range append(visit.Items, undeclared...) {
+		if item.Status.IsMismatch() {
+			visit.ItemMismatch = true
+		}
+	}
+	return &itemCheck{checked: pending, undeclared: undeclared}, 0, nil
+}
+
+// checkVisitItems is prepareItemCheck for a single visit, writing the response
+// when the visitor may not sign out
+func checkVisitItems(c *gin.Context, user *models.User, visit *models.Visit, req *SignOutVisitorRequest) (*itemCheck, bool) {
+	check, status, response := prepareItemCheck(user, visit, req)
+	if status != 0 {
+		c.JSON(status, response)
+		return nil, false
+	}
+	return check, true
+}
+
+// ListDeclaredItems returns declared items with their visits, newest first.
+// Query: status, serial_number, visit_id and location_id (super admin only).
+func ListDeclaredItems(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if status := c.Query("status"); status != "" {
+		filters["status"] = models.ItemStatus(status)
+	}
+	if serial := c.Query("serial_number"); serial != "" {
+		filters["serial_number"] = serial
+	}
+	if v := c.Query("visit_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visit ID"})
+			return
+		}
+		filters["visit_id"] = uint(id)
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetDeclaredItems(filters))
+}
+
+// ListOutstandingItems reports items declared in but not checked out: those
+// of visits that ended without an exit check, and those found missing.
+// ?on_site=true adds the items of visitors still on site.
+func ListOutstandingItems(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	filters["outstanding"] = true
+	if c.Query("on_site") != "true" {
+		filters["on_site"] = false
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetDeclaredItems(filters))
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandOverEscortRequest passes a visitor on to another staff escort
+type HandOverEscortRequest struct {
+	EscortID uint   `json:"escort_id" binding:"required"` // From the host directory
+	Note     string `json:"note"`
+}
+
+// resolveEscort loads the staff escort picked at sign-in and refuses a
+// sign-in to an area that requires an escort without one
+func resolveEscort(c *gin.Context, escortID, locationID uint, area *models.Area) (*models.Host, bool) {
+	if escortID == 0 {
+		if area != nil && area.EscortRequired {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Visitors to " + area.Name + " must be escorted; give an escort_id"})
+			return nil, false
+		}
+		return nil, true
+	}
+	escort, err := database.DB.GetHostByID(escortID)
+	if err != nil || !escort.Active || escort.LocationID != locationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Escort not found at this location"})
+		return nil, false
+	}
+	return escort, true
+}
+
+// setVisitEscort assigns the escort to a visit being signed in
+func setVisitEscort(visit *models.Visit, escort *models.Host) {
+	visit.EscortID = nil
+	visit.EscortName = ""
+	if escort != nil {
+		visit.EscortID = &escort.ID
+		visit.EscortName = escort.Name
+	}
+}
+
+// recordEscort starts the escort assignment of a visit that was just signed in
+func recordEscort(visit *models.Visit, assignedBy *uint) {
+	if visit.EscortID == nil {
+		return
+	}
+	database.DB.CreateEscortAssignment(&models.EscortAssignment{
+		VisitID:    visit.ID,
+		EscortID:   *visit.EscortID,
+		EscortName: visit.EscortName,
+		StartedAt:  visit.SignInTime,
+		AssignedBy: assignedBy,
+	})
+}
+
+// HandOverEscort passes a visitor on site to another escort. The current
+// assignment ends and the new one starts at the same moment.
+func HandOverEscort(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req HandOverEscortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	if !visit.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor is not signed in"})
+		return
+	}
+	escort, ok := resolveEscort(c, req.EscortID, visit.LocationID, nil)
+	if !ok {
+		return
+	}
+
+	assignment := &models.EscortAssignment{
+		VisitID:      visit.ID,
+		EscortID:     escort.ID,
+		EscortName:   escort.Name,
+		StartedAt:    time.Now(),
+		AssignedBy:   actorID(user),
+		HandoverNote: strings.TrimSpace(req.Note),
+	}
+	if err := database.DB.HandOverEscort(assignment); err != nil {
+		switch {
+		case errors.Is(err, database.ErrVisitNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": "Visitor is not signed in"})
+		case errors.Is(err, database.ErrSameEscort):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Escort is already assigned to this visitor"})
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, assignment)
+}
+
+// ListVisitEscorts returns who escorted the visitor during a visit, oldest first
+func ListVisitEscorts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	assignments := database.DB.GetEscortAssignments(map[string]interface{}{"visit_id": visit.ID})
+	for _, a := range assignments {
+		a.Visit = nil
+	}
+	c.JSON(http.StatusOK, assignments)
+}
+
+// ListEscortAssignments returns escort assignments with their visits, oldest first.
+// Query: escort_id, visit_id, current, at (who was escorting at that time) and
+// location_id (super admin only).
+func ListEscortAssignments(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	for _, param := range []string{"escort_id", "visit_id"} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+			filters[param] = uint(id)
+		}
+	}
+	if current := c.Query("current"); current != "" {
+		filters["current"] = current == "true"
+	}
+	if at := c.Query("at"); at != "" {
+		t, ok := parseTimeParam(at)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at time"})
+			return
+		}
+		filters["at"] = t
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetEscortAssignments(filters))
+}
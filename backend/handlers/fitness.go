@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"digital-logbook/database"
+	"digital-logbook/middleware"
 	"digital-logbook/models"
 	"net/http"
 	"strconv"
@@ -18,8 +19,9 @@ type CreateMemberRequest struct {
 }
 
 type CheckInRequest struct {
-	MemberID uint                  `json:"member_id" binding:"required"`
-	Session  models.FitnessSession `json:"session" binding:"required,oneof=morning afternoon evening"`
+	MemberID   uint                  `json:"member_id" binding:"required"`
+	Session    models.FitnessSession `json:"session" binding:"required,oneof=morning afternoon evening"`
+	LocationID uint                  `json:"location_id"` // Super admins only; others check in at their own location
 }
 
 type CheckOutRequest struct {
@@ -125,6 +127,25 @@ func CheckIn(c *gin.Context) {
 		return
 	}
 
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// The location puts the member on that site's muster roll
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else {
+		if req.LocationID != 0 {
+			locationID = req.LocationID
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+			return
+		}
+	}
+
 	now := time.Now()
 	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
@@ -135,10 +156,11 @@ func CheckIn(c *gin.Context) {
 	}
 
 	attendance := &models.FitnessAttendance{
-		MemberID: req.MemberID,
-		Session:  req.Session,
-		Date:     date,
-		CheckIn:  now,
+		MemberID:   req.MemberID,
+		Session:    req.Session,
+		Date:       date,
+		CheckIn:    now,
+		LocationID: &locationID,
 	}
 
 	if err := database.DB.CreateFitnessAttendance(attendance); err != nil {
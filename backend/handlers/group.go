@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"digital-logbook/notify"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GroupMemberRequest is one person in a group. Repeat visitors pass visitor_id;
+// new visitors need a name and ID number.
+type GroupMemberRequest struct {
+	VisitorID   uint               `json:"visitor_id"`
+	Name        string             `json:"name"`
+	IDNumber    string             `json:"id_number"`
+	CompanyFrom string             `json:"company_from"` // Defaults to the group's
+	BadgeNumber string             `json:"badge_number"`
+	Lead        bool               `json:"lead"`     // The first member leads if nobody is marked
+	Override    *WatchlistOverride `json:"override"` // Needed when the member matches a warning entry
+	// Safety inductions and NDAs the member accepted
+	Acknowledgements []AcknowledgementRequest `json:"acknowledgements"`
+	Items            []DeclaredItemRequest    `json:"items"` // Equipment the member brings on site
+	VehicleDetails                            // Members sharing a car declare it once
+}
+
+// CreateVisitGroupRequest signs in a delegation or crew sharing one host, area and purpose
+type CreateVisitGroupRequest struct {
+	Name        string               `json:"name" binding:"required"`
+	AreaOfVisit string               `json:"area_of_visit"` // Free text only where the location has no managed areas
+	AreaID      uint                 `json:"area_id"`
+	Purpose     string               `json:"purpose" binding:"required"`
+	CompanyFrom string               `json:"company_from"`
+	HostName    string               `json:"host_name"`
+	HostID      uint                 `json:"host_id"`
+	EscortID    uint                 `json:"escort_id"` // Escorts the whole group
+	LocationID  uint                 `json:"location_id"`
+	Members     []GroupMemberRequest `json:"members" binding:"required,min=1"`
+	// Optional; the visits default to models.DefaultVisitDuration
+	ExpectedDeparture       *time.Time `json:"expected_departure"`
+	ExpectedDurationMinutes int        `json:"expected_duration_minutes"`
+}
+
+// GroupMemberProblem explains why a member cannot be signed in
+type GroupMemberProblem struct {
+	Index int    `json:"index"` // Position in the members list
+	Name  string `json:"name"`
+	Error string `json:"error"`
+	// Watchlist matches, for members who need an override or are denied entry
+	Watchlist gin.H `json:"watchlist,omitempty"`
+	// Documents the member has yet to accept
+	MissingAcknowledgements []*models.AckDocument `json:"missing_acknowledgements,omitempty"`
+	status                  int
+}
+
+// SignOutGroupRequest lists the badges handed back when the group leaves
+type SignOutGroupRequest struct {
+	ReturnedBadges []string               `json:"returned_badges"` // Omit when every badge was returned
+	Partial        bool                   `json:"partial"`         // Sign out those who returned their badge and leave the rest on site
+	Items          []GroupMemberItemCheck `json:"items"`           // Exit check of the declared items of members leaving
+}
+
+// GroupMemberItemCheck is the exit check of one member's items
+type GroupMemberItemCheck struct {
+	VisitID uint `json:"visit_id"`
+	SignOutVisitorRequest
+}
+
+// GroupSignOutResponse reports the members signed out and those still holding badges
+type GroupSignOutResponse struct {
+	Group         *models.VisitGroup        `json:"group"`
+	SignedOut     []uint                    `json:"signed_out"` // Visit IDs
+	HoldingBadges []models.GroupMemberBadge `json:"holding_badges"`
+}
+
+// notifyGroup tells the group's host about several members in one message
+func notifyGroup(event notify.Event, group *models.VisitGroup, members []*models.Visit) {
+	if len(members) == 0 {
+		return
+	}
+	// Name the lead if they are among the members
+	first := members[0]
+	for _, visit := range members {
+		if visit.VisitorID == group.LeadVisitorID {
+			first = visit
+			break
+		}
+	}
+
+	msg, ok := hostMessage(event, first)
+	if !ok {
+		return
+	}
+	msg.GroupID = group.ID
+	msg.GroupName = group.Name
+	msg.GroupSize = len(members)
+	if msg.GroupSize > 1 {
+		msg.BadgeNumber = ""
+	}
+	notifier.Dispatch(msg)
+}
+
+// groupFromParam loads the group in the URL if the user may access its location
+func groupFromParam(c *gin.Context) (*models.VisitGroup, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	group, err := database.DB.GetVisitGroupByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != group.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit group not found"})
+		return nil, false
+	}
+	return group, true
+}
+
+// checkGroupMembers validates every member before anyone is signed in and
+// returns the profiles to sign in, with zero IDs for new visitors, and each
+// member's acknowledgements. area is nil where the location has no managed areas.
+func checkGroupMembers(c *gin.Context, user *models.User, req *CreateVisitGroupRequest, locationID uint, area *models.Area) ([]*models.Visitor, [][]*pendingAck, []GroupMemberProblem) {
+	members := make([]*models.Visitor, len(req.Members))
+	acks := make([][]*pendingAck, len(req.Members))
+	var problems []GroupMemberProblem
+	problem := func(i int, name string, status int, msg string) {
+		problems = append(problems, GroupMemberProblem{Index: i, Name: name, Error: msg, status: status})
+	}
+
+	seenVisitors := make(map[uint]bool)
+	seenIDNumbers := make(map[string]bool)
+	seenBadges := make(map[string]bool)
+	seenBays := make(map[string]bool)
+	for i, m := range req.Members {
+		var visitor *models.Visitor
+		if m.VisitorID != 0 {
+			existing, err := database.DB.GetVisitorByID(m.VisitorID)
+			if err != nil {
+				problem(i, m.Name, http.StatusBadRequest, "Visitor not found")
+				continue
+			}
+			visitor = existing
+			visitor.CurrentVisit = nil
+		} else {
+			if m.Name == "" || m.IDNumber == "" {
+				problem(i, m.Name, http.StatusBadRequest, "Name and ID number are required for new visitors")
+				continue
+			}
+			company := m.CompanyFrom
+			if company == "" {
+				company = req.CompanyFrom
+			}
+			visitor = &models.Visitor{Name: m.Name, IDNumber: m.IDNumber, CompanyFrom: company}
+		}
+
+		idNumber := models.NormalizeIDNumber(visitor.IDNumber)
+		badgeNumber := models.NormalizeBadgeNumber(m.BadgeNumber)
+		if _, err := newDeclaredItems(m.Items); err != nil {
+			problem(i, visitor.Name, http.StatusBadRequest, "Declared items: "+err.Error())
+			continue
+		}
+		if msg := m.VehicleDetails.problem(); msg != "" {
+			problem(i, visitor.Name, http.StatusBadRequest, msg)
+			continue
+		}
+		bayNumber := models.NormalizeBayNumber(m.ParkingBay)
+		switch {
+		case visitor.ID != 0 && seenVisitors[visitor.ID], idNumber != "" && seenIDNumbers[idNumber]:
+			problem(i, visitor.Name, http.StatusBadRequest, "Visitor is listed more than once")
+			continue
+		case badgeNumber == "":
+			problem(i, visitor.Name, http.StatusBadRequest, "Badge number is required")
+			continue
+		case seenBadges[badgeNumber]:
+			problem(i, visitor.Name, http.StatusBadRequest, "Badge is given to more than one member")
+			continue
+		case bayNumber != "" && seenBays[bayNumber]:
+			problem(i, visitor.Name, http.StatusBadRequest, "Parking bay is given to more than one member")
+			continue
+		}
+		seenVisitors[visitor.ID] = true
+		seenIDNumbers[idNumber] = true
+		seenBadges[badgeNumber] = true
+		if bayNumber != "" {
+			seenBays[bayNumber] = true
+		}
+
+		if visitor.ID != 0 {
+			if _, err := database.DB.GetOpenVisit(visitor.ID); err == nil {
+				problem(i, visitor.Name, http.StatusConflict, "Visitor already signed in")
+				continue
+			}
+		}
+		badge, err := database.DB.GetBadgeByNumber(locationID, badgeNumber)
+		if err != nil {
+			problem(i, visitor.Name, http.StatusBadRequest, "Badge is not in this location's inventory")
+			continue
+		}
+		if badge.Status != models.BadgeAvailable {
+			problem(i, visitor.Name, http.StatusConflict, "Badge is already issued or out of service")
+			continue
+		}
+		if area != nil && !area.Admits(badge) {
+			problem(i, visitor.Name, http.StatusForbidden, "Badge does not grant access to this area")
+			continue
+		}
+		if bayNumber != "" {
+			bay, err := database.DB.GetParkingBayByNumber(locationID, bayNumber)
+			if err != nil {
+				problem(i, visitor.Name, http.StatusBadRequest, "Parking bay is not in this location's inventory")
+				continue
+			}
+			if bay.Status != models.BayAvailable {
+				problem(i, visitor.Name, http.StatusConflict, "Parking bay is occupied or out of service")
+				continue
+			}
+		}
+		if status, response := screenWatchlist(c, user, visitor.ID, visitor.Name, visitor.IDNumber, locationID, m.Override); status != 0 {
+			msg, _ := response["error"].(string)
+			delete(response, "error")
+			problems = append(problems, GroupMemberProblem{Index: i, Name: visitor.Name, Error: msg, Watchlist: response, status: status})
+			continue
+		}
+		pending, ackProblem := checkAcknowledgements(user, visitor.ID, locationID, req.AreaOfVisit, m.Acknowledgements)
+		if ackProblem != nil {
+			problems = append(problems, GroupMemberProblem{
+				Index:                   i,
+				Name:                    visitor.Name,
+				Error:                   ackProblem.message,
+				MissingAcknowledgements: ackProblem.missing,
+				status:                  ackProblem.status,
+			})
+			continue
+		}
+		members[i] = visitor
+		acks[i] = pending
+	}
+	return members, acks, problems
+}
+
+// groupProblemStatus picks the response status for a rejected group: denied
+// entry outranks conflicts, which outrank invalid input
+func groupProblemStatus(problems []GroupMemberProblem) int {
+	status := http.StatusBadRequest
+	for _, p := range problems {
+		switch p.status {
+		case http.StatusForbidden:
+			return http.StatusForbidden
+		case http.StatusConflict:
+			status = http.StatusConflict
+		}
+	}
+	return status
+}
+
+// CreateVisitGroup signs in a group in one step. Every member is validated
+// first; if any cannot be signed in, nobody is (data_entry or admin only).
+func CreateVisitGroup(c *gin.Context) {
+	var req CreateVisitGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+
+	lead := 0
+	leads := 0
+	for i, m := range req.Members {
+		if m.Lead {
+			lead = i
+			leads++
+		}
+	}
+	if leads > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only one member can lead the group"})
+		return
+	}
+
+	signInTime := time.Now()
+	departure, ok := expectedDeparture(c, signInTime, req.ExpectedDeparture, req.ExpectedDurationMinutes)
+	if !ok {
+		return
+	}
+	host, ok := resolveHost(c, req.HostID, locationID)
+	if !ok {
+		return
+	}
+	area, ok := resolveArea(c, locationID, req.AreaID, req.AreaOfVisit)
+	if !ok {
+		return
+	}
+	if !areaOrAbort(c, area, "", len(req.Members)) {
+		return
+	}
+	escort, ok := resolveEscort(c, req.EscortID, locationID, area)
+	if !ok {
+		return
+	}
+	req.AreaOfVisit = areaName(area, req.AreaOfVisit)
+
+	members, acks, problems := checkGroupMembers(c, user, &req, locationID, area)
+	if len(problems) > 0 {
+		c.JSON(groupProblemStatus(problems), gin.H{
+			"error":   fmt.Sprintf("%d of %d members cannot be signed in", len(problems), len(req.Members)),
+			"members": problems,
+		})
+		return
+	}
+
+	group := &models.VisitGroup{
+		Name:        req.Name,
+		AreaOfVisit: req.AreaOfVisit,
+		Purpose:     req.Purpose,
+		HostName:    req.HostName,
+		LocationID:  locationID,
+		CreatedBy:   actorID(user),
+	}
+	if host != nil {
+		group.HostID = &host.ID
+		group.HostName = host.Name
+	}
+	if area != nil {
+		group.AreaID = &area.ID
+	}
+
+	groupVisits := make([]*models.Visit, len(members))
+	for i, m := range req.Members {
+		items, _ := newDeclaredItems(m.Items) // Checked with the members
+		visit := &models.Visit{
+			Purpose:           req.Purpose,
+			HostName:          req.HostName,
+			BadgeNumber:       m.BadgeNumber,
+			Items:             items,
+			ExpectedDeparture: departure,
+		}
+		visit.SignIn(signInTime, actorID(user))
+		setVisitArea(visit, area, req.AreaOfVisit)
+		setVisitEscort(visit, escort)
+		m.VehicleDetails.applyTo(visit)
+		setVisitHost(visit, host)
+		groupVisits[i] = visit
+	}
+
+	var allAcks []*pendingAck
+	for _, pending := range acks {
+		allAcks = append(allAcks, pending...)
+	}
+	ctx := c.Request.Context()
+	if err := storeSignatures(ctx, allAcks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store signature"})
+		return
+	}
+
+	if err := database.DB.CreateVisitGroup(group, members, groupVisits, lead); err != nil {
+		discardSignatures(ctx, allAcks)
+		respondVisitError(c, err, "Failed to sign in group")
+		return
+	}
+	for i, visit := range groupVisits {
+		recordAcknowledgements(ctx, acks[i], visit.VisitorID, visit.ID)
+		recordEscort(visit, actorID(user))
+	}
+	notifyGroup(notify.EventVisitorArrived, group, groupVisits)
+
+	created, err := database.DB.GetVisitGroupByID(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load group"})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// ListVisitGroups returns groups, newest first. Query: on_site, location_id (super admin only).
+func ListVisitGroups(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if onSite := c.Query("on_site"); onSite != "" {
+		filters["on_site"] = onSite == "true"
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllVisitGroups(filters))
+}
+
+// GetVisitGroup returns a group with its members' visits
+func GetVisitGroup(c *gin.Context) {
+	group, ok := groupFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// checkGroupItems prepares the exit check of each leaving member's items. If
+// any member cannot sign out, nobody does and every such member is reported.
+func checkGroupItems(c *gin.Context, user *models.User, leaving []*models.Visit, reqs []GroupMemberItemCheck) ([]*itemCheck, bool) {
+	checks := make(map[uint]*SignOutVisitorRequest, len(reqs))
+	for i := range reqs {
+		checks[reqs[i].VisitID] = &reqs[i].SignOutVisitorRequest
+	}
+
+	items := make([]*itemCheck, len(leaving))
+	status := http.StatusConflict
+	var problems []gin.H
+	for i, visit := range leaving {
+		req, exists := checks[visit.ID]
+		if !exists {
+			req = &SignOutVisitorRequest{}
+		}
+		delete(checks, visit.ID)
+
+		check, problemStatus, problem := prepareItemCheck(user, visit, req)
+		if problemStatus != 0 {
+			problem["visit_id"] = visit.ID
+			if visit.Visitor != nil {
+				problem["visitor_name"] = visit.Visitor.Name
+			}
+			if problemStatus == http.StatusBadRequest {
+				status = http.StatusBadRequest
+			}
+			problems = append(problems, problem)
+			continue
+		}
+		items[i] = check
+	}
+	for id := range checks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Visit %d is not a member leaving with the group", id)})
+		return nil, false
+	}
+	if len(problems) > 0 {
+		c.JSON(status, gin.H{
+			"error":   "Check the declared items of these members before signing the group out",
+			"members": problems,
+		})
+		return nil, false
+	}
+	return items, true
+}
+
+// SignOutVisitGroup signs out every member still on site. When returned_badges
+// is given and some members have not handed theirs back, nobody is signed out
+// and those members are reported, unless partial is set.
+func SignOutVisitGroup(c *gin.Context) {
+	group, ok := groupFromParam(c)
+	if !ok {
+		return
+	}
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req SignOutGroupRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var returned map[string]bool
+	if req.ReturnedBadges != nil {
+		returned = make(map[string]bool, len(req.ReturnedBadges))
+		for _, number := range req.ReturnedBadges {
+			returned[models.NormalizeBadgeNumber(number)] = true
+		}
+	}
+
+	var leaving []*models.Visit
+	holding := make([]models.GroupMemberBadge, 0)
+	for _, visit := range group.Members {
+		if !visit.IsActive() {
+			continue
+		}
+		if returned != nil && visit.BadgeNumber != "" && !returned[visit.BadgeNumber] {
+			entry := models.GroupMemberBadge{
+				VisitID:     visit.ID,
+				VisitorID:   visit.VisitorID,
+				BadgeNumber: visit.BadgeNumber,
+			}
+			if visit.Visitor != nil {
+				entry.VisitorName = visit.Visitor.Name
+			}
+			holding = append(holding, entry)
+			continue
+		}
+		leaving = append(leaving, visit)
+	}
+
+	if len(leaving) == 0 && len(holding) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Group already signed out"})
+		return
+	}
+	if len(holding) > 0 && !req.Partial {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":          "Some members still hold badges; pass partial to sign out the others",
+			"holding_badges": holding,
+		})
+		return
+	}
+
+	items, ok := checkGroupItems(c, user, leaving, req.Items)
+	if !ok {
+		return
+	}
+
+	signedOut := make([]uint, 0, len(leaving))
+	departed := make([]*models.Visit, 0, len(leaving))
+	for i, visit := range leaving {
+		if err := closeVisit(visit, user, items[i]); err != nil {
+			var transition *models.TransitionError
+			if errors.As(err, &transition) {
+				// Signed out meanwhile by someone else
+				continue
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out visitor", "signed_out": signedOut})
+			return
+		}
+		signedOut = append(signedOut, visit.ID)
+		departed = append(departed, visit)
+	}
+	notifyGroup(notify.EventVisitorDeparted, group, departed)
+
+	updated, err := database.DB.GetVisitGroupByID(group.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load group"})
+		return
+	}
+	c.JSON(http.StatusOK, GroupSignOutResponse{
+		Group:         updated,
+		SignedOut:     signedOut,
+		HoldingBadges: holding,
+	})
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/jobs"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"digital-logbook/notify"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateHostRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Department string `json:"department"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	Phone      string `json:"phone"`
+	LocationID uint   `json:"location_id"`
+}
+
+type UpdateHostRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Department string `json:"department"`
+	Email      string `json:"email" binding:"omitempty,email"`
+	Phone      string `json:"phone"`
+	Active     *bool  `json:"active"`
+}
+
+// notifier is nil when host notifications are not configured
+var notifier *notify.Dispatcher
+
+// ConfigureNotifier enables host notifications on sign-in and sign-out,
+// including the end-of-day sweep's auto sign-outs
+func ConfigureNotifier(dispatcher *notify.Dispatcher) {
+	notifier = dispatcher
+	jobs.ConfigureDepartures(func(visit *models.Visit) {
+		notifyHost(notify.EventVisitorDeparted, visit)
+	})
+}
+
+// notifyHost tells the visit's host that their visitor arrived or left
+func notifyHost(event notify.Event, visit *models.Visit) {
+	if msg, ok := hostMessage(event, visit); ok {
+		notifier.Dispatch(msg)
+	}
+}
+
+// hostMessage describes the visit for its host. It returns false when
+// notifications are off or the visit has no host in the directory.
+func hostMessage(event notify.Event, visit *models.Visit) (notify.Message, bool) {
+	if notifier == nil || visit.HostID == nil {
+		return notify.Message{}, false
+	}
+	host, err := database.DB.GetHostByID(*visit.HostID)
+	if err != nil {
+		return notify.Message{}, false
+	}
+
+	msg := notify.Message{
+		Event:       event,
+		Time:        time.Now(),
+		HostID:      host.ID,
+		HostName:    host.Name,
+		HostEmail:   host.Email,
+		HostPhone:   host.Phone,
+		VisitID:     visit.ID,
+		AreaOfVisit: visit.AreaOfVisit,
+		Purpose:     visit.Purpose,
+		BadgeNumber: visit.BadgeNumber,
+		LocationID:  visit.LocationID,
+	}
+	if visitor, err := database.DB.GetVisitorByID(visit.VisitorID); err == nil {
+		msg.VisitorName = visitor.Name
+		msg.VisitorCompany = visitor.CompanyFrom
+	}
+	if host.Location != nil {
+		msg.LocationName = host.Location.Name
+	}
+	return msg, true
+}
+
+// resolveHost checks that the host picked for a visit is active at the
+// visit's location. A zero hostID means no host was picked.
+func resolveHost(c *gin.Context, hostID, locationID uint) (*models.Host, bool) {
+	if hostID == 0 {
+		return nil, true
+	}
+	host, err := database.DB.GetHostByID(hostID)
+	if err != nil || host.LocationID != locationID || !host.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Host not found at this location"})
+		return nil, false
+	}
+	return host, true
+}
+
+// setVisitHost links the visit to the host, keeping host_name in step
+func setVisitHost(visit *models.Visit, host *models.Host) {
+	if host == nil {
+		return
+	}
+	visit.HostID = &host.ID
+	visit.HostName = host.Name
+	visit.Host = host
+}
+
+// hostFromParam loads the host in the URL if the user may access its location
+func hostFromParam(c *gin.Context) (*models.Host, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	host, err := database.DB.GetHostByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != host.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not found"})
+		return nil, false
+	}
+	return host, true
+}
+
+// ListHosts returns the host directory. Query: q, department, active, location_id (super admin only).
+func ListHosts(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if q := c.Query("q"); q != "" {
+		filters["q"] = q
+	}
+	if department := c.Query("department"); department != "" {
+		filters["department"] = department
+	}
+	if active := c.Query("active"); active != "" {
+		filters["active"] = active == "true"
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllHosts(filters))
+}
+
+// GetHost returns a specific host by ID
+func GetHost(c *gin.Context) {
+	host, ok := hostFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, host)
+}
+
+// CreateHost adds an employee to a location's host directory (admin only)
+func CreateHost(c *gin.Context) {
+	var req CreateHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+	if _, err := database.DB.GetLocationByID(locationID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+		return
+	}
+
+	host := &models.Host{
+		LocationID: locationID,
+		Name:       req.Name,
+		Department: req.Department,
+		Email:      req.Email,
+		Phone:      req.Phone,
+		Active:     true,
+	}
+
+	if err := database.DB.CreateHost(host); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create host"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, host)
+}
+
+// UpdateHost updates a host's details or deactivates them (admin only)
+func UpdateHost(c *gin.Context) {
+	host, ok := hostFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	host.Name = req.Name
+	host.Department = req.Department
+	host.Email = req.Email
+	host.Phone = req.Phone
+	if req.Active != nil {
+		host.Active = *req.Active
+	}
+
+	if err := database.DB.UpdateHost(host); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update host"})
+		return
+	}
+
+	c.JSON(http.StatusOK, host)
+}
+
+// DeleteHost removes a host from the directory (admin only). Past visits keep the host's name.
+func DeleteHost(c *gin.Context) {
+	host, ok := hostFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.DeleteHost(host.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete host"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Host deleted successfully"})
+}
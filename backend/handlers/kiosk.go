@@ -0,0 +1,475 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KioskSubmitRequest is what a visitor enters about themselves at a kiosk
+type KioskSubmitRequest struct {
+	Name        string `json:"name" binding:"required"`
+	IDNumber    string `json:"id_number" binding:"required"`
+	CompanyFrom string `json:"company_from"`
+	AreaOfVisit string `json:"area_of_visit"` // Free text only where the location has no managed areas
+	AreaID      uint   `json:"area_id"`
+	Purpose     string `json:"purpose" binding:"required"`
+	HostName    string `json:"host_name"`
+	HostID      uint   `json:"host_id"` // From the kiosk directory; sets host_name
+}
+
+// KioskAcknowledgeRequest accepts the documents a kiosk visitor was shown
+type KioskAcknowledgeRequest struct {
+	Acknowledgements []AcknowledgementRequest `json:"acknowledgements" binding:"required"`
+}
+
+// KioskCheckInRequest checks a pre-registered visitor in at a kiosk
+type KioskCheckInRequest struct {
+	IDNumber string `json:"id_number" binding:"required"`
+}
+
+// KioskOption is an area or host a visitor can pick at a kiosk
+type KioskOption struct {
+	ID         uint   `json:"id"`
+	Name       string `json:"name"`
+	Department string `json:"department,omitempty"`
+}
+
+// KioskDirectory lists the areas and hosts at the kiosk's location, without
+// contact details
+type KioskDirectory struct {
+	Areas []KioskOption `json:"areas"`
+	Hosts []KioskOption `json:"hosts"`
+}
+
+// KioskSubmission is what a kiosk is shown of a visit checked in there
+type KioskSubmission struct {
+	VisitID     uint                 `json:"visit_id"`
+	Status      models.VisitorStatus `json:"status"`
+	AreaOfVisit string               `json:"area_of_visit"`
+	HostName    string               `json:"host_name"`
+	// Documents the visitor still has to accept, while the visit awaits an operator
+	MissingAcknowledgements []*models.AckDocument `json:"missing_acknowledgements"`
+}
+
+// KioskPreregistration is an expected visit as listed at a kiosk
+type KioskPreregistration struct {
+	VisitID          uint       `json:"visit_id"`
+	VisitorName      string     `json:"visitor_name"`
+	HostName         string     `json:"host_name"`
+	AreaOfVisit      string     `json:"area_of_visit"`
+	ExpectedFrom     *time.Time `json:"expected_from"`
+	ExpectedUntil    *time.Time `json:"expected_until"`
+	AwaitingApproval bool       `json:"awaiting_approval"`
+	// Made without an ID number, so the visitor checks in at reception
+	SeeReception bool `json:"see_reception"`
+}
+
+// kioskFromContext returns the kiosk's principal, its service account and the
+// location it is bound to, as set by middleware.KioskAuth
+func kioskFromContext(c *gin.Context) (*models.User, *models.ServiceAccount, uint, bool) {
+	user, err := middleware.GetCurrentUser(c)
+	account, ok := middleware.GetServiceAccount(c)
+	if err != nil || !ok || user.LocationID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Kiosk not authenticated"})
+		return nil, nil, 0, false
+	}
+	return user, account, *user.LocationID, true
+}
+
+// kioskSubmission builds the kiosk's view of a visit
+func kioskSubmission(visit *models.Visit) *KioskSubmission {
+	submission := &KioskSubmission{
+		VisitID:                 visit.ID,
+		Status:                  visit.Status,
+		AreaOfVisit:             visit.AreaOfVisit,
+		HostName:                visit.HostName,
+		MissingAcknowledgements: make([]*models.AckDocument, 0),
+	}
+	if visit.IsPending() {
+		submission.MissingAcknowledgements = append(submission.MissingAcknowledgements,
+			database.DB.MissingAcknowledgements(visit.VisitorID, visit.LocationID, visit.AreaOfVisit, time.Now())...)
+	}
+	return submission
+}
+
+// kioskVisitFromParam loads the visit in the URL if it was checked in at this kiosk
+func kioskVisitFromParam(c *gin.Context) (*models.Visit, *models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, nil, false
+	}
+
+	user, account, locationID, ok := kioskFromContext(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || visit.LocationID != locationID || visit.KioskID == nil || *visit.KioskID != account.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return nil, nil, false
+	}
+	return visit, user, true
+}
+
+// GetKioskDirectory lists the active areas and hosts a visitor can pick
+func GetKioskDirectory(c *gin.Context) {
+	_, _, locationID, ok := kioskFromContext(c)
+	if !ok {
+		return
+	}
+
+	directory := KioskDirectory{Areas: make([]KioskOption, 0), Hosts: make([]KioskOption, 0)}
+	filters := map[string]interface{}{"location_id": locationID, "active": true}
+	for _, area := range database.DB.GetAllAreas(filters) {
+		directory.Areas = append(directory.Areas, KioskOption{ID: area.ID, Name: area.Name})
+	}
+	for _, host := range database.DB.GetAllHosts(filters) {
+		directory.Hosts = append(directory.Hosts, KioskOption{ID: host.ID, Name: host.Name, Department: host.Department})
+	}
+	c.JSON(http.StatusOK, directory)
+}
+
+// SubmitKioskVisit records a walk-in visitor's own details as a pending visit
+// for an operator to confirm. A returning visitor is linked to their profile
+// by ID number; the kiosk never changes a stored profile. Watchlist screening,
+// badges and equipment are left to the operator.
+func SubmitKioskVisit(c *gin.Context) {
+	var req KioskSubmitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, account, locationID, ok := kioskFromContext(c)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	idNumber := strings.TrimSpace(req.IDNumber)
+	if name == "" || idNumber == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and ID number are required"})
+		return
+	}
+	area, ok := resolveArea(c, locationID, req.AreaID, req.AreaOfVisit)
+	if !ok {
+		return
+	}
+	host, ok := resolveHost(c, req.HostID, locationID)
+	if !ok {
+		return
+	}
+
+	var visitor *models.Visitor
+	if profiles := database.DB.GetVisitorsByIDNumber(idNumber); len(profiles) > 0 {
+		visitor = profiles[0]
+	}
+	isNewVisitor := visitor == nil
+	if isNewVisitor {
+		visitor = &models.Visitor{
+			Name:        name,
+			IDNumber:    idNumber,
+			CompanyFrom: strings.TrimSpace(req.CompanyFrom),
+		}
+		if err := database.DB.CreateVisitor(visitor); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record your details"})
+			return
+		}
+	}
+
+	visit := &models.Visit{
+		VisitorID:  visitor.ID,
+		Purpose:    req.Purpose,
+		HostName:   req.HostName,
+		KioskID:    &account.ID,
+		LocationID: locationID,
+	}
+	visit.Submit(time.Now())
+	setVisitArea(visit, area, req.AreaOfVisit)
+	setVisitHost(visit, host)
+
+	if err := database.DB.CreateVisit(visit); err != nil {
+		if isNewVisitor {
+			database.DB.DeleteVisitor(visitor.ID)
+		}
+		respondVisitError(c, err, "Failed to record your details")
+		return
+	}
+
+	c.JSON(http.StatusCreated, kioskSubmission(visit))
+}
+
+// GetKioskVisit shows a visit checked in at this kiosk, so the kiosk can tell
+// the visitor once reception has signed them in
+func GetKioskVisit(c *gin.Context) {
+	visit, _, ok := kioskVisitFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, kioskSubmission(visit))
+}
+
+// AcknowledgeKioskVisit records the safety inductions and NDAs a visitor
+// accepted at the kiosk, so the operator need not collect them again
+func AcknowledgeKioskVisit(c *gin.Context) {
+	visit, user, ok := kioskVisitFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req KioskAcknowledgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !visit.IsPending() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visit is " + string(visit.Status)})
+		return
+	}
+	acks, ok := acceptAcknowledgements(c, user, visit.VisitorID, visit.LocationID, visit.AreaOfVisit, req.Acknowledgements)
+	if !ok {
+		return
+	}
+	recordAcknowledgements(c.Request.Context(), acks, visit.VisitorID, visit.ID)
+
+	c.JSON(http.StatusOK, kioskSubmission(visit))
+}
+
+// LookupKioskPreregistrations finds the visitor's pre-registrations for today
+// at the kiosk's location by their ID number, or by name for pre-registrations
+// made without one
+func LookupKioskPreregistrations(c *gin.Context) {
+	_, _, locationID, ok := kioskFromContext(c)
+	if !ok {
+		return
+	}
+
+	idNumber := models.NormalizeIDNumber(c.Query("id_number"))
+	name := strings.TrimSpace(c.Query("name"))
+	if idNumber == "" && name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_number or name is required"})
+		return
+	}
+
+	now := time.Now()
+	year, month, day := now.Date()
+	dayEnd := time.Date(year, month, day, 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+
+	filters := map[string]interface{}{"location_id": locationID, "status": string(models.StatusExpected)}
+	result := make([]*KioskPreregistration, 0)
+	for _, visit := range database.DB.GetAllVisits(filters) {
+		if visit.Visitor == nil || !visit.ExpectedFrom.Before(dayEnd) || visit.ExpectedUntil.Before(now) {
+			continue
+		}
+		if visit.Visitor.IDNumber != "" {
+			if idNumber == "" || models.NormalizeIDNumber(visit.Visitor.IDNumber) != idNumber {
+				continue
+			}
+		} else if name == "" || !strings.EqualFold(visit.Visitor.Name, name) {
+			continue
+		}
+		result = append(result, &KioskPreregistration{
+			VisitID:          visit.ID,
+			VisitorName:      visit.Visitor.Name,
+			HostName:         visit.HostName,
+			AreaOfVisit:      visit.AreaOfVisit,
+			ExpectedFrom:     visit.ExpectedFrom,
+			ExpectedUntil:    visit.ExpectedUntil,
+			AwaitingApproval: visit.AwaitingApproval(),
+			SeeReception:     visit.Visitor.IDNumber == "",
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ExpectedFrom.Before(*result[j].ExpectedFrom)
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// CheckInKioskPreregistration marks an expected visitor as waiting at
+// reception, for an operator to confirm
+func CheckInKioskPreregistration(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req KioskCheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, account, locationID, ok := kioskFromContext(c)
+	if !ok {
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || visit.LocationID != locationID || visit.ExpectedUntil == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pre-registration not found"})
+		return
+	}
+	switch {
+	case !visit.IsExpected():
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is " + string(visit.Status)})
+		return
+	case visit.AwaitingApproval():
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is awaiting approval; please see reception"})
+		return
+	case visit.Visitor == nil || visit.Visitor.IDNumber == "":
+		// Found by name only; nothing the kiosk can check ties the visitor to it
+		c.JSON(http.StatusConflict, gin.H{"error": "Your details need to be confirmed; please see reception"})
+		return
+	case models.NormalizeIDNumber(visit.Visitor.IDNumber) != models.NormalizeIDNumber(req.IDNumber):
+		c.JSON(http.StatusConflict, gin.H{"error": "ID number does not match the pre-registration"})
+		return
+	}
+
+	if err := visit.Submit(time.Now()); err != nil {
+		respondVisitError(c, err, "Failed to check in")
+		return
+	}
+	visit.KioskID = &account.ID
+	if err := database.DB.UpdateVisit(visit); err != nil {
+		respondVisitError(c, err, "Failed to check in")
+		return
+	}
+
+	c.JSON(http.StatusOK, kioskSubmission(visit))
+}
+
+// KioskSignOut signs out the visitor whose badge was scanned at the kiosk.
+// Visitors with equipment still to be checked are sent to reception.
+func KioskSignOut(c *gin.Context) {
+	if badgeSigner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Badge printing is not configured"})
+		return
+	}
+
+	var req SignOutByQRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _, locationID, ok := kioskFromContext(c)
+	if !ok {
+		return
+	}
+
+	visitID, ok := verifyBadgeCode(c, req.Code, "Badge has expired; please sign out at reception")
+	if !ok {
+		return
+	}
+	visit, err := database.DB.GetVisitByID(visitID)
+	if err != nil || visit.LocationID != locationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	if !visit.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already signed out"})
+		return
+	}
+	if len(pendingItems(visit)) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Please sign out at reception so your equipment can be checked"})
+		return
+	}
+
+	if err := signOutVisit(visit, user, &itemCheck{}); err != nil {
+		var transition *models.TransitionError
+		if errors.As(err, &transition) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already signed out"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"visit_id":      visit.ID,
+		"status":        visit.Status,
+		"sign_out_time": visit.SignOutTime,
+	})
+}
+
+// ConfirmKioskVisit signs in a visitor who checked in at a kiosk, once an
+// operator has checked their ID and issued a badge
+func ConfirmKioskVisit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req ArriveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	if !visit.IsPending() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visit is " + string(visit.Status) + ", not waiting for confirmation"})
+		return
+	}
+
+	signInWaitingVisit(c, visit, user, &req)
+}
+
+// DenyVisit turns away a visitor who checked in at a kiosk or was pre-registered
+func DenyVisit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req DenyVisitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	if err := denyVisit(visit, req.Reason, user); err != nil {
+		respondVisitError(c, err, "Failed to deny visit")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
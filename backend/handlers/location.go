@@ -5,20 +5,44 @@ import (
 	"digital-logbook/models"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type CreateLocationRequest struct {
-	Name    string `json:"name" binding:"required"`
-	Code    string `json:"code" binding:"required"`
-	Address string `json:"address"`
+	Name         string `json:"name" binding:"required"`
+	Code         string `json:"code" binding:"required"`
+	Address      string `json:"address"`
+	EndOfDayTime string `json:"end_of_day_time"` // HH:MM; empty disables the end-of-day sweep
+	Timezone     string `json:"timezone"`
 }
 
+// UpdateLocationRequest overrides the fields that are set. The sweep settings
+// are pointers so they can be cleared with an empty string.
 type UpdateLocationRequest struct {
-	Name    string `json:"name"`
-	Code    string `json:"code"`
-	Address string `json:"address"`
+	Name         string  `json:"name"`
+	Code         string  `json:"code"`
+	Address      string  `json:"address"`
+	EndOfDayTime *string `json:"end_of_day_time"`
+	Timezone     *string `json:"timezone"`
+}
+
+// validateSweepSettings checks a location's end-of-day time and time zone
+func validateSweepSettings(c *gin.Context, endOfDayTime, timezone string) bool {
+	if endOfDayTime != "" {
+		if _, err := time.Parse("15:04", endOfDayTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "End of day time must be HH:MM"})
+			return false
+		}
+	}
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
+			return false
+		}
+	}
+	return true
 }
 
 // CreateLocation creates a new location (admin only)
@@ -30,9 +54,14 @@ func CreateLocation(c *gin.Context) {
 	}
 
 	location := &models.Location{
-		Name:    req.Name,
-		Code:    req.Code,
-		Address: req.Address,
+		Name:         req.Name,
+		Code:         req.Code,
+		Address:      req.Address,
+		EndOfDayTime: req.EndOfDayTime,
+		Timezone:     req.Timezone,
+	}
+	if !validateSweepSettings(c, req.EndOfDayTime, req.Timezone) {
+		return
 	}
 
 	if err := database.DB.CreateLocation(location); err != nil {
@@ -86,6 +115,17 @@ func UpdateLocation(c *gin.Context) {
 		return
 	}
 
+	endOfDayTime, timezone := location.EndOfDayTime, location.Timezone
+	if req.EndOfDayTime != nil {
+		endOfDayTime = *req.EndOfDayTime
+	}
+	if req.Timezone != nil {
+		timezone = *req.Timezone
+	}
+	if !validateSweepSettings(c, endOfDayTime, timezone) {
+		return
+	}
+
 	if req.Name != "" {
 		location.Name = req.Name
 	}
@@ -95,6 +135,8 @@ func UpdateLocation(c *gin.Context) {
 	if req.Address != "" {
 		location.Address = req.Address
 	}
+	location.EndOfDayTime = endOfDayTime
+	location.Timezone = timezone
 
 	if err := database.DB.UpdateLocation(location); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
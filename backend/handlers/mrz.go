@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"digital-logbook/middleware"
+	"digital-logbook/mrz"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ParseMRZRequest struct {
+	MRZ           string `json:"mrz" binding:"required"` // The 2 or 3 lines as read by the scanner
+	RejectExpired bool   `json:"reject_expired"`
+}
+
+// MRZPrefill holds the CreateVisitorRequest fields taken from the document
+type MRZPrefill struct {
+	VisitorID   uint   `json:"visitor_id,omitempty"` // Set when a profile has the document number
+	Name        string `json:"name"`
+	IDNumber    string `json:"id_number"`
+	CompanyFrom string `json:"company_from,omitempty"` // From the existing profile
+}
+
+// MRZResponse is a scanned document, ready to sign the visitor in
+type MRZResponse struct {
+	Document *mrz.Document          `json:"document"`
+	Expired  bool                   `json:"expired"`
+	Prefill  MRZPrefill             `json:"prefill"`
+	Existing *VisitorLookupResponse `json:"existing,omitempty"` // As returned by GET /api/visitors/lookup
+}
+
+// ParseVisitorMRZ reads the machine readable zone of an ID card or passport
+// so the visitor's name and ID number need not be typed in
+func ParseVisitorMRZ(c *gin.Context) {
+	var req ParseMRZRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	now := time.Now()
+	doc, err := mrz.Parse(req.MRZ, now)
+	if err != nil {
+		var checkErr *mrz.CheckDigitError
+		if errors.As(err, &checkErr) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "MRZ check digits do not match; rescan the document",
+				"fields": checkErr.Fields,
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expired := doc.Expired(now)
+	if expired && req.RejectExpired {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "Document expired on " + doc.ExpiryDate.Format("2006-01-02"),
+			"expiry_date": doc.ExpiryDate,
+		})
+		return
+	}
+
+	response := MRZResponse{
+		Document: doc,
+		Expired:  expired,
+		Prefill: MRZPrefill{
+			Name:     doc.FullName(),
+			IDNumber: doc.DocumentNumber,
+		},
+	}
+	if existing := lookupVisitor(user, doc.DocumentNumber); existing != nil {
+		// Keep the profile's details so repeat visits link up
+		response.Existing = existing
+		response.Prefill.VisitorID = existing.Visitor.ID
+		response.Prefill.Name = existing.Visitor.Name
+		response.Prefill.CompanyFrom = existing.Visitor.CompanyFrom
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MarkAccountedRequest marks a person on the muster roll during a drill.
+// Accounted defaults to true; send false to undo a mistaken mark.
+type MarkAccountedRequest struct {
+	Type      models.MusterPersonType `json:"type" binding:"required,oneof=visitor fitness driver"`
+	RecordID  uint                    `json:"record_id" binding:"required"`
+	Accounted *bool                   `json:"accounted"`
+}
+
+// musterLocation loads the location in the URL if the user may see it
+func musterLocation(c *gin.Context) (*models.Location, *models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	location, err := database.DB.GetLocationByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != location.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return nil, nil, false
+	}
+	return location, user, true
+}
+
+// buildMusterRoll lists everyone on site at a location: signed-in visitors,
+// gym members checked in today who have not checked out and drivers whose cargo is not released.
+// People accounted for in the running drill, if any, are marked.
+func buildMusterRoll(location *models.Location) *models.MusterRoll {
+	var people []models.MusterPerson
+
+	for _, visit := range database.DB.GetAllVisits(map[string]interface{}{
+		"location_id": location.ID,
+		"status":      string(models.StatusSignedIn),
+	}) {
+		person := models.MusterPerson{
+			Type:        models.MusterVisitor,
+			RecordID:    visit.ID,
+			Area:        visit.AreaOfVisit,
+			BadgeNumber: visit.BadgeNumber,
+			Since:       visit.SignInTime,
+		}
+		if visit.Visitor != nil {
+			person.Name = visit.Visitor.Name
+			person.IDNumber = visit.Visitor.IDNumber
+			person.Company = visit.Visitor.CompanyFrom
+		}
+		people = append(people, person)
+	}
+
+	// Gym sessions are daily; a check-in left open from an earlier day is
+	// not taken as the member still being on site
+	for _, attendance := range database.DB.GetAllFitnessAttendance(map[string]interface{}{
+		"location_id": location.ID,
+		"open":        true,
+		"date":        time.Now().Format("2006-01-02"),
+	}) {
+		person := models.MusterPerson{
+			Type:     models.MusterFitness,
+			RecordID: attendance.ID,
+			Area:     models.MusterAreaFitness,
+			Since:    attendance.CheckIn,
+		}
+		if attendance.Member != nil {
+			person.Name = attendance.Member.Name
+			person.IDNumber = attendance.Member.IDNumber
+			person.Company = attendance.Member.Company
+		}
+		people = append(people, person)
+	}
+
+	for _, entry := range database.DB.GetAllCargo(map[string]interface{}{
+		"location_id": location.ID,
+		"released":    false,
+	}) {
+		people = append(people, models.MusterPerson{
+			Type:     models.MusterDriver,
+			RecordID: entry.ID,
+			Name:     entry.DriverName,
+			Company:  entry.Company,
+			Area:     models.MusterAreaCargo,
+			Vehicle:  entry.VehicleRegistration,
+			Since:    entry.TimeIn,
+		})
+	}
+
+	roll := &models.MusterRoll{
+		Location:    location,
+		GeneratedAt: time.Now(),
+		Total:       len(people),
+		Areas:       []models.MusterArea{},
+	}
+	if drill, err := database.DB.GetActiveDrill(location.ID); err == nil {
+		roll.Drill = drill
+		for i := range people {
+			if mark, ok := drill.Mark(people[i].Type, people[i].RecordID); ok {
+				accountedAt := mark.AccountedAt
+				people[i].AccountedFor = true
+				people[i].AccountedAt = &accountedAt
+				roll.AccountedFor++
+			}
+		}
+	}
+
+	sort.Slice(people, func(i, j int) bool {
+		if people[i].Area != people[j].Area {
+			return people[i].Area < people[j].Area
+		}
+		return people[i].Name < people[j].Name
+	})
+	for _, person := range people {
+		if n := len(roll.Areas); n == 0 || roll.Areas[n-1].Area != person.Area {
+			roll.Areas = append(roll.Areas, models.MusterArea{Area: person.Area})
+		}
+		area := &roll.Areas[len(roll.Areas)-1]
+		area.People = append(area.People, person)
+	}
+	return roll
+}
+
+// GetMusterRoll returns everyone currently on site at a location, grouped by area
+func GetMusterRoll(c *gin.Context) {
+	location, _, ok := musterLocation(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, buildMusterRoll(location))
+}
+
+var musterTemplate = template.Must(template.New("muster").Funcs(template.FuncMap{
+	"clock": func(t time.Time, loc *time.Location) string { return t.In(loc).Format("15:04") },
+	"stamp": func(t time.Time, loc *time.Location) string { return t.In(loc).Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Muster roll - {{.Roll.Location.Name}}</title>
+<style>
+body { font-family: sans-serif; font-size: 12px; margin: 16px; }
+h1 { font-size: 18px; margin: 0 0 4px; }
+h2 { font-size: 14px; margin: 16px 0 4px; page-break-after: avoid; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #444; padding: 4px 6px; text-align: left; }
+th { background: #eee; }
+td.check { width: 24px; text-align: center; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Muster roll - {{.Roll.Location.Name}} ({{.Roll.Location.Code}})</h1>
+<p>Generated {{stamp .Roll.GeneratedAt .TZ}}. {{.Roll.Total}} on site{{if .Roll.Drill}}, {{.Roll.AccountedFor}} accounted for in the drill started {{clock .Roll.Drill.StartedAt .TZ}}{{end}}.</p>
+{{range .Roll.Areas}}
+<h2>{{.Area}} ({{len .People}})</h2>
+<table>
+<tr><th></th><th>Name</th><th>Type</th><th>ID number</th><th>Company</th><th>Badge / vehicle</th><th>Since</th></tr>
+{{range .People}}<tr><td class="check">{{if .AccountedFor}}&#10003;{{else}}&#9744;{{end}}</td><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.IDNumber}}</td><td>{{.Company}}</td><td>{{.BadgeNumber}}{{.Vehicle}}</td><td>{{clock .Since $.TZ}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>Nobody is on site.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// ExportMusterRoll returns the muster roll as a printable HTML page (default) or as CSV
+func ExportMusterRoll(c *gin.Context) {
+	location, _, ok := musterLocation(c)
+	if !ok {
+		return
+	}
+	roll := buildMusterRoll(location)
+	tz := location.TimeLocation()
+	filename := fmt.Sprintf("muster-%s-%s", location.Code, roll.GeneratedAt.In(tz).Format("20060102-1504"))
+
+	switch c.DefaultQuery("format", "html") {
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		musterTemplate.Execute(c.Writer, gin.H{"Roll": roll, "TZ": tz})
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Status(http.StatusOK)
+		w := csv.NewWriter(c.Writer)
+		w.Write([]string{"area", "type", "record_id", "name", "id_number", "company", "badge_number", "vehicle", "since", "accounted_for"})
+		for _, area := range roll.Areas {
+			for _, p := range area.People {
+				w.Write([]string{
+					p.Area, string(p.Type), strconv.FormatUint(uint64(p.RecordID), 10), p.Name, p.IDNumber, p.Company,
+					p.BadgeNumber, p.Vehicle, p.Since.In(tz).Format("2006-01-02 15:04"), strconv.FormatBool(p.AccountedFor),
+				})
+			}
+		}
+		w.Flush()
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be html or csv"})
+	}
+}
+
+// StartDrill starts an evacuation drill at a location so people can be marked as accounted for
+func StartDrill(c *gin.Context) {
+	location, user, ok := musterLocation(c)
+	if !ok {
+		return
+	}
+
+	drill := &models.Drill{
+		LocationID: location.ID,
+		StartedBy:  user.ID,
+	}
+	if err := database.DB.CreateDrill(drill); err != nil {
+		if errors.Is(err, database.ErrDrillInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A drill is already in progress at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start drill"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, buildMusterRoll(location))
+}
+
+// MarkAccounted records that a person on the muster roll has been accounted for in the running drill
+func MarkAccounted(c *gin.Context) {
+	location, user, ok := musterLocation(c)
+	if !ok {
+		return
+	}
+
+	var req MarkAccountedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roll := buildMusterRoll(location)
+	if roll.Drill == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No drill is in progress at this location"})
+		return
+	}
+	var person *models.MusterPerson
+	for _, area := range roll.Areas {
+		for i := range area.People {
+			if area.People[i].Type == req.Type && area.People[i].RecordID == req.RecordID {
+				person = &area.People[i]
+			}
+		}
+	}
+	if person == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Person is not on the muster roll"})
+		return
+	}
+
+	accounted := req.Accounted == nil || *req.Accounted
+	mark := models.DrillMark{
+		Type:        req.Type,
+		RecordID:    req.RecordID,
+		AccountedAt: time.Now(),
+		AccountedBy: user.ID,
+	}
+	if _, err := database.DB.MarkAccounted(roll.Drill.ID, mark, accounted); err != nil {
+		if errors.Is(err, database.ErrDrillEnded) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No drill is in progress at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update muster roll"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildMusterRoll(location))
+}
+
+// EndDrill ends the running drill, recording who was still unaccounted for
+func EndDrill(c *gin.Context) {
+	location, user, ok := musterLocation(c)
+	if !ok {
+		return
+	}
+
+	roll := buildMusterRoll(location)
+	if roll.Drill == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "No drill is in progress at this location"})
+		return
+	}
+
+	var unaccounted []models.MusterPerson
+	for _, area := range roll.Areas {
+		for _, person := range area.People {
+			if !person.AccountedFor {
+				unaccounted = append(unaccounted, person)
+			}
+		}
+	}
+
+	drill, err := database.DB.EndDrill(roll.Drill.ID, user.ID, roll.Total, unaccounted)
+	if err != nil {
+		if errors.Is(err, database.ErrDrillEnded) {
+			c.JSON(http.StatusConflict, gin.H{"error": "No drill is in progress at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end drill"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drill)
+}
+
+// ListDrills returns the drills held at a location, newest first
+func ListDrills(c *gin.Context) {
+	location, _, ok := musterLocation(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, database.DB.GetAllDrills(map[string]interface{}{"location_id": location.ID}))
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"digital-logbook/auth"
+	"digital-logbook/models"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcProvider is nil when single sign-on is not configured
+var oidcProvider *auth.OIDCProvider
+
+// oidcStateCookie ties a login to the browser that started it, so a callback
+// carrying someone else's state and code cannot sign this browser in
+const (
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/auth/oidc"
+)
+
+// ConfigureOIDC enables single sign-on through the given provider
+func ConfigureOIDC(provider *auth.OIDCProvider) {
+	oidcProvider = provider
+}
+
+// OIDCLogin redirects the browser to the identity provider to start single sign-on
+func OIDCLogin(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	authURL, state, err := oidcProvider.AuthCodeURL(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	setOIDCStateCookie(c, state, int(auth.PendingLoginTTL/time.Second))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes single sign-on, provisioning the user on first login
+func OIDCCallback(c *gin.Context) {
+	if oidcProvider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured"})
+		return
+	}
+
+	if idpError := c.Query("error"); idpError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-on rejected by identity provider: " + idpError})
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing state or code"})
+		return
+	}
+
+	cookie, err := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-on was not started in this browser"})
+		return
+	}
+
+	identity, err := oidcProvider.Exchange(c.Request.Context(), state, code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed"})
+		return
+	}
+
+	user, err := auth.ProvisionUser(models.AuthProviderOIDC, identity, oidcProvider.Config.Mapping)
+	if err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, auth.ErrUsernameInUse) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": "Access denied: " + err.Error()})
+		return
+	}
+
+	response, err := issueToken(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	// Hand the token to the frontend in the URL fragment so it never reaches server logs
+	if redirect := oidcProvider.Config.PostLoginRedirect; redirect != "" {
+		fragment := url.Values{
+			"token":      {response.Token},
+			"expires_at": {response.ExpiresAt.Format(time.RFC3339)},
+		}
+		c.Redirect(http.StatusFound, redirect+"#"+fragment.Encode())
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// setOIDCStateCookie stores the login state for the callback; a negative
+// maxAge deletes it. Lax lets the cookie through the identity provider's
+// redirect back, which is a top-level navigation.
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	secure := strings.HasPrefix(oidcProvider.Config.RedirectURL, "https://")
+	c.SetCookie(oidcStateCookie, state, maxAge, oidcCookiePath, "", secure, true)
+}
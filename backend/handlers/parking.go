@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxVehicleRegistration limits the length of a number plate
+const maxVehicleRegistration = 16
+
+// VehicleDetails is the vehicle a visitor arrives in and the bay it is parked in.
+// All fields are optional, but a parking bay needs a registration.
+type VehicleDetails struct {
+	VehicleRegistration string `json:"vehicle_registration"`
+	VehicleMake         string `json:"vehicle_make"`
+	VehicleColour       string `json:"vehicle_colour"`
+	ParkingBay          string `json:"parking_bay"` // Bay number from the location's inventory
+}
+
+type CreateParkingBayRequest struct {
+	Number     string `json:"number" binding:"required"`
+	Zone       string `json:"zone"`
+	LocationID uint   `json:"location_id"`
+}
+
+type UpdateParkingBayRequest struct {
+	Number string                  `json:"number" binding:"required"`
+	Zone   string                  `json:"zone"`
+	Status models.ParkingBayStatus `json:"status"` // Only available or out_of_service; bays are occupied at sign-in
+}
+
+// problem returns why the details cannot be recorded, or "" if they can
+func (v VehicleDetails) problem() string {
+	registration := models.NormalizeVehicleRegistration(v.VehicleRegistration)
+	switch {
+	case len(registration) > maxVehicleRegistration:
+		return "Vehicle registration is too long"
+	case registration == "" && strings.TrimSpace(v.ParkingBay) != "":
+		return "A vehicle registration is required to assign a parking bay"
+	case registration == "" && (strings.TrimSpace(v.VehicleMake) != "" || strings.TrimSpace(v.VehicleColour) != ""):
+		return "A vehicle registration is required with the make and colour"
+	}
+	return ""
+}
+
+// applyTo copies the details to the visit; the bay is taken when the visit is stored
+func (v VehicleDetails) applyTo(visit *models.Visit) {
+	visit.VehicleRegistration = models.NormalizeVehicleRegistration(v.VehicleRegistration)
+	visit.VehicleMake = strings.TrimSpace(v.VehicleMake)
+	visit.VehicleColour = strings.TrimSpace(v.VehicleColour)
+	visit.ParkingBay = models.NormalizeBayNumber(v.ParkingBay)
+}
+
+// vehicleOrAbort validates the vehicle details and, when a bay is given,
+// checks it is free at the location. CreateVisit repeats the check atomically.
+func vehicleOrAbort(c *gin.Context, locationID uint, details VehicleDetails) bool {
+	if msg := details.problem(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return false
+	}
+	if strings.TrimSpace(details.ParkingBay) == "" {
+		return true
+	}
+	return checkBayAvailable(c, locationID, details.ParkingBay)
+}
+
+// checkBayAvailable rejects a sign-in early when the parking bay cannot be assigned
+func checkBayAvailable(c *gin.Context, locationID uint, number string) bool {
+	bay, err := database.DB.GetParkingBayByNumber(locationID, number)
+	if err != nil {
+		respondVisitError(c, database.ErrBayNotInInventory, "")
+		return false
+	}
+	if bay.Status != models.BayAvailable {
+		respondVisitError(c, database.ErrBayUnavailable, "")
+		return false
+	}
+	return true
+}
+
+// parkingBayFromParam loads the bay named in the URL if the user may access its location
+func parkingBayFromParam(c *gin.Context) (*models.ParkingBay, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	bay, err := database.DB.GetParkingBayByID(uint(id))
+	if err != nil || (user.LocationID != nil && *user.LocationID != bay.LocationID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Parking bay not found"})
+		return nil, false
+	}
+	return bay, true
+}
+
+// ListParkingBays returns the parking bay inventory with the vehicles parked.
+// Query: status, zone and location_id (super admin only).
+func ListParkingBays(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	if status := c.Query("status"); status != "" {
+		filters["status"] = models.ParkingBayStatus(status)
+	}
+	if zone := c.Query("zone"); zone != "" {
+		filters["zone"] = zone
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllParkingBays(filters))
+}
+
+// GetParkingOccupancy counts each location's bays by status
+func GetParkingOccupancy(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetParkingOccupancy(locationFilter(c, user)))
+}
+
+// GetParkingBay returns a specific parking bay by ID
+func GetParkingBay(c *gin.Context) {
+	bay, ok := parkingBayFromParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, bay)
+}
+
+// CreateParkingBay adds a bay to a location's inventory (admin only)
+func CreateParkingBay(c *gin.Context) {
+	var req CreateParkingBayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+	if _, err := database.DB.GetLocationByID(locationID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+		return
+	}
+
+	bay := &models.ParkingBay{
+		LocationID: locationID,
+		Number:     req.Number,
+		Zone:       strings.TrimSpace(req.Zone),
+		Status:     models.BayAvailable,
+	}
+
+	if err := database.DB.CreateParkingBay(bay); err != nil {
+		if errors.Is(err, database.ErrBayNumberInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Parking bay number already exists at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create parking bay"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, bay)
+}
+
+// UpdateParkingBay changes a bay's details, or takes it out of and back into service (admin only)
+func UpdateParkingBay(c *gin.Context) {
+	bay, ok := parkingBayFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateParkingBayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Status != "" && req.Status != bay.Status {
+		if req.Status != models.BayAvailable && req.Status != models.BayOutOfService {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Status can only be set to available or out_of_service"})
+			return
+		}
+		if bay.Status == models.BayOccupied {
+			c.JSON(http.StatusConflict, gin.H{"error": "Parking bay is occupied; sign the visitor out first"})
+			return
+		}
+		bay.Status = req.Status
+	}
+
+	bay.Number = req.Number
+	bay.Zone = strings.TrimSpace(req.Zone)
+
+	if err := database.DB.UpdateParkingBay(bay); err != nil {
+		if errors.Is(err, database.ErrBayNumberInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Parking bay number already exists at this location"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update parking bay"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bay)
+}
+
+// DeleteParkingBay removes a bay from inventory (admin only)
+func DeleteParkingBay(c *gin.Context) {
+	bay, ok := parkingBayFromParam(c)
+	if !ok {
+		return
+	}
+
+	if bay.Status == models.BayOccupied {
+		c.JSON(http.StatusConflict, gin.H{"error": "Parking bay is occupied; sign the visitor out first"})
+		return
+	}
+
+	if err := database.DB.DeleteParkingBay(bay.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete parking bay"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Parking bay deleted successfully"})
+}
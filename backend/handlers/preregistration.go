@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"digital-logbook/notify"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePreregistrationRequest registers an expected visitor ahead of their
+// arrival. Known visitors are linked with visitor_id; the ID number of a new
+// visitor may be left for the gate to fill in.
+type CreatePreregistrationRequest struct {
+	VisitorID        uint      `json:"visitor_id"`
+	Name             string    `json:"name"`
+	IDNumber         string    `json:"id_number"`
+	CompanyFrom      string    `json:"company_from"`
+	AreaOfVisit      string    `json:"area_of_visit"` // Free text only where the location has no managed areas
+	AreaID           uint      `json:"area_id"`
+	Purpose          string    `json:"purpose" binding:"required"`
+	HostName         string    `json:"host_name"` // Required unless host_id is given
+	HostID           uint      `json:"host_id"`
+	ExpectedFrom     time.Time `json:"expected_from" binding:"required"`
+	ExpectedUntil    time.Time `json:"expected_until" binding:"required"`
+	RequiresApproval bool      `json:"requires_approval"`
+	LocationID       uint      `json:"location_id"`
+}
+
+// ArriveRequest converts a pre-registration into a signed-in visit at the gate
+type ArriveRequest struct {
+	IDNumber                string                   `json:"id_number" binding:"required"` // As shown on the visitor's document
+	BadgeNumber             string                   `json:"badge_number" binding:"required"`
+	EscortID                uint                     `json:"escort_id"`
+	Override                *WatchlistOverride       `json:"override"`
+	ExpectedDeparture       *time.Time               `json:"expected_departure"`
+	ExpectedDurationMinutes int                      `json:"expected_duration_minutes"`
+	Acknowledgements        []AcknowledgementRequest `json:"acknowledgements"`
+	Items                   []DeclaredItemRequest    `json:"items"`
+	VehicleDetails
+}
+
+type CancelPreregistrationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DenyVisitRequest turns an expected or kiosk visitor away at the gate
+type DenyVisitRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// preregistrationFromParam loads the pre-registration in the URL if the user may access it
+func preregistrationFromParam(c *gin.Context) (*models.Visit, *models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) || visit.ExpectedUntil == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pre-registration not found"})
+		return nil, nil, false
+	}
+	return visit, user, true
+}
+
+// CreatePreregistration registers an expected visitor
+func CreatePreregistration(c *gin.Context) {
+	var req CreatePreregistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+
+	host, ok := resolveHost(c, req.HostID, locationID)
+	if !ok {
+		return
+	}
+	if host == nil && req.HostName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Host is required"})
+		return
+	}
+	area, ok := resolveArea(c, locationID, req.AreaID, req.AreaOfVisit)
+	if !ok {
+		return
+	}
+
+	if !req.ExpectedUntil.After(req.ExpectedFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Arrival window must end after it starts"})
+		return
+	}
+	if !req.ExpectedUntil.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Arrival window has already passed"})
+		return
+	}
+
+	var visitor *models.Visitor
+	if req.VisitorID != 0 {
+		visitor, err = database.DB.GetVisitorByID(req.VisitorID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+			return
+		}
+	} else {
+		if req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required for new visitors"})
+			return
+		}
+		visitor = &models.Visitor{
+			Name:        req.Name,
+			IDNumber:    req.IDNumber,
+			CompanyFrom: req.CompanyFrom,
+		}
+		if err := database.DB.CreateVisitor(visitor); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create visitor"})
+			return
+		}
+	}
+
+	expectedFrom, expectedUntil := req.ExpectedFrom, req.ExpectedUntil
+	visit := &models.Visit{
+		VisitorID:        visitor.ID,
+		Purpose:          req.Purpose,
+		HostName:         req.HostName,
+		ExpectedFrom:     &expectedFrom,
+		ExpectedUntil:    &expectedUntil,
+		RegisteredBy:     actorID(user),
+		ApprovalRequired: req.RequiresApproval,
+		LocationID:       locationID,
+	}
+	visit.Expect(time.Now(), actorID(user))
+	setVisitArea(visit, area, req.AreaOfVisit)
+	setVisitHost(visit, host)
+
+	if err := database.DB.CreateVisit(visit); err != nil {
+		if req.VisitorID == 0 {
+			database.DB.DeleteVisitor(visitor.ID)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pre-registration"})
+		return
+	}
+
+	visit, _ = database.DB.GetVisitByID(visit.ID)
+	c.JSON(http.StatusCreated, visit)
+}
+
+// ListPreregistrations returns expected visits in order of arrival, for the
+// gate to pick from. Query: date (arrival window overlaps the day),
+// pending_approval, location_id (super admin only).
+func ListPreregistrations(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := locationFilter(c, user)
+	filters["status"] = string(models.StatusExpected)
+
+	var dayStart, dayEnd time.Time
+	if date := c.Query("date"); date != "" {
+		day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date"})
+			return
+		}
+		dayStart, dayEnd = day, day.AddDate(0, 0, 1)
+	}
+
+	result := make([]*models.Visit, 0)
+	for _, visit := range database.DB.GetAllVisits(filters) {
+		if !dayStart.IsZero() && (!visit.ExpectedFrom.Before(dayEnd) || visit.ExpectedUntil.Before(dayStart)) {
+			continue
+		}
+		if pending := c.Query("pending_approval"); pending != "" && visit.AwaitingApproval() != (pending == "true") {
+			continue
+		}
+		result = append(result, visit)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ExpectedFrom.Before(*result[j].ExpectedFrom)
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// ApprovePreregistration approves an expected visit that requires approval (admin only)
+func ApprovePreregistration(c *gin.Context) {
+	visit, user, ok := preregistrationFromParam(c)
+	if !ok {
+		return
+	}
+
+	if !visit.AwaitingApproval() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is not awaiting approval"})
+		return
+	}
+
+	now := time.Now()
+	visit.ApprovedBy = &user.ID
+	visit.ApprovedAt = &now
+
+	if err := database.DB.UpdateVisit(visit); err != nil {
+		respondVisitError(c, err, "Failed to approve pre-registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// RejectPreregistration turns down an expected visit that requires approval (admin only)
+func RejectPreregistration(c *gin.Context) {
+	visit, user, ok := preregistrationFromParam(c)
+	if !ok {
+		return
+	}
+
+	if !visit.AwaitingApproval() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is not awaiting approval"})
+		return
+	}
+
+	if err := visit.Cancel(models.CancelReasonRejected, time.Now(), actorID(user)); err != nil {
+		respondVisitError(c, err, "Failed to reject pre-registration")
+		return
+	}
+	if err := database.DB.UpdateVisit(visit); err != nil {
+		respondVisitError(c, err, "Failed to reject pre-registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// CancelPreregistration withdraws an expected visit
+func CancelPreregistration(c *gin.Context) {
+	visit, user, ok := preregistrationFromParam(c)
+	if !ok {
+		return
+	}
+
+	// The reason is optional, and so is the body
+	var req CancelPreregistrationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if !visit.IsExpected() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is already " + string(visit.Status)})
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = "cancelled"
+	}
+	if err := visit.Cancel(reason, time.Now(), actorID(user)); err != nil {
+		respondVisitError(c, err, "Failed to cancel pre-registration")
+		return
+	}
+	if err := database.DB.UpdateVisit(visit); err != nil {
+		respondVisitError(c, err, "Failed to cancel pre-registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// DenyPreregistration records that an expected visitor was turned away at the
+// gate, for instance when their documents could not be verified
+func DenyPreregistration(c *gin.Context) {
+	visit, user, ok := preregistrationFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req DenyVisitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := denyVisit(visit, req.Reason, user); err != nil {
+		respondVisitError(c, err, "Failed to deny pre-registration")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// denyVisit turns away the visitor of an expected or pending visit
+func denyVisit(visit *models.Visit, reason string, by *models.User) error {
+	if err := visit.Deny(reason, time.Now(), actorID(by)); err != nil {
+		return err
+	}
+	return database.DB.UpdateVisit(visit)
+}
+
+// ArrivePreregistration signs in an expected visitor once the gate has checked
+// their ID, issuing a badge. The pre-registration itself becomes the visit.
+func ArrivePreregistration(c *gin.Context) {
+	visit, user, ok := preregistrationFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req ArriveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case !visit.IsExpected():
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is " + string(visit.Status)})
+		return
+	case visit.AwaitingApproval():
+		c.JSON(http.StatusConflict, gin.H{"error": "Pre-registration is awaiting approval"})
+		return
+	}
+
+	signInWaitingVisit(c, visit, user, &req)
+}
+
+// signInWaitingVisit signs in an expected or kiosk-submitted visit in place,
+// with the same checks as a walk-in sign-in. A visitor the watchlist blocks is
+// turned away and the visit denied.
+func signInWaitingVisit(c *gin.Context, visit *models.Visit, user *models.User, req *ArriveRequest) {
+	visitor, err := database.DB.GetVisitorByID(visit.VisitorID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+		return
+	}
+	if _, err := database.DB.GetOpenVisit(visitor.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor already signed in"})
+		return
+	}
+
+	// The document shown at the gate must match what the host registered or the visitor entered
+	idNumber := req.IDNumber
+	if visitor.IDNumber != "" && models.NormalizeIDNumber(visitor.IDNumber) != models.NormalizeIDNumber(idNumber) {
+		if visit.IsPending() {
+			c.JSON(http.StatusConflict, gin.H{"error": "ID number does not match the one entered at the kiosk"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "ID number does not match the pre-registration"})
+		return
+	}
+
+	signInTime := time.Now()
+	departure, ok := expectedDeparture(c, signInTime, req.ExpectedDeparture, req.ExpectedDurationMinutes)
+	if !ok {
+		return
+	}
+	items, ok := declaredItemsOrAbort(c, req.Items)
+	if !ok {
+		return
+	}
+	if !checkBadgeAvailable(c, visit.LocationID, req.BadgeNumber) {
+		return
+	}
+	var area *models.Area
+	if visit.AreaID != nil {
+		if a, err := database.DB.GetAreaByID(*visit.AreaID); err == nil {
+			area = a
+		}
+	}
+	if !areaOrAbort(c, area, req.BadgeNumber, 1) {
+		return
+	}
+	escort, ok := resolveEscort(c, req.EscortID, visit.LocationID, area)
+	if !ok {
+		return
+	}
+	if !vehicleOrAbort(c, visit.LocationID, req.VehicleDetails) {
+		return
+	}
+	if status, response := screenWatchlist(c, user, visitor.ID, visitor.Name, idNumber, visit.LocationID, req.Override); status != 0 {
+		// A visitor the watchlist blocks is turned away for good
+		if response["severity"] == models.WatchlistBlock {
+			denyVisit(visit, models.DenyReasonWatchlist, user)
+		}
+		c.JSON(status, response)
+		return
+	}
+	acks, ok := acceptAcknowledgements(c, user, visitor.ID, visit.LocationID, visit.AreaOfVisit, req.Acknowledgements)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if visitor.IDNumber == "" {
+		visitor.IDNumber = idNumber
+		if err := database.DB.UpdateVisitor(visitor); err != nil {
+			discardSignatures(ctx, acks)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visitor"})
+			return
+		}
+	}
+
+	if err := visit.SignIn(signInTime, actorID(user)); err != nil {
+		discardSignatures(ctx, acks)
+		respondVisitError(c, err, "Failed to sign in visitor")
+		return
+	}
+	visit.ExpectedDeparture = departure
+	visit.BadgeNumber = req.BadgeNumber
+	visit.Items = items
+	setVisitEscort(visit, escort)
+	req.VehicleDetails.applyTo(visit)
+	if err := database.DB.StartExpectedVisit(visit); err != nil {
+		discardSignatures(ctx, acks)
+		if errors.Is(err, database.ErrVisitNotExpected) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Visit is no longer waiting to be signed in"})
+			return
+		}
+		respondVisitError(c, err, "Failed to sign in visitor")
+		return
+	}
+	recordAcknowledgements(ctx, acks, visitor.ID, visit.ID)
+	recordEscort(visit, actorID(user))
+	notifyHost(notify.EventVisitorArrived, visit)
+
+	visit.Visitor = nil
+	visitor.CurrentVisit = visit
+	c.JSON(http.StatusOK, visitor)
+}
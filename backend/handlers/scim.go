@@ -0,0 +1,920 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	scimUserSchema        = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema       = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema        = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimPatchSchema       = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimConfigSchema      = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	scimLogbookUserSchema = "urn:digital-logbook:scim:schemas:extension:1.0:User"
+
+	scimDefaultPageSize = 100
+)
+
+// SCIM groups are the fixed user roles; members of a group hold that role
+var scimRoleGroups = []struct {
+	Role        models.UserRole
+	DisplayName string
+}{
+	{models.RoleDataEntry, "Data Entry Operators"},
+	{models.RoleDashboardVisitor, "Visitor Dashboard Operators"},
+	{models.RoleDashboardCargo, "Cargo Dashboard Operators"},
+	{models.RoleAdmin, "Administrators"},
+}
+
+// scimFallbackRole is given to users removed from their role group
+const scimFallbackRole = models.RoleDataEntry
+
+// scimAuthProvider is where users created over SCIM sign in
+var scimAuthProvider = models.AuthProviderOIDC
+
+// ConfigureSCIM sets how users created by the provisioning client authenticate
+func ConfigureSCIM(provider models.AuthProvider) {
+	scimAuthProvider = provider
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMemberRef struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location"`
+}
+
+// scimLogbookExtension carries logbook-specific user attributes
+type scimLogbookExtension struct {
+	Location string `json:"location,omitempty"` // Location code, e.g. NBO-HQ
+}
+
+type scimUser struct {
+	Schemas     []string              `json:"schemas"`
+	ID          string                `json:"id,omitempty"`
+	ExternalID  string                `json:"externalId,omitempty"`
+	UserName    string                `json:"userName"`
+	Name        *scimName             `json:"name,omitempty"`
+	DisplayName string                `json:"displayName,omitempty"`
+	Emails      []scimEmail           `json:"emails,omitempty"`
+	Active      *bool                 `json:"active,omitempty"`
+	Password    string                `json:"password,omitempty"` // Write only
+	Groups      []scimMemberRef       `json:"groups,omitempty"`
+	Logbook     *scimLogbookExtension `json:"urn:digital-logbook:scim:schemas:extension:1.0:User,omitempty"`
+	Meta        *scimMeta             `json:"meta,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id"`
+	DisplayName string          `json:"displayName"`
+	Members     []scimMemberRef `json:"members"`
+	Meta        *scimMeta       `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations" binding:"required"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// scimJSON writes a SCIM response with the SCIM media type
+func scimJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+// scimPage applies startIndex and count to a result set
+func scimPage(c *gin.Context, resources []interface{}) scimListResponse {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimDefaultPageSize)))
+	if err != nil || count < 0 {
+		count = scimDefaultPageSize
+	}
+
+	total := len(resources)
+	from := startIndex - 1
+	if from > total {
+		from = total
+	}
+	to := from + count
+	if to > total {
+		to = total
+	}
+
+	return scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: to - from,
+		Resources:    resources[from:to],
+	}
+}
+
+func scimRoleDisplayName(role models.UserRole) string {
+	for _, group := range scimRoleGroups {
+		if group.Role == role {
+			return group.DisplayName
+		}
+	}
+	return string(role)
+}
+
+func toSCIMUser(user *models.User) scimUser {
+	active := !user.Disabled
+	created := user.CreatedAt
+	result := scimUser{
+		Schemas:     []string{scimUserSchema, scimLogbookUserSchema},
+		ID:          strconv.FormatUint(uint64(user.ID), 10),
+		ExternalID:  user.SCIMExternalID,
+		UserName:    user.Username,
+		Name:        &scimName{Formatted: user.FullName},
+		DisplayName: user.FullName,
+		Active:      &active,
+		Groups: []scimMemberRef{{
+			Value:   string(user.Role),
+			Display: scimRoleDisplayName(user.Role),
+			Ref:     "/scim/v2/Groups/" + string(user.Role),
+		}},
+		Logbook: &scimLogbookExtension{},
+		Meta: &scimMeta{
+			ResourceType: "User",
+			Created:      &created,
+			Location:     "/scim/v2/Users/" + strconv.FormatUint(uint64(user.ID), 10),
+		},
+	}
+	if !user.UpdatedAt.IsZero() {
+		modified := user.UpdatedAt
+		result.Meta.LastModified = &modified
+	}
+	if user.Email != "" {
+		result.Emails = []scimEmail{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.LocationID != nil {
+		if location, err := database.DB.GetLocationByID(*user.LocationID); err == nil {
+			result.Logbook.Location = location.Code
+		}
+	}
+	return result
+}
+
+// scimUserAttributes flattens a user for filter evaluation
+func scimUserAttributes(user *models.User) map[string][]string {
+	return map[string][]string{
+		"id":             {strconv.FormatUint(uint64(user.ID), 10)},
+		"username":       {user.Username},
+		"externalid":     {user.SCIMExternalID},
+		"displayname":    {user.FullName},
+		"name.formatted": {user.FullName},
+		"emails":         {user.Email},
+		"emails.value":   {user.Email},
+		"active":         {strconv.FormatBool(!user.Disabled)},
+		"groups":         {string(user.Role)},
+		"groups.value":   {string(user.Role)},
+	}
+}
+
+// scimUserFullName picks the best available name from a SCIM user
+func scimUserFullName(su *scimUser) string {
+	if su.DisplayName != "" {
+		return su.DisplayName
+	}
+	if su.Name != nil {
+		if su.Name.Formatted != "" {
+			return su.Name.Formatted
+		}
+		if full := strings.TrimSpace(su.Name.GivenName + " " + su.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	return su.UserName
+}
+
+func scimPrimaryEmail(emails []scimEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+// setSCIMLocation assigns a location by code; an empty code leaves it unchanged
+func setSCIMLocation(user *models.User, code string) bool {
+	if code == "" {
+		return true
+	}
+	location, err := database.DB.GetLocationByCode(code)
+	if err != nil {
+		return false
+	}
+	user.LocationID = &location.ID
+	return true
+}
+
+// scimManages returns true if the provisioning client may see and change the
+// user: accounts that sign in through scimAuthProvider and accounts it created.
+// Other local accounts, such as the break-glass admin, are out of its reach.
+func scimManages(user *models.User) bool {
+	if user.IsLocal() {
+		return user.SCIMCreated
+	}
+	return user.AuthProvider == scimAuthProvider
+}
+
+// scimUsers returns the users the provisioning client manages
+func scimUsers() []*models.User {
+	users := make([]*models.User, 0)
+	for _, user := range database.DB.GetAllUsers() {
+		if scimManages(user) {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func scimUserFromParam(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		middleware.SCIMError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	user, err := database.DB.GetUserByID(uint(id))
+	if err != nil || !scimManages(user) {
+		middleware.SCIMError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// SCIMServiceProviderConfig describes the supported SCIM features
+func SCIMServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{scimConfigSchema},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scimDefaultPageSize},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "API key bearer token",
+			"description": "Service account API key with the scim:provision permission",
+		}},
+	})
+}
+
+// SCIMListUsers lists users, optionally filtered
+func SCIMListUsers(c *gin.Context) {
+	filter, err := parseSCIMFilter(c.Query("filter"))
+	if err != nil {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidFilter", "Unsupported filter expression")
+		return
+	}
+
+	users := scimUsers()
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	resources := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		if filter.Matches(scimUserAttributes(user)) {
+			resources = append(resources, toSCIMUser(user))
+		}
+	}
+
+	scimJSON(c, http.StatusOK, scimPage(c, resources))
+}
+
+// SCIMGetUser returns a single user
+func SCIMGetUser(c *gin.Context) {
+	user, ok := scimUserFromParam(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+// SCIMCreateUser provisions a new user
+func SCIMCreateUser(c *gin.Context) {
+	var req scimUser
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserName == "" {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "userName is required")
+		return
+	}
+
+	if _, err := database.DB.GetUserByUsername(req.UserName); err == nil {
+		middleware.SCIMError(c, http.StatusConflict, "uniqueness", "userName already exists")
+		return
+	}
+
+	user := &models.User{
+		Username:       req.UserName,
+		Role:           scimFallbackRole,
+		FullName:       scimUserFullName(&req),
+		Email:          scimPrimaryEmail(req.Emails),
+		AuthProvider:   scimAuthProvider,
+		SCIMExternalID: req.ExternalID,
+		SCIMCreated:    true,
+		Disabled:       req.Active != nil && !*req.Active,
+	}
+	if req.Logbook == nil || req.Logbook.Location == "" || !setSCIMLocation(user, req.Logbook.Location) {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "A valid location code is required in the "+scimLogbookUserSchema+" extension")
+		return
+	}
+
+	if scimAuthProvider == models.AuthProviderLocal && req.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to hash password")
+			return
+		}
+		user.PasswordHash = string(hashedPassword)
+	}
+
+	if err := database.DB.CreateUser(user); err != nil {
+		middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to create user")
+		return
+	}
+
+	scimJSON(c, http.StatusCreated, toSCIMUser(user))
+}
+
+// SCIMReplaceUser replaces a user's attributes (PUT)
+func SCIMReplaceUser(c *gin.Context) {
+	user, ok := scimUserFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req scimUser
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserName == "" {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "userName is required")
+		return
+	}
+
+	if req.UserName != user.Username {
+		if _, err := database.DB.GetUserByUsername(req.UserName); err == nil {
+			middleware.SCIMError(c, http.StatusConflict, "uniqueness", "userName already exists")
+			return
+		}
+	}
+	if req.Logbook != nil && !setSCIMLocation(user, req.Logbook.Location) {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Unknown location code")
+		return
+	}
+
+	user.Username = req.UserName
+	user.FullName = scimUserFullName(&req)
+	user.Email = scimPrimaryEmail(req.Emails)
+	user.SCIMExternalID = req.ExternalID
+	user.Disabled = req.Active != nil && !*req.Active
+	user.UpdatedAt = time.Now()
+
+	if err := database.DB.UpdateUser(user); err != nil {
+		middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to update user")
+		return
+	}
+	if user.Disabled {
+		database.DB.RevokeUserSessions(user.ID, 0)
+	}
+
+	scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+// scimBool accepts JSON booleans as well as the "True"/"False" strings some clients send
+func scimBool(raw json.RawMessage) (bool, bool) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, true
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if parsed, err := strconv.ParseBool(s); err == nil {
+			return parsed, true
+		}
+	}
+	return false, false
+}
+
+func scimString(raw json.RawMessage) (string, bool) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// applySCIMUserPatch applies one attribute of a PATCH operation to a user
+func applySCIMUserPatch(user *models.User, op, path string, value json.RawMessage) bool {
+	path = strings.ToLower(path)
+	remove := op == "remove"
+
+	switch {
+	case path == "active":
+		active, ok := scimBool(value)
+		if !ok {
+			return false
+		}
+		user.Disabled = !active
+	case path == "username":
+		name, ok := scimString(value)
+		if !ok || name == "" {
+			return false
+		}
+		user.Username = name
+	case path == "displayname" || path == "name.formatted":
+		name, ok := scimString(value)
+		if !ok {
+			return false
+		}
+		user.FullName = name
+	case path == "name.givenname" || path == "name.familyname":
+		name, ok := scimString(value)
+		if !ok {
+			return false
+		}
+		parts := strings.SplitN(user.FullName, " ", 2)
+		given, family := parts[0], ""
+		if len(parts) == 2 {
+			family = parts[1]
+		}
+		if path == "name.givenname" {
+			given = name
+		} else {
+			family = name
+		}
+		user.FullName = strings.TrimSpace(given + " " + family)
+	case path == "name":
+		var name scimName
+		if err := json.Unmarshal(value, &name); err != nil {
+			return false
+		}
+		user.FullName = scimUserFullName(&scimUser{Name: &name, UserName: user.Username})
+	case path == "externalid":
+		if remove {
+			user.SCIMExternalID = ""
+			return true
+		}
+		id, ok := scimString(value)
+		if !ok {
+			return false
+		}
+		user.SCIMExternalID = id
+	case path == "emails" || strings.HasPrefix(path, "emails["):
+		if remove {
+			user.Email = ""
+			return true
+		}
+		if strings.HasPrefix(path, "emails[") {
+			email, ok := scimString(value)
+			if !ok {
+				return false
+			}
+			user.Email = email
+			return true
+		}
+		var emails []scimEmail
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return false
+		}
+		user.Email = scimPrimaryEmail(emails)
+	case path == strings.ToLower(scimLogbookUserSchema)+":location":
+		code, ok := scimString(value)
+		if !ok {
+			return false
+		}
+		return setSCIMLocation(user, code)
+	case path == strings.ToLower(scimLogbookUserSchema):
+		var ext scimLogbookExtension
+		if err := json.Unmarshal(value, &ext); err != nil {
+			return false
+		}
+		return setSCIMLocation(user, ext.Location)
+	default:
+		// Unknown attributes are ignored so clients can send their full mapping
+	}
+	return true
+}
+
+// SCIMPatchUser applies PATCH operations to a user
+func SCIMPatchUser(c *gin.Context) {
+	user, ok := scimUserFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req scimPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid PATCH request")
+		return
+	}
+
+	// Work on a copy so a failed operation leaves the user untouched
+	updated := *user
+	originalUsername := user.Username
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Unsupported operation: "+operation.Op)
+			return
+		}
+
+		if operation.Path == "" {
+			// Without a path the value is an object of attributes to set
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &attrs); err != nil {
+				middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Value must be an object when no path is given")
+				return
+			}
+			for attr, value := range attrs {
+				if !applySCIMUserPatch(&updated, op, attr, value) {
+					middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Invalid value for "+attr)
+					return
+				}
+			}
+			continue
+		}
+
+		if !applySCIMUserPatch(&updated, op, operation.Path, operation.Value) {
+			middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Invalid value for "+operation.Path)
+			return
+		}
+	}
+
+	if updated.Username != originalUsername {
+		if _, err := database.DB.GetUserByUsername(updated.Username); err == nil {
+			middleware.SCIMError(c, http.StatusConflict, "uniqueness", "userName already exists")
+			return
+		}
+	}
+
+	updated.UpdatedAt = time.Now()
+	if err := database.DB.UpdateUser(&updated); err != nil {
+		middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to update user")
+		return
+	}
+	if updated.Disabled {
+		database.DB.RevokeUserSessions(updated.ID, 0)
+	}
+
+	scimJSON(c, http.StatusOK, toSCIMUser(&updated))
+}
+
+// SCIMDeleteUser deprovisions a user and signs them out everywhere
+func SCIMDeleteUser(c *gin.Context) {
+	user, ok := scimUserFromParam(c)
+	if !ok {
+		return
+	}
+
+	database.DB.RevokeUserSessions(user.ID, 0)
+	if err := database.DB.DeleteUser(user.ID); err != nil {
+		middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to delete user")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func toSCIMGroup(role models.UserRole, displayName string, users []*models.User) scimGroup {
+	group := scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          string(role),
+		DisplayName: displayName,
+		Members:     []scimMemberRef{},
+		Meta: &scimMeta{
+			ResourceType: "Group",
+			Location:     "/scim/v2/Groups/" + string(role),
+		},
+	}
+	for _, user := range users {
+		if user.Role == role {
+			id := strconv.FormatUint(uint64(user.ID), 10)
+			group.Members = append(group.Members, scimMemberRef{
+				Value:   id,
+				Display: user.Username,
+				Ref:     "/scim/v2/Users/" + id,
+			})
+		}
+	}
+	sort.Slice(group.Members, func(i, j int) bool {
+		a, _ := strconv.Atoi(group.Members[i].Value)
+		b, _ := strconv.Atoi(group.Members[j].Value)
+		return a < b
+	})
+	return group
+}
+
+// scimGroupFromParam resolves the role group named in the URL
+func scimGroupFromParam(c *gin.Context) (models.UserRole, string, bool) {
+	for _, group := range scimRoleGroups {
+		if string(group.Role) == c.Param("id") {
+			return group.Role, group.DisplayName, true
+		}
+	}
+	middleware.SCIMError(c, http.StatusNotFound, "", "Group not found")
+	return "", "", false
+}
+
+// SCIMListGroups lists the role groups and their members
+func SCIMListGroups(c *gin.Context) {
+	filter, err := parseSCIMFilter(c.Query("filter"))
+	if err != nil {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidFilter", "Unsupported filter expression")
+		return
+	}
+
+	users := scimUsers()
+	resources := make([]interface{}, 0, len(scimRoleGroups))
+	for _, g := range scimRoleGroups {
+		group := toSCIMGroup(g.Role, g.DisplayName, users)
+		memberIDs := make([]string, 0, len(group.Members))
+		for _, member := range group.Members {
+			memberIDs = append(memberIDs, member.Value)
+		}
+		attrs := map[string][]string{
+			"id":            {group.ID},
+			"displayname":   {group.DisplayName},
+			"members":       memberIDs,
+			"members.value": memberIDs,
+		}
+		if filter.Matches(attrs) {
+			resources = append(resources, group)
+		}
+	}
+
+	scimJSON(c, http.StatusOK, scimPage(c, resources))
+}
+
+// SCIMGetGroup returns one role group
+func SCIMGetGroup(c *gin.Context) {
+	role, displayName, ok := scimGroupFromParam(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMGroup(role, displayName, scimUsers()))
+}
+
+// SCIMGroupsReadOnly rejects creating or deleting groups, which are fixed roles
+func SCIMGroupsReadOnly(c *gin.Context) {
+	middleware.SCIMError(c, http.StatusForbidden, "mutability", "Groups correspond to fixed roles and cannot be created or deleted")
+}
+
+// groupMembership works out a change to the role groups on a copy of the
+// managed users' roles, so a request is checked in full before any account
+// is changed
+type groupMembership struct {
+	users map[string]*models.User // By ID, the users the provisioning client manages
+	roles map[string]models.UserRole
+}
+
+func newGroupMembership() *groupMembership {
+	m := &groupMembership{users: make(map[string]*models.User), roles: make(map[string]models.UserRole)}
+	for _, user := range scimUsers() {
+		id := strconv.FormatUint(uint64(user.ID), 10)
+		m.users[id] = user
+		m.roles[id] = user.Role
+	}
+	return m
+}
+
+// member returns the key of a member ID if the provisioning client manages the user
+func (m *groupMembership) member(userID string) (string, bool) {
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return "", false
+	}
+	key := strconv.FormatUint(id, 10)
+	_, ok := m.users[key]
+	return key, ok
+}
+
+// add moves the users into the role group. If any is unknown nothing
+// changes and the ID is returned.
+func (m *groupMembership) add(role models.UserRole, memberIDs []string) (string, bool) {
+	keys := make([]string, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		key, ok := m.member(id)
+		if !ok {
+			return id, false
+		}
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		m.roles[key] = role
+	}
+	return "", true
+}
+
+// remove moves the users out of the role group, ignoring those not in it
+func (m *groupMembership) remove(role models.UserRole, memberIDs []string) {
+	for _, id := range memberIDs {
+		if key, ok := m.member(id); ok && m.roles[key] == role {
+			m.roles[key] = scimFallbackRole
+		}
+	}
+}
+
+// replace makes exactly the given users hold the role among the users the
+// provisioning client manages; other accounts keep their role. Like add, it
+// returns an unknown ID without changing anything.
+func (m *groupMembership) replace(role models.UserRole, memberIDs []string) (string, bool) {
+	keep := make(map[string]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		key, ok := m.member(id)
+		if !ok {
+			return id, false
+		}
+		keep[key] = true
+	}
+	for key, current := range m.roles {
+		if keep[key] {
+			m.roles[key] = role
+		} else if current == role {
+			m.roles[key] = scimFallbackRole
+		}
+	}
+	return "", true
+}
+
+// save stores the roles that changed and returns the IDs of any users that
+// could not be updated
+func (m *groupMembership) save() []string {
+	keys := make([]string, 0, len(m.roles))
+	for key := range m.roles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var failed []string
+	now := time.Now()
+	for _, key := range keys {
+		if m.users[key].Role == m.roles[key] {
+			continue
+		}
+		user := *m.users[key]
+		user.Role = m.roles[key]
+		user.UpdatedAt = now
+		if err := database.DB.UpdateUser(&user); err != nil {
+			failed = append(failed, key)
+		}
+	}
+	return failed
+}
+
+// scimMemberIDs reads the member IDs from a members value or a members[value eq "x"] path
+func scimMemberIDs(path string, value json.RawMessage) []string {
+	if start := strings.Index(path, "["); start != -1 && strings.HasSuffix(path, "]") {
+		filter, err := parseSCIMFilter(path[start+1 : len(path)-1])
+		if err == nil && len(filter) == 1 && len(filter[0]) == 1 && filter[0][0].attr == "value" {
+			return []string{filter[0][0].value}
+		}
+		return nil
+	}
+
+	var members []scimMemberRef
+	if err := json.Unmarshal(value, &members); err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.Value)
+	}
+	return ids
+}
+
+// SCIMPatchGroup adds or removes members, changing their role. Every
+// operation is checked before any member is changed.
+func SCIMPatchGroup(c *gin.Context) {
+	role, displayName, ok := scimGroupFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req scimPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid PATCH request")
+		return
+	}
+
+	members := newGroupMembership()
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.ToLower(operation.Path)
+		if path == "displayname" {
+			continue // Role group names are fixed
+		}
+		if !strings.HasPrefix(path, "members") {
+			middleware.SCIMError(c, http.StatusBadRequest, "invalidPath", "Only members can be modified")
+			return
+		}
+
+		memberIDs := scimMemberIDs(operation.Path, operation.Value)
+		switch op {
+		case "add":
+			if id, ok := members.add(role, memberIDs); !ok {
+				middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Unknown member: "+id)
+				return
+			}
+		case "remove":
+			if path == "members" && len(operation.Value) == 0 {
+				members.replace(role, nil)
+				continue
+			}
+			members.remove(role, memberIDs)
+		case "replace":
+			if id, ok := members.replace(role, memberIDs); !ok {
+				middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Unknown member: "+id)
+				return
+			}
+		default:
+			middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Unsupported operation: "+operation.Op)
+			return
+		}
+	}
+
+	if failed := members.save(); len(failed) > 0 {
+		middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to update members: "+strings.Join(failed, ", "))
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMGroup(role, displayName, scimUsers()))
+}
+
+// SCIMReplaceGroup sets the full member list of a role group (PUT)
+func SCIMReplaceGroup(c *gin.Context) {
+	role, displayName, ok := scimGroupFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req scimGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidSyntax", "Invalid group")
+		return
+	}
+
+	memberIDs := make([]string, 0, len(req.Members))
+	for _, member := range req.Members {
+		memberIDs = append(memberIDs, member.Value)
+	}
+	members := newGroupMembership()
+	if id, ok := members.replace(role, memberIDs); !ok {
+		middleware.SCIMError(c, http.StatusBadRequest, "invalidValue", "Unknown member: "+id)
+		return
+	}
+	if failed := members.save(); len(failed) > 0 {
+		middleware.SCIMError(c, http.StatusInternalServerError, "", "Failed to update members: "+strings.Join(failed, ", "))
+		return
+	}
+
+	scimJSON(c, http.StatusOK, toSCIMGroup(role, displayName, scimUsers()))
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+)
+
+// scimFilter is a parsed SCIM filter (RFC 7644 section 3.4.2.2) limited to
+// attribute comparisons joined by "and" / "or", which is what provisioning
+// clients send in practice. "and" binds tighter than "or".
+type scimFilter [][]scimComparison
+
+type scimComparison struct {
+	attr  string
+	op    string
+	value string
+}
+
+var errInvalidFilter = errors.New("invalid filter")
+
+// tokenizeSCIMFilter splits a filter on whitespace, keeping quoted strings intact
+func tokenizeSCIMFilter(filter string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	quoted := false
+
+	for i := 0; i < len(filter); i++ {
+		ch := filter[i]
+		switch {
+		case inQuotes && ch == '\\' && i+1 < len(filter):
+			i++
+			current.WriteByte(filter[i])
+		case ch == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case !inQuotes && (ch == ' ' || ch == '\t'):
+			if current.Len() > 0 || quoted {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				quoted = false
+			}
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	if inQuotes {
+		return nil, errInvalidFilter
+	}
+	if current.Len() > 0 || quoted {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// parseSCIMFilter parses a filter expression; an empty filter matches everything
+func parseSCIMFilter(filter string) (scimFilter, error) {
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result scimFilter
+	var group []scimComparison
+	for i := 0; i < len(tokens); {
+		if i+1 >= len(tokens) {
+			return nil, errInvalidFilter
+		}
+
+		comparison := scimComparison{
+			attr: strings.ToLower(tokens[i]),
+			op:   strings.ToLower(tokens[i+1]),
+		}
+		i += 2
+		switch comparison.op {
+		case "pr":
+		case "eq", "ne", "co", "sw", "ew":
+			if i >= len(tokens) {
+				return nil, errInvalidFilter
+			}
+			comparison.value = tokens[i]
+			i++
+		default:
+			return nil, errInvalidFilter
+		}
+		group = append(group, comparison)
+
+		if i == len(tokens) {
+			break
+		}
+		switch strings.ToLower(tokens[i]) {
+		case "and":
+		case "or":
+			result = append(result, group)
+			group = nil
+		default:
+			return nil, errInvalidFilter
+		}
+		i++
+		if i == len(tokens) {
+			return nil, errInvalidFilter
+		}
+	}
+	if len(group) > 0 {
+		result = append(result, group)
+	}
+	return result, nil
+}
+
+// Matches evaluates the filter against attribute values keyed by lowercase attribute path
+func (f scimFilter) Matches(attrs map[string][]string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, group := range f {
+		matched := true
+		for _, comparison := range group {
+			if !comparison.matches(attrs[comparison.attr]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmp scimComparison) matches(values []string) bool {
+	if cmp.op == "pr" {
+		for _, v := range values {
+			if v != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if cmp.op == "ne" {
+		for _, v := range values {
+			if strings.EqualFold(v, cmp.value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	want := strings.ToLower(cmp.value)
+	for _, v := range values {
+		v = strings.ToLower(v)
+		switch cmp.op {
+		case "eq":
+			if v == want {
+				return true
+			}
+		case "co":
+			if strings.Contains(v, want) {
+				return true
+			}
+		case "sw":
+			if strings.HasPrefix(v, want) {
+				return true
+			}
+		case "ew":
+			if strings.HasSuffix(v, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
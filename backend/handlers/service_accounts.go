@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateServiceAccountRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	Description string              `json:"description"`
+	Permissions []models.Permission `json:"permissions" binding:"required,min=1"`
+	LocationIDs []uint              `json:"location_ids"`
+}
+
+type UpdateServiceAccountRequest struct {
+	Description *string             `json:"description"`
+	Permissions []models.Permission `json:"permissions"`
+	LocationIDs []uint              `json:"location_ids"`
+	Disabled    *bool               `json:"disabled"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name          string `json:"name"`
+	ExpiresInDays int    `json:"expires_in_days" binding:"min=0"`
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"` // Only returned once, at creation
+	APIKey models.APIKey `json:"api_key"`
+}
+
+// validatePermissions returns the first unknown permission, if any
+func validatePermissions(perms []models.Permission) (models.Permission, bool) {
+	for _, perm := range perms {
+		if !perm.IsValid() {
+			return perm, false
+		}
+	}
+	return "", true
+}
+
+const kioskAccountError = "A kiosk account holds only the kiosk permission and is bound to exactly one location"
+
+// validKioskAccount checks that a kiosk holds only the kiosk permission and
+// is bound to exactly one location
+func validKioskAccount(perms []models.Permission, locationIDs []uint) bool {
+	for _, perm := range perms {
+		if perm == models.PermKiosk {
+			return len(perms) == 1 && len(locationIDs) == 1
+		}
+	}
+	return true
+}
+
+// validateLocationIDs checks that every location exists
+func validateLocationIDs(ids []uint) bool {
+	for _, id := range ids {
+		if _, err := database.DB.GetLocationByID(id); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateServiceAccount creates a new service account (admin only)
+func CreateServiceAccount(c *gin.Context) {
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if perm, ok := validatePermissions(req.Permissions); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + string(perm)})
+		return
+	}
+	if !validateLocationIDs(req.LocationIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+		return
+	}
+	if !validKioskAccount(req.Permissions, req.LocationIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": kioskAccountError})
+		return
+	}
+
+	account := &models.ServiceAccount{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+		LocationIDs: req.LocationIDs,
+	}
+
+	if err := database.DB.CreateServiceAccount(account); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// ListServiceAccounts returns all service accounts (admin only)
+func ListServiceAccounts(c *gin.Context) {
+	accounts := database.DB.GetAllServiceAccounts()
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetServiceAccount returns a specific service account by ID (admin only)
+func GetServiceAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	account, err := database.DB.GetServiceAccountByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// UpdateServiceAccount updates permissions, locations or the disabled flag (admin only)
+func UpdateServiceAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	account, err := database.DB.GetServiceAccountByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return
+	}
+
+	var req UpdateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate everything before touching the stored account
+	permissions := account.Permissions
+	if req.Permissions != nil {
+		if perm, ok := validatePermissions(req.Permissions); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission: " + string(perm)})
+			return
+		}
+		permissions = req.Permissions
+	}
+	locationIDs := account.LocationIDs
+	if req.LocationIDs != nil {
+		if !validateLocationIDs(req.LocationIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+			return
+		}
+		locationIDs = req.LocationIDs
+	}
+	if !validKioskAccount(permissions, locationIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": kioskAccountError})
+		return
+	}
+
+	updated := *account
+	updated.Permissions = permissions
+	updated.LocationIDs = locationIDs
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.Disabled != nil {
+		updated.Disabled = *req.Disabled
+	}
+	account = &updated
+
+	if err := database.DB.UpdateServiceAccount(account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update service account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// DeleteServiceAccount deletes a service account and all of its keys (admin only)
+func DeleteServiceAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if err := database.DB.DeleteServiceAccount(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete service account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deleted successfully"})
+}
+
+// CreateAPIKey issues a new API key for a service account (admin only).
+// The plaintext key is only ever returned by this call.
+func CreateAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := database.DB.GetServiceAccountByID(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plaintext, prefix, hash, err := middleware.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	key := &models.APIKey{
+		ServiceAccountID: uint(id),
+		Name:             req.Name,
+		Prefix:           prefix,
+		KeyHash:          hash,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.CreateAPIKey(key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		Key:    plaintext,
+		APIKey: *key,
+	})
+}
+
+// ListAPIKeys returns the keys of a service account without their secrets (admin only)
+func ListAPIKeys(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	if _, err := database.DB.GetServiceAccountByID(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service account not found"})
+		return
+	}
+
+	keys := database.DB.GetAPIKeysForServiceAccount(uint(id))
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey revokes a service account key immediately (admin only)
+func RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("keyId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return
+	}
+
+	key, err := database.DB.GetAPIKeyByID(uint(keyID))
+	if err != nil || key.ServiceAccountID != uint(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if err := database.DB.RevokeAPIKey(key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionResponse struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+// toSessionResponses marks the session used by the current request and orders by most recently seen
+func toSessionResponses(c *gin.Context, sessions []*models.Session) []SessionResponse {
+	var currentID uint
+	if current, ok := middleware.GetCurrentSession(c); ok {
+		currentID = current.ID
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	result := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, SessionResponse{Session: s, Current: s.ID == currentID})
+	}
+	return result
+}
+
+// ListMySessions returns the active sessions of the current user
+func ListMySessions(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions := database.DB.GetAllSessions(map[string]interface{}{
+		"user_id": user.ID,
+		"active":  true,
+	})
+	c.JSON(http.StatusOK, toSessionResponses(c, sessions))
+}
+
+// RevokeMySession signs out one of the current user's sessions
+func RevokeMySession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	session, err := database.DB.GetSessionByID(uint(id))
+	if err != nil || session.UserID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := database.DB.RevokeSession(session.ID, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// ListSessions returns sessions across all users (admin only)
+func ListSessions(c *gin.Context) {
+	filters := make(map[string]interface{})
+
+	// Filter by user if provided
+	if userID := c.Query("user_id"); userID != "" {
+		if id, err := strconv.ParseUint(userID, 10, 32); err == nil {
+			filters["user_id"] = uint(id)
+		}
+	}
+
+	// Only active sessions unless all=true is requested
+	if c.Query("all") != "true" {
+		filters["active"] = true
+	}
+
+	sessions := database.DB.GetAllSessions(filters)
+	c.JSON(http.StatusOK, toSessionResponses(c, sessions))
+}
+
+// RevokeSession signs out any user's session (admin only)
+func RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	admin, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := database.DB.RevokeSession(uint(id), admin.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeUserSessions signs a user out of every device (admin only)
+func RevokeUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	admin, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if _, err := database.DB.GetUserByID(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	database.DB.RevokeUserSessions(uint(id), admin.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "User sessions revoked successfully"})
+}
@@ -25,6 +25,7 @@ type UpdateUserRequest struct {
 	Role     models.UserRole `json:"role" binding:"required,oneof=data_entry dashboard_visitor dashboard_cargo admin"`
 	FullName string          `json:"full_name"`
 	LocationID *uint         `json:"location_id"`
+	Disabled *bool           `json:"disabled"`
 }
 
 // CreateUser creates a new user (admin only)
@@ -54,6 +55,7 @@ func CreateUser(c *gin.Context) {
 		Role:         req.Role,
 		FullName:     req.FullName,
 		LocationID:   req.LocationID,
+		AuthProvider: models.AuthProviderLocal,
 	}
 
 	if err := database.DB.CreateUser(user); err != nil {
@@ -135,6 +137,10 @@ func UpdateUser(c *gin.Context) {
 		user.LocationID = req.LocationID
 	}
 
+	if req.Disabled != nil {
+		user.Disabled = *req.Disabled
+	}
+
 	if err := database.DB.UpdateUser(user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
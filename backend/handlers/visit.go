@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/jobs"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"digital-logbook/notify"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateVisitRequest struct {
+	AreaOfVisit       string     `json:"area_of_visit"` // Or area_id where the location has managed areas
+	AreaID            uint       `json:"area_id"`
+	Purpose           string     `json:"purpose" binding:"required"`
+	HostName          string     `json:"host_name"`
+	HostID            uint       `json:"host_id"`            // Sets host_name; 0 unlinks the visit from the directory
+	ExpectedDeparture *time.Time `json:"expected_departure"` // Optional; extending it clears the overdue flag
+}
+
+// VisitReport summarises the visits that started within a period
+type VisitReport struct {
+	From                   time.Time      `json:"from"`
+	To                     time.Time      `json:"to"`
+	TotalVisits            int            `json:"total_visits"`
+	UniqueVisitors         int            `json:"unique_visitors"`
+	OnSite                 int            `json:"on_site"`
+	Overdue                int            `json:"overdue"` // On site past their expected departure
+	SignedOut              int            `json:"signed_out"`
+	AutoSignedOut          int            `json:"auto_signed_out"`          // Closed by the end-of-day sweep; included in signed_out
+	AverageDurationMinutes float64        `json:"average_duration_minutes"` // Completed visits only
+	ByArea                 map[string]int `json:"by_area"`
+	ByLocation             map[string]int `json:"by_location"` // Keyed by location code
+}
+
+// signOutVisit closes the visit, returns its badge to inventory and tells the host
+func signOutVisit(visit *models.Visit, by *models.User, items *itemCheck) error {
+	if err := closeVisit(visit, by, items); err != nil {
+		return err
+	}
+	notifyHost(notify.EventVisitorDeparted, visit)
+	return nil
+}
+
+// closeVisit signs the visit out with the exit check of its items, returns its
+// badge to inventory, frees its parking bay and ends its escort
+func closeVisit(visit *models.Visit, by *models.User, items *itemCheck) error {
+	if err := visit.SignOut(time.Now(), actorID(by)); err != nil {
+		return err
+	}
+	if err := database.DB.CloseVisit(visit, items.checked, items.undeclared); err != nil {
+		return err
+	}
+	visit.Items = append(visit.Items, items.undeclared...)
+	if visit.BadgeID != nil {
+		database.DB.ReleaseBadge(*visit.BadgeID, visit.ID)
+	}
+	if visit.ParkingBayID != nil {
+		database.DB.ReleaseParkingBay(*visit.ParkingBayID, visit.ID)
+	}
+	if visit.EscortID != nil {
+		database.DB.EndEscort(visit.ID, *visit.SignOutTime)
+	}
+	return nil
+}
+
+// respondVisitError maps errors from creating a visit or changing its status to a response
+func respondVisitError(c *gin.Context, err error, fallback string) {
+	var transition *models.TransitionError
+	switch {
+	case errors.As(err, &transition):
+		respondTransitionError(c, transition)
+	case errors.Is(err, database.ErrAlreadySignedIn):
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor already signed in"})
+	case errors.Is(err, database.ErrAlreadyPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor is already waiting at reception to be signed in"})
+	case errors.Is(err, database.ErrBadgeNotInInventory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Badge is not in this location's inventory"})
+	case errors.Is(err, database.ErrBadgeUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Badge is already issued or out of service"})
+	case errors.Is(err, database.ErrBadgeNotForArea):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Badge does not grant access to this area"})
+	case errors.Is(err, database.ErrAreaFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "Area is at maximum occupancy"})
+	case errors.Is(err, database.ErrBayNotInInventory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parking bay is not in this location's inventory"})
+	case errors.Is(err, database.ErrBayUnavailable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Parking bay is occupied or out of service"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// respondTransitionError refuses a status change the visit's current status does not allow
+func respondTransitionError(c *gin.Context, err *models.TransitionError) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":     "Visit is " + string(err.From) + " and cannot become " + string(err.To),
+		"status":    err.From,
+		"requested": err.To,
+	})
+}
+
+// expectedDeparture works out when a visitor signing in at signIn should leave,
+// from an explicit time or a duration, defaulting to models.DefaultVisitDuration
+func expectedDeparture(c *gin.Context, signIn time.Time, departure *time.Time, minutes int) (*time.Time, bool) {
+	if departure != nil && minutes != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either expected_departure or expected_duration_minutes, not both"})
+		return nil, false
+	}
+	if minutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expected duration must be positive"})
+		return nil, false
+	}
+
+	var t time.Time
+	switch {
+	case departure != nil:
+		if !departure.After(signIn) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expected departure must be in the future"})
+			return nil, false
+		}
+		t = *departure
+	case minutes > 0:
+		t = signIn.Add(time.Duration(minutes) * time.Minute)
+	default:
+		t = signIn.Add(models.DefaultVisitDuration)
+	}
+	return &t, true
+}
+
+// actorID is the user to record against a status change; service accounts
+// and kiosks have no user ID, so they are recorded as nil
+func actorID(user *models.User) *uint {
+	if user == nil || user.ID == 0 {
+		return nil
+	}
+	return &user.ID
+}
+
+// canAccessVisit checks that a location-bound user only touches visits at their location
+func canAccessVisit(user *models.User, visit *models.Visit) bool {
+	return user.LocationID == nil || *user.LocationID == visit.LocationID
+}
+
+// parseTimeParam accepts a date (2006-01-02) or an RFC 3339 timestamp
+func parseTimeParam(value string) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// visitFilters builds the location, status and period filters shared by visit listings
+func visitFilters(c *gin.Context, user *models.User) (map[string]interface{}, bool) {
+	filters := make(map[string]interface{})
+
+	// If user is restricted to a location, force that filter
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	} else if locID := c.Query("location_id"); locID != "" {
+		if id, err := strconv.ParseUint(locID, 10, 32); err == nil {
+			filters["location_id"] = uint(id)
+		}
+	}
+
+	if status := c.Query("status"); status != "" {
+		filters["status"] = status
+	}
+	if visitorID := c.Query("visitor_id"); visitorID != "" {
+		if id, err := strconv.ParseUint(visitorID, 10, 32); err == nil {
+			filters["visitor_id"] = uint(id)
+		}
+	}
+	if overdue := c.Query("overdue"); overdue != "" {
+		filters["overdue"] = overdue == "true"
+	}
+	if areaID := c.Query("area_id"); areaID != "" {
+		if id, err := strconv.ParseUint(areaID, 10, 32); err == nil {
+			filters["area_id"] = uint(id)
+		}
+	}
+	if registration := c.Query("vehicle_registration"); registration != "" {
+		filters["vehicle_registration"] = registration
+	}
+
+	if from := c.Query("from"); from != "" {
+		t, ok := parseTimeParam(from)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return nil, false
+		}
+		filters["from"] = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, ok := parseTimeParam(to)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return nil, false
+		}
+		// A bare date includes the whole day
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		filters["to"] = t
+	}
+
+	return filters, true
+}
+
+// ListVisits returns visits across all visitors, newest first
+func ListVisits(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters, ok := visitFilters(c, user)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllVisits(filters))
+}
+
+// GetVisit returns a specific visit by ID
+func GetVisit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// GetVisitStatusHistory returns each status change of a visit with when and
+// by whom it was made, oldest first
+func GetVisitStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetVisitStatusHistory(visit.ID))
+}
+
+// SignOutVisit closes a specific visit, checking the items declared at sign-in
+func SignOutVisit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req SignOutVisitorRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	if !visit.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor already signed out", "status": visit.Status})
+		return
+	}
+	items, ok := checkVisitItems(c, user, visit, &req)
+	if !ok {
+		return
+	}
+
+	if err := signOutVisit(visit, user, items); err != nil {
+		respondVisitError(c, err, "Failed to sign out visitor")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// UpdateVisit corrects the details of a visit (admin only)
+func UpdateVisit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	if visit.Status.IsFinal() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visit is " + string(visit.Status) + " and can no longer be changed", "status": visit.Status})
+		return
+	}
+
+	var req UpdateVisitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// An unchanged area is kept even if it has been retired since
+	areaChanged := !models.SameAreaName(req.AreaOfVisit, visit.AreaOfVisit)
+	if req.AreaID != 0 {
+		areaChanged = visit.AreaID == nil || *visit.AreaID != req.AreaID
+	}
+	if areaChanged {
+		area, ok := resolveArea(c, visit.LocationID, req.AreaID, req.AreaOfVisit)
+		if !ok {
+			return
+		}
+		setVisitArea(visit, area, req.AreaOfVisit)
+	}
+	visit.Purpose = req.Purpose
+	visit.HostName = req.HostName
+	visit.HostID = nil
+	visit.Host = nil
+	if req.HostID != 0 {
+		host, err := database.DB.GetHostByID(req.HostID)
+		if err != nil || host.LocationID != visit.LocationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Host not found at this location"})
+			return
+		}
+		setVisitHost(visit, host)
+	}
+	if req.ExpectedDeparture != nil {
+		if !req.ExpectedDeparture.After(visit.SignInTime) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expected departure must be after sign-in"})
+			return
+		}
+		visit.ExpectedDeparture = req.ExpectedDeparture
+		if visit.OverdueAt != nil && req.ExpectedDeparture.After(time.Now()) {
+			visit.OverdueAt = nil
+		}
+	}
+
+	if err := database.DB.UpdateVisit(visit); err != nil {
+		respondVisitError(c, err, "Failed to update visit")
+		return
+	}
+
+	c.JSON(http.StatusOK, visit)
+}
+
+// DeleteVisit deletes a single visit from a visitor's history (admin only)
+func DeleteVisit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	// The visit can only have gone since it was loaded
+	if err := database.DB.DeleteVisit(visit.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	jobs.RemoveOrphanedBlobs(c.Request.Context(), imageStore)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Visit deleted successfully"})
+}
+
+// GetVisitReport computes visit statistics for a period (default: today)
+func GetVisitReport(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters, ok := visitFilters(c, user)
+	if !ok {
+		return
+	}
+	if _, ok := filters["from"]; !ok {
+		now := time.Now()
+		filters["from"] = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	}
+	if _, ok := filters["to"]; !ok {
+		filters["to"] = time.Now()
+	}
+
+	report := VisitReport{
+		From:       filters["from"].(time.Time),
+		To:         filters["to"].(time.Time),
+		ByArea:     make(map[string]int),
+		ByLocation: make(map[string]int),
+	}
+
+	now := time.Now()
+	visitorIDs := make(map[uint]bool)
+	var completed int
+	var totalDuration time.Duration
+	for _, visit := range database.DB.GetAllVisits(filters) {
+		if !visit.HasStarted() {
+			continue
+		}
+		report.TotalVisits++
+		visitorIDs[visit.VisitorID] = true
+		report.ByArea[visit.AreaOfVisit]++
+		if visit.Location != nil {
+			report.ByLocation[visit.Location.Code]++
+		}
+
+		if visit.IsActive() {
+			report.OnSite++
+			if visit.IsOverdue(now) {
+				report.Overdue++
+			}
+		} else {
+			report.SignedOut++
+			if visit.Status == models.StatusAutoSignedOut {
+				report.AutoSignedOut++
+			}
+			completed++
+			totalDuration += visit.Duration()
+		}
+	}
+	report.UniqueVisitors = len(visitorIDs)
+	if completed > 0 {
+		report.AverageDurationMinutes = totalDuration.Minutes() / float64(completed)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"digital-logbook/badgeprint"
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// badgeSigner signs the QR codes on printed badges; nil disables printing
+var badgeSigner *badgeprint.Signer
+
+// ConfigureBadgeSigner enables printed badges and sign-out by QR code
+func ConfigureBadgeSigner(signer *badgeprint.Signer) {
+	badgeSigner = signer
+}
+
+type SignOutByQRRequest struct {
+	Code string `json:"code" binding:"required"` // As read by the scanner
+	SignOutVisitorRequest
+}
+
+// badgeCodeGrace is how long past its valid-until time a badge still signs its
+// holder out, so visitors who overstay can leave the same way as everyone else
+const badgeCodeGrace = 12 * time.Hour
+
+// badgeValidUntil is when the printed badge stops working: the visit's expected departure
+func badgeValidUntil(visit *models.Visit) time.Time {
+	if visit.ExpectedDeparture != nil {
+		return *visit.ExpectedDeparture
+	}
+	return visit.SignInTime.Add(models.DefaultVisitDuration)
+}
+
+// PrintVisitBadge renders a temporary badge for a visitor on site, as a PDF
+// or, with ?format=zpl, for a Zebra printer
+func PrintVisitBadge(c *gin.Context) {
+	if badgeSigner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Badge printing is not configured"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "zpl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be pdf or zpl"})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	if !visit.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Badges can only be printed for visitors who are signed in"})
+		return
+	}
+
+	validUntil := badgeValidUntil(visit)
+	badge := &badgeprint.Badge{
+		Area:        visit.AreaOfVisit,
+		Host:        visit.HostName,
+		BadgeNumber: visit.BadgeNumber,
+		ValidUntil:  validUntil,
+		Code:        badgeSigner.Sign(visit.ID, validUntil),
+	}
+	if visit.Visitor != nil {
+		badge.VisitorName = visit.Visitor.Name
+		badge.Company = visit.Visitor.CompanyFrom
+	}
+	if visit.Location != nil {
+		badge.Location = visit.Location.Name
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	if format == "zpl" {
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="badge-%d.zpl"`, visit.ID))
+		c.Data(http.StatusOK, "application/zpl", badge.ZPL())
+		return
+	}
+
+	pdf, err := badge.PDF()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render badge"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="badge-%d.pdf"`, visit.ID))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// verifyBadgeCode checks a scanned badge code, allowing badgeCodeGrace past
+// its expiry, and returns the visit it was printed for; expired tells the
+// holder what to do instead
+func verifyBadgeCode(c *gin.Context, code, expired string) (uint, bool) {
+	visitID, err := badgeSigner.Verify(code, time.Now().Add(-badgeCodeGrace))
+	switch {
+	case errors.Is(err, badgeprint.ErrMalformed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code is not a visitor badge"})
+		return 0, false
+	case errors.Is(err, badgeprint.ErrForged):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Badge code is not valid"})
+		return 0, false
+	case errors.Is(err, badgeprint.ErrExpired):
+		c.JSON(http.StatusForbidden, gin.H{"error": expired, "visit_id": visitID})
+		return 0, false
+	}
+	return visitID, true
+}
+
+// SignOutVisitorByQR signs out the visitor whose printed badge was scanned
+func SignOutVisitorByQR(c *gin.Context) {
+	if badgeSigner == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Badge printing is not configured"})
+		return
+	}
+
+	var req SignOutByQRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	visitID, ok := verifyBadgeCode(c, req.Code, "Badge has expired; sign the visitor out by badge number or from the visit")
+	if !ok {
+		return
+	}
+
+	visit, err := database.DB.GetVisitByID(visitID)
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+	if !visit.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor is already signed out"})
+		return
+	}
+	items, ok := checkVisitItems(c, user, visit, &req.SignOutVisitorRequest)
+	if !ok {
+		return
+	}
+
+	if err := signOutVisit(visit, user, items); err != nil {
+		respondVisitError(c, err, "Failed to sign out visitor")
+		return
+	}
+
+	visitor := visit.Visitor
+	visit.Visitor = nil
+	visitor.CurrentVisit = visit
+	c.JSON(http.StatusOK, visitor)
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"digital-logbook/database"
+	"digital-logbook/imaging"
+	"digital-logbook/jobs"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"digital-logbook/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImageSize  = 10 << 20 // Bytes per uploaded image
+	thumbnailSize = 240      // Longest side of a thumbnail in pixels
+)
+
+// imageStore is nil when image uploads are not configured
+var imageStore storage.BlobStore
+
+// ConfigureImageStore enables visit photo and ID document uploads
+func ConfigureImageStore(store storage.BlobStore) {
+	imageStore = store
+}
+
+// canViewImage applies the role rules for images: visitor operators see
+// photos, but ID document scans are limited to data entry operators and admins
+func canViewImage(user *models.User, img *models.VisitImage) bool {
+	if img.Kind == models.ImageIDDocument {
+		return user.Role == models.RoleDataEntry || user.Role == models.RoleAdmin
+	}
+	return true
+}
+
+// visitForImages loads the visit in the URL if the user may access its location
+func visitForImages(c *gin.Context) (*models.Visit, *models.User, bool) {
+	if imageStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Image storage is not configured"})
+		return nil, nil, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	visit, err := database.DB.GetVisitByID(uint(id))
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return nil, nil, false
+	}
+	return visit, user, true
+}
+
+// imageFromParam loads the visit image in the URL if the user may see it
+func imageFromParam(c *gin.Context) (*models.VisitImage, *models.User, bool) {
+	visit, user, ok := visitForImages(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	imageID, err := strconv.ParseUint(c.Param("imageId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+		return nil, nil, false
+	}
+
+	img, err := database.DB.GetVisitImageByID(uint(imageID))
+	if err != nil || img.VisitID != visit.ID || !canViewImage(user, img) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return nil, nil, false
+	}
+	return img, user, true
+}
+
+// newBlobName returns a random file name so image URLs cannot be guessed from the store
+func newBlobName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UploadVisitImage attaches a visitor photo or ID document scan to a visit.
+// Multipart fields: file (JPEG or PNG) and kind (photo or id_document).
+func UploadVisitImage(c *gin.Context) {
+	visit, user, ok := visitForImages(c)
+	if !ok {
+		return
+	}
+
+	kind := models.VisitImageKind(c.PostForm("kind"))
+	if kind == "" {
+		kind = models.ImageVisitorPhoto
+	}
+	if !kind.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kind must be photo or id_document"})
+		return
+	}
+	if !canViewImage(user, &models.VisitImage{Kind: kind}) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageSize+1<<20)
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Images are limited to %d MB", maxImageSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An image file is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxImageSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image"})
+		return
+	}
+	if len(data) > maxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Images are limited to %d MB", maxImageSize>>20)})
+		return
+	}
+
+	decoded, format, err := imaging.Decode(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image must be a JPEG or PNG of at most 40 megapixels"})
+		return
+	}
+	var thumb bytes.Buffer
+	if err := imaging.EncodeJPEG(&thumb, imaging.Thumbnail(decoded, thumbnailSize)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create thumbnail"})
+		return
+	}
+
+	name, err := newBlobName()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	ext, contentType := "jpg", "image/jpeg"
+	if format == "png" {
+		ext, contentType = "png", "image/png"
+	}
+
+	img := &models.VisitImage{
+		VisitID:      visit.ID,
+		LocationID:   visit.LocationID,
+		Kind:         kind,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        decoded.Bounds().Dx(),
+		Height:       decoded.Bounds().Dy(),
+		BlobKey:      fmt.Sprintf("visits/%d/%s.%s", visit.ID, name, ext),
+		ThumbnailKey: fmt.Sprintf("visits/%d/%s_thumb.jpg", visit.ID, name),
+		UploadedBy:   user.ID,
+	}
+
+	ctx := c.Request.Context()
+	if err := imageStore.Put(ctx, img.BlobKey, bytes.NewReader(data)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := imageStore.Put(ctx, img.ThumbnailKey, &thumb); err != nil {
+		imageStore.Delete(ctx, img.BlobKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := database.DB.CreateVisitImage(img); err != nil {
+		imageStore.Delete(ctx, img.BlobKey)
+		imageStore.Delete(ctx, img.ThumbnailKey)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visit not found"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, img)
+}
+
+// ListVisitImages returns the images of a visit the user may see
+func ListVisitImages(c *gin.Context) {
+	visit, user, ok := visitForImages(c)
+	if !ok {
+		return
+	}
+
+	images := make([]*models.VisitImage, 0)
+	for _, img := range database.DB.GetVisitImages(visit.ID) {
+		if canViewImage(user, img) {
+			images = append(images, img)
+		}
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// GetVisitImage streams an image, or its thumbnail with ?thumbnail=true.
+// Viewing a full ID document scan is recorded in the audit log.
+func GetVisitImage(c *gin.Context) {
+	img, _, ok := imageFromParam(c)
+	if !ok {
+		return
+	}
+
+	key, contentType := img.BlobKey, img.ContentType
+	thumbnail := c.Query("thumbnail") == "true"
+	if thumbnail {
+		key, contentType = img.ThumbnailKey, "image/jpeg"
+	}
+
+	blob, err := imageStore.Get(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	defer blob.Close()
+
+	if img.Kind == models.ImageIDDocument && !thumbnail {
+		recordAudit(c, models.AuditIDDocumentViewed, "visit", img.VisitID, &img.LocationID, map[string]interface{}{
+			"image_id": img.ID,
+		})
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, blob, nil)
+}
+
+// DeleteVisitImage removes an image and its files (admin only)
+func DeleteVisitImage(c *gin.Context) {
+	img, _, ok := imageFromParam(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.DeleteVisitImage(img.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	jobs.RemoveOrphanedBlobs(c.Request.Context(), imageStore)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"digital-logbook/database"
+	"digital-logbook/jobs"
 	"digital-logbook/middleware"
 	"digital-logbook/models"
+	"digital-logbook/notify"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,21 +13,78 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// CreateVisitorRequest registers a visit. Repeat visitors pass visitor_id to
+// link the visit to their existing profile, in which case name and ID number
+// may be omitted.
 type CreateVisitorRequest struct {
+	VisitorID   uint               `json:"visitor_id"`
+	Name        string             `json:"name"`
+	IDNumber    string             `json:"id_number"`
+	AreaOfVisit string             `json:"area_of_visit"` // Free text only where the location has no managed areas
+	AreaID      uint               `json:"area_id"`
+	CompanyFrom string             `json:"company_from"`
+	Purpose     string             `json:"purpose" binding:"required"`
+	HostName    string             `json:"host_name"`
+	HostID      uint               `json:"host_id"` // From the host directory; sets host_name
+	EscortID    uint               `json:"escort_id"`
+	BadgeNumber string             `json:"badge_number" binding:"required"`
+	LocationID  uint               `json:"location_id"`
+	Override    *WatchlistOverride `json:"override"` // Needed when the visitor matches a warning entry
+	// Optional; the visit defaults to models.DefaultVisitDuration
+	ExpectedDeparture       *time.Time `json:"expected_departure"`
+	ExpectedDurationMinutes int        `json:"expected_duration_minutes"`
+	// Safety inductions and NDAs the visitor accepted, required when the area has any outstanding
+	Acknowledgements []AcknowledgementRequest `json:"acknowledgements"`
+	Items            []DeclaredItemRequest    `json:"items"` // Equipment brought on site, checked at sign-out
+	VehicleDetails
+}
+
+type UpdateVisitorRequest struct {
 	Name        string `json:"name" binding:"required"`
 	IDNumber    string `json:"id_number" binding:"required"`
-	AreaOfVisit string `json:"area_of_visit" binding:"required"`
 	CompanyFrom string `json:"company_from"`
-	Purpose     string `json:"purpose" binding:"required"`
+}
+
+type SignOutByBadgeRequest struct {
 	BadgeNumber string `json:"badge_number" binding:"required"`
 	LocationID  uint   `json:"location_id"`
+	SignOutVisitorRequest
 }
 
+// VisitorLookupResponse prefills sign-in for a returning visitor
+type VisitorLookupResponse struct {
+	Visitor    *models.Visitor `json:"visitor"`
+	VisitCount int             `json:"visit_count"`
+	LastVisit  *models.Visit   `json:"last_visit,omitempty"`
+	Flags      []string        `json:"flags"`
+}
+
+// Flags reported by the visitor lookup
+const (
+	VisitorFlagOnSite            = "on_site"            // Already signed in
+	VisitorFlagDuplicateProfiles = "duplicate_profiles" // Several profiles share the ID number
+)
+
+// SignInRequest starts a new visit; area and purpose default to the previous visit's
 type SignInRequest struct {
-	BadgeNumber string `json:"badge_number" binding:"required"`
+	BadgeNumber string             `json:"badge_number" binding:"required"`
+	AreaOfVisit string             `json:"area_of_visit"`
+	AreaID      uint               `json:"area_id"`
+	Purpose     string             `json:"purpose"`
+	HostName    string             `json:"host_name"`
+	HostID      uint               `json:"host_id"`
+	EscortID    uint               `json:"escort_id"`
+	LocationID  uint               `json:"location_id"`
+	Override    *WatchlistOverride `json:"override"` // Needed when the visitor matches a warning entry
+	// Optional; the visit defaults to models.DefaultVisitDuration
+	ExpectedDeparture       *time.Time               `json:"expected_departure"`
+	ExpectedDurationMinutes int                      `json:"expected_duration_minutes"`
+	Acknowledgements        []AcknowledgementRequest `json:"acknowledgements"`
+	Items                   []DeclaredItemRequest    `json:"items"`
+	VehicleDetails
 }
 
-// CreateVisitor creates a new visitor entry (data_entry or admin only)
+// CreateVisitor registers a visitor and signs them in for their first visit (data_entry or admin only)
 func CreateVisitor(c *gin.Context) {
 	var req CreateVisitorRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -52,27 +111,123 @@ func CreateVisitor(c *gin.Context) {
 		}
 	}
 
-	visitor := &models.Visitor{
-		Name:        req.Name,
-		IDNumber:    req.IDNumber,
-		AreaOfVisit: req.AreaOfVisit,
-		CompanyFrom: req.CompanyFrom,
-		Purpose:     req.Purpose,
-		BadgeNumber: req.BadgeNumber,
-		Status:      models.StatusSignedIn,
-		SignInTime:  time.Now(),
-		LocationID:  locationID,
+	signInTime := time.Now()
+	departure, ok := expectedDeparture(c, signInTime, req.ExpectedDeparture, req.ExpectedDurationMinutes)
+	if !ok {
+		return
+	}
+	items, ok := declaredItemsOrAbort(c, req.Items)
+	if !ok {
+		return
+	}
+	if !checkBadgeAvailable(c, locationID, req.BadgeNumber) {
+		return
+	}
+	area, ok := resolveArea(c, locationID, req.AreaID, req.AreaOfVisit)
+	if !ok {
+		return
+	}
+	if !areaOrAbort(c, area, req.BadgeNumber, 1) {
+		return
+	}
+	escort, ok := resolveEscort(c, req.EscortID, locationID, area)
+	if !ok {
+		return
+	}
+	if !vehicleOrAbort(c, locationID, req.VehicleDetails) {
+		return
+	}
+	host, ok := resolveHost(c, req.HostID, locationID)
+	if !ok {
+		return
+	}
+
+	var visitor *models.Visitor
+	isNewVisitor := req.VisitorID == 0
+	if !isNewVisitor {
+		// Link the visit to the returning visitor's profile
+		visitor, err = database.DB.GetVisitorByID(req.VisitorID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+			return
+		}
+		if _, err := database.DB.GetOpenVisit(visitor.ID); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Visitor already signed in"})
+			return
+		}
+		if !screenVisitor(c, user, visitor.ID, visitor.Name, visitor.IDNumber, locationID, req.Override) {
+			return
+		}
+	} else {
+		if req.Name == "" || req.IDNumber == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name and ID number are required for new visitors"})
+			return
+		}
+		if !screenVisitor(c, user, 0, req.Name, req.IDNumber, locationID, req.Override) {
+			return
+		}
+
+		visitor = &models.Visitor{
+			Name:        req.Name,
+			IDNumber:    req.IDNumber,
+			CompanyFrom: req.CompanyFrom,
+		}
+	}
+
+	acks, ok := acceptAcknowledgements(c, user, visitor.ID, locationID, areaName(area, req.AreaOfVisit), req.Acknowledgements)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if isNewVisitor {
+		if err := database.DB.CreateVisitor(visitor); err != nil {
+			discardSignatures(ctx, acks)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create visitor"})
+			return
+		}
+	} else if req.CompanyFrom != "" && req.CompanyFrom != visitor.CompanyFrom {
+		visitor.CompanyFrom = req.CompanyFrom
+		visitor.UpdatedAt = time.Now()
+		if err := database.DB.UpdateVisitor(visitor); err != nil {
+			discardSignatures(ctx, acks)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visitor"})
+			return
+		}
 	}
 
-	if err := database.DB.CreateVisitor(visitor); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create visitor"})
+	visit := &models.Visit{
+		VisitorID:         visitor.ID,
+		Purpose:           req.Purpose,
+		HostName:          req.HostName,
+		BadgeNumber:       req.BadgeNumber,
+		Items:             items,
+		ExpectedDeparture: departure,
+		LocationID:        locationID,
+	}
+	visit.SignIn(signInTime, actorID(user))
+	setVisitArea(visit, area, req.AreaOfVisit)
+	setVisitEscort(visit, escort)
+	req.VehicleDetails.applyTo(visit)
+	setVisitHost(visit, host)
+
+	if err := database.DB.CreateVisit(visit); err != nil {
+		if isNewVisitor {
+			// Don't leave a profile behind for a visit that never happened
+			database.DB.DeleteVisitor(visitor.ID)
+		}
+		discardSignatures(ctx, acks)
+		respondVisitError(c, err, "Failed to create visit")
 		return
 	}
+	recordAcknowledgements(ctx, acks, visitor.ID, visit.ID)
+	recordEscort(visit, actorID(user))
+	notifyHost(notify.EventVisitorArrived, visit)
 
+	visitor.CurrentVisit = visit
 	c.JSON(http.StatusCreated, visitor)
 }
 
-// SignInVisitor assigns a badge and marks visitor as signed in
+// SignInVisitor starts a new visit for a returning visitor, keeping earlier visits as history
 func SignInVisitor(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -86,23 +241,138 @@ func SignInVisitor(c *gin.Context) {
 		return
 	}
 
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	visitor, err := database.DB.GetVisitorByID(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
 		return
 	}
 
-	visitor.SignIn(req.BadgeNumber)
+	if _, err := database.DB.GetOpenVisit(visitor.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor already signed in"})
+		return
+	}
 
-	if err := database.DB.UpdateVisitor(visitor); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in visitor"})
+	signInTime := time.Now()
+	departure, ok := expectedDeparture(c, signInTime, req.ExpectedDeparture, req.ExpectedDurationMinutes)
+	if !ok {
+		return
+	}
+	items, ok := declaredItemsOrAbort(c, req.Items)
+	if !ok {
+		return
+	}
+
+	// Returning visitors usually come back for the same reason
+	visit := &models.Visit{
+		VisitorID:         visitor.ID,
+		AreaOfVisit:       req.AreaOfVisit,
+		Purpose:           req.Purpose,
+		HostName:          req.HostName,
+		BadgeNumber:       req.BadgeNumber,
+		Items:             items,
+		ExpectedDeparture: departure,
+	}
+	visit.SignIn(signInTime, actorID(user))
+	if last := visitor.CurrentVisit; last != nil {
+		if visit.AreaOfVisit == "" && req.AreaID == 0 {
+			visit.AreaOfVisit = last.AreaOfVisit
+			visit.AreaID = last.AreaID
+		}
+		if visit.Purpose == "" {
+			visit.Purpose = last.Purpose
+		}
+		if visit.HostName == "" && req.HostID == 0 {
+			visit.HostName = last.HostName
+			visit.HostID = last.HostID
+		}
+		visit.LocationID = last.LocationID
+	}
+	if (visit.AreaOfVisit == "" && req.AreaID == 0) || visit.Purpose == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Area of visit and purpose are required"})
+		return
+	}
+
+	if user.LocationID != nil {
+		visit.LocationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		visit.LocationID = req.LocationID
+	} else if visit.LocationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+
+	if req.HostID != 0 {
+		host, ok := resolveHost(c, req.HostID, visit.LocationID)
+		if !ok {
+			return
+		}
+		setVisitHost(visit, host)
+	} else if visit.HostID != nil {
+		// Only carry the previous host over if they can still be visited here
+		if host, err := database.DB.GetHostByID(*visit.HostID); err == nil && host.Active && host.LocationID == visit.LocationID {
+			setVisitHost(visit, host)
+		} else {
+			visit.HostID = nil
+		}
+	}
+
+	if !checkBadgeAvailable(c, visit.LocationID, visit.BadgeNumber) {
+		return
+	}
+	areaID := req.AreaID
+	if areaID == 0 && visit.AreaID != nil {
+		// Keep the previous visit's area if it can still be visited here, otherwise go by its name
+		if prev, err := database.DB.GetAreaByID(*visit.AreaID); err == nil && prev.Active && prev.LocationID == visit.LocationID {
+			areaID = prev.ID
+		}
+	}
+	area, ok := resolveArea(c, visit.LocationID, areaID, visit.AreaOfVisit)
+	if !ok {
+		return
+	}
+	if !areaOrAbort(c, area, visit.BadgeNumber, 1) {
+		return
+	}
+	setVisitArea(visit, area, visit.AreaOfVisit)
+	escort, ok := resolveEscort(c, req.EscortID, visit.LocationID, area)
+	if !ok {
+		return
+	}
+	setVisitEscort(visit, escort)
+	if !vehicleOrAbort(c, visit.LocationID, req.VehicleDetails) {
+		return
+	}
+	req.VehicleDetails.applyTo(visit)
+	if !screenVisitor(c, user, visitor.ID, visitor.Name, visitor.IDNumber, visit.LocationID, req.Override) {
+		return
+	}
+	acks, ok := acceptAcknowledgements(c, user, visitor.ID, visit.LocationID, visit.AreaOfVisit, req.Acknowledgements)
+	if !ok {
 		return
 	}
 
+	ctx := c.Request.Context()
+	if err := database.DB.CreateVisit(visit); err != nil {
+		discardSignatures(ctx, acks)
+		respondVisitError(c, err, "Failed to sign in visitor")
+		return
+	}
+	recordAcknowledgements(ctx, acks, visitor.ID, visit.ID)
+	recordEscort(visit, actorID(user))
+	notifyHost(notify.EventVisitorArrived, visit)
+
+	visitor.CurrentVisit = visit
 	c.JSON(http.StatusOK, visitor)
 }
 
-// SignOutVisitor marks visitor as signed out
+// SignOutVisitor closes the visitor's open visit, checking the items they
+// declared at sign-in
 func SignOutVisitor(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -110,28 +380,108 @@ func SignOutVisitor(c *gin.Context) {
 		return
 	}
 
+	var req SignOutVisitorRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	visitor, err := database.DB.GetVisitorByID(uint(id))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
 		return
 	}
 
-	if visitor.Status == models.StatusSignedOut {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Visitor already signed out"})
+	visit, err := database.DB.GetOpenVisit(visitor.ID)
+	if err != nil || !canAccessVisit(user, visit) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Visitor already signed out"})
+		return
+	}
+	items, ok := checkVisitItems(c, user, visit, &req)
+	if !ok {
 		return
 	}
 
-	visitor.SignOut()
+	if err := signOutVisit(visit, user, items); err != nil {
+		respondVisitError(c, err, "Failed to sign out visitor")
+		return
+	}
 
-	if err := database.DB.UpdateVisitor(visitor); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign out visitor"})
+	visit.Visitor = nil
+	visitor.CurrentVisit = visit
+	c.JSON(http.StatusOK, visitor)
+}
+
+// SignOutVisitorByBadge signs out whoever holds a returned badge
+func SignOutVisitorByBadge(c *gin.Context) {
+	var req SignOutByBadgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	} else if req.LocationID != 0 {
+		locationID = req.LocationID
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location ID is required for super admin"})
+		return
+	}
+
+	badge, err := database.DB.GetBadgeByNumber(locationID, req.BadgeNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge is not in this location's inventory"})
 		return
 	}
 
+	switch badge.Status {
+	case models.BadgeIssued:
+	case models.BadgeLost, models.BadgeUnreturned:
+		c.JSON(http.StatusConflict, gin.H{"error": "Badge is marked as " + string(badge.Status) + "; record it as recovered first", "badge": badge})
+		return
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "Badge is not issued to anyone (status: " + string(badge.Status) + ")", "badge": badge})
+		return
+	}
+
+	visit := badge.Visit
+	if visit == nil || !visit.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Badge is not held by a signed-in visitor", "badge": badge})
+		return
+	}
+	items, ok := checkVisitItems(c, user, visit, &req.SignOutVisitorRequest)
+	if !ok {
+		return
+	}
+
+	if err := signOutVisit(visit, user, items); err != nil {
+		respondVisitError(c, err, "Failed to sign out visitor")
+		return
+	}
+
+	visitor := visit.Visitor
+	visit.Visitor = nil
+	visitor.CurrentVisit = visit
 	c.JSON(http.StatusOK, visitor)
 }
 
-// ListVisitors returns all visitors with optional filtering
+// ListVisitors returns visitor profiles with their latest visit, with optional filtering
 func ListVisitors(c *gin.Context) {
 	user, err := middleware.GetCurrentUser(c)
 	if err != nil {
@@ -153,7 +503,7 @@ func ListVisitors(c *gin.Context) {
 		}
 	}
 
-	// Filter by status if provided
+	// Filter by the status of the latest visit if provided
 	status := c.Query("status")
 	if status != "" {
 		filters["status"] = status
@@ -163,38 +513,122 @@ func ListVisitors(c *gin.Context) {
 	c.JSON(http.StatusOK, visitors)
 }
 
-// GetVisitor returns a specific visitor by ID
-func GetVisitor(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+// LookupVisitor finds a returning visitor by ID number to prefill sign-in
+func LookupVisitor(c *gin.Context) {
+	idNumber := c.Query("id_number")
+	if models.NormalizeIDNumber(idNumber) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id_number is required"})
 		return
 	}
 
-	visitor, err := database.DB.GetVisitorByID(uint(id))
+	user, err := middleware.GetCurrentUser(c)
 	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	response := lookupVisitor(user, idNumber)
+	if response == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, visitor)
+	c.JSON(http.StatusOK, response)
 }
 
-// UpdateVisitor updates a visitor entry (admin only)
-func UpdateVisitor(c *gin.Context) {
+// lookupVisitor finds the profile recorded under the ID number with the
+// user's view of their visit history, or returns nil
+func lookupVisitor(user *models.User, idNumber string) *VisitorLookupResponse {
+	profiles := database.DB.GetVisitorsByIDNumber(idNumber)
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	response := &VisitorLookupResponse{
+		Visitor: profiles[0],
+		Flags:   []string{},
+	}
+
+	// Visit history is limited to the user's location like other visit listings
+	filters := map[string]interface{}{"visitor_id": response.Visitor.ID}
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	}
+	visits := database.DB.GetAllVisits(filters)
+	response.VisitCount = len(visits)
+	if len(visits) > 0 {
+		response.LastVisit = visits[0]
+	}
+
+	if response.Visitor.IsActive() {
+		response.Flags = append(response.Flags, VisitorFlagOnSite)
+	}
+	if len(profiles) > 1 {
+		response.Flags = append(response.Flags, VisitorFlagDuplicateProfiles)
+	}
+	return response
+}
+
+// visitorFromParam loads the visitor named by the :id parameter. Users bound
+// to a location only find visitors who have visited it.
+func visitorFromParam(c *gin.Context) (*models.Visitor, *models.User, bool) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
+		return nil, nil, false
 	}
 
-	visitor, err := database.DB.GetVisitorByID(uint(id))
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, nil, false
+	}
+
+	var locationID uint
+	if user.LocationID != nil {
+		locationID = *user.LocationID
+	}
+	visitor, err := database.DB.GetVisitorAtLocation(uint(id), locationID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
+		return nil, nil, false
+	}
+	return visitor, user, true
+}
+
+// GetVisitor returns a specific visitor by ID
+func GetVisitor(c *gin.Context) {
+	visitor, _, ok := visitorFromParam(c)
+	if !ok {
 		return
 	}
 
-	var req CreateVisitorRequest
+	c.JSON(http.StatusOK, visitor)
+}
+
+// ListVisitorVisits returns a visitor's visit history, newest first
+func ListVisitorVisits(c *gin.Context) {
+	visitor, user, ok := visitorFromParam(c)
+	if !ok {
+		return
+	}
+
+	filters := map[string]interface{}{"visitor_id": visitor.ID}
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllVisits(filters))
+}
+
+// UpdateVisitor updates a visitor's profile (admin only)
+func UpdateVisitor(c *gin.Context) {
+	visitor, _, ok := visitorFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateVisitorRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -202,9 +636,8 @@ func UpdateVisitor(c *gin.Context) {
 
 	visitor.Name = req.Name
 	visitor.IDNumber = req.IDNumber
-	visitor.AreaOfVisit = req.AreaOfVisit
 	visitor.CompanyFrom = req.CompanyFrom
-	visitor.Purpose = req.Purpose
+	visitor.UpdatedAt = time.Now()
 
 	if err := database.DB.UpdateVisitor(visitor); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update visitor"})
@@ -214,18 +647,29 @@ func UpdateVisitor(c *gin.Context) {
 	c.JSON(http.StatusOK, visitor)
 }
 
-// DeleteVisitor deletes a visitor entry (admin only)
+// DeleteVisitor deletes a visitor and their visit history (admin only)
 func DeleteVisitor(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+	visitor, user, ok := visitorFromParam(c)
+	if !ok {
 		return
 	}
 
-	if err := database.DB.DeleteVisitor(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete visitor"})
+	// Deleting the profile deletes its visits everywhere, so a location's admin
+	// may only delete visitors who have not been anywhere else
+	if user.LocationID != nil {
+		for _, visit := range database.DB.GetAllVisits(map[string]interface{}{"visitor_id": visitor.ID}) {
+			if !canAccessVisit(user, visit) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Visitor has visits at other locations; delete the visits here instead"})
+				return
+			}
+		}
+	}
+
+	if err := database.DB.DeleteVisitor(visitor.ID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Visitor not found"})
 		return
 	}
+	jobs.RemoveOrphanedBlobs(c.Request.Context(), imageStore)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Visitor deleted successfully"})
 }
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/middleware"
+	"digital-logbook/models"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateWatchlistEntryRequest struct {
+	IDNumber   string                   `json:"id_number"`
+	Name       string                   `json:"name"`
+	Reason     string                   `json:"reason" binding:"required"`
+	Severity   models.WatchlistSeverity `json:"severity" binding:"required"`
+	ExpiresAt  *time.Time               `json:"expires_at"`
+	LocationID *uint                    `json:"location_id"`
+}
+
+// WatchlistOverride lets a supervisor admit a visitor who matched a warning entry.
+// Admins may override on their own authority; other operators need an admin's credentials.
+type WatchlistOverride struct {
+	Reason             string `json:"reason"`
+	SupervisorUsername string `json:"supervisor_username"`
+	SupervisorPassword string `json:"supervisor_password"`
+}
+
+// validateWatchlistEntry checks the request and applies location scoping for the admin
+func validateWatchlistEntry(c *gin.Context, req *CreateWatchlistEntryRequest) bool {
+	if req.IDNumber == "" && req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An ID number or name is required"})
+		return false
+	}
+	if !req.Severity.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Severity must be block or warn"})
+		return false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return false
+	}
+	if user.LocationID != nil {
+		req.LocationID = user.LocationID
+	} else if req.LocationID != nil {
+		if _, err := database.DB.GetLocationByID(*req.LocationID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Location not found"})
+			return false
+		}
+	}
+	return true
+}
+
+// watchlistEntryFromParam loads the entry named in the URL if it covers the admin's
+// location. Entries for all locations can only be changed by super admins.
+func watchlistEntryFromParam(c *gin.Context, forUpdate bool) (*models.WatchlistEntry, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, false
+	}
+
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	entry, err := database.DB.GetWatchlistEntryByID(uint(id))
+	if err != nil || (user.LocationID != nil && !entry.AppliesTo(*user.LocationID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist entry not found"})
+		return nil, false
+	}
+	if forUpdate && user.LocationID != nil && entry.LocationID == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only super admins can change entries that apply to all locations"})
+		return nil, false
+	}
+	return entry, true
+}
+
+// ListWatchlist returns watchlist entries (admin only)
+func ListWatchlist(c *gin.Context) {
+	user, err := middleware.GetCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	filters := make(map[string]interface{})
+	if user.LocationID != nil {
+		filters["location_id"] = *user.LocationID
+	} else if locID := c.Query("location_id"); locID != "" {
+		if id, err := strconv.ParseUint(locID, 10, 32); err == nil {
+			filters["location_id"] = uint(id)
+		}
+	}
+	if c.Query("active") != "" {
+		filters["active"] = c.Query("active") == "true"
+	}
+
+	c.JSON(http.StatusOK, database.DB.GetAllWatchlistEntries(filters))
+}
+
+// GetWatchlistEntry returns a watchlist entry by ID (admin only)
+func GetWatchlistEntry(c *gin.Context) {
+	entry, ok := watchlistEntryFromParam(c, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// CreateWatchlistEntry adds a person to the watchlist (admin only)
+func CreateWatchlistEntry(c *gin.Context) {
+	var req CreateWatchlistEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateWatchlistEntry(c, &req) {
+		return
+	}
+
+	user, _ := middleware.GetCurrentUser(c)
+	entry := &models.WatchlistEntry{
+		IDNumber:   req.IDNumber,
+		Name:       req.Name,
+		Reason:     req.Reason,
+		Severity:   req.Severity,
+		ExpiresAt:  req.ExpiresAt,
+		LocationID: req.LocationID,
+		CreatedBy:  user.ID,
+	}
+
+	if err := database.DB.CreateWatchlistEntry(entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create watchlist entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// UpdateWatchlistEntry updates a watchlist entry (admin only)
+func UpdateWatchlistEntry(c *gin.Context) {
+	entry, ok := watchlistEntryFromParam(c, true)
+	if !ok {
+		return
+	}
+
+	var req CreateWatchlistEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validateWatchlistEntry(c, &req) {
+		return
+	}
+
+	entry.IDNumber = req.IDNumber
+	entry.Name = req.Name
+	entry.Reason = req.Reason
+	entry.Severity = req.Severity
+	entry.ExpiresAt = req.ExpiresAt
+	entry.LocationID = req.LocationID
+	entry.Location = nil
+
+	if err := database.DB.UpdateWatchlistEntry(entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watchlist entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteWatchlistEntry removes a watchlist entry (admin only)
+func DeleteWatchlistEntry(c *gin.Context) {
+	entry, ok := watchlistEntryFromParam(c, true)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.DeleteWatchlistEntry(entry.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete watchlist entry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Watchlist entry deleted successfully"})
+}
+
+// Supervisor credentials given for overrides are limited per operator and
+// supervisor, so the override prompt cannot be used to guess an admin's
+// password, nor to lock a supervisor out for everyone else
+const (
+	maxOverrideFailures = 5
+	overrideLockout     = 15 * time.Minute
+)
+
+var (
+	errOverrideDenied = errors.New("override requires valid credentials of an admin for this location")
+	errOverrideLocked = errors.New("too many failed override attempts naming this supervisor; try again later")
+)
+
+// overrideFailures tracks failed supervisor checks by operator and supervisor
+type overrideFailures struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+var failedOverrides = &overrideFailures{failures: make(map[string][]time.Time)}
+
+// overrideKey identifies the operator asking and the supervisor named. Service
+// accounts share user ID 0, so operators are told apart by username.
+func overrideKey(operator *models.User, supervisor string) string {
+	return strings.ToLower(operator.Username) + "\x00" + strings.ToLower(supervisor)
+}
+
+// recent drops failures older than the lockout and returns those left
+func (f *overrideFailures) recent(key string, now time.Time) []time.Time {
+	var kept []time.Time
+	for _, at := range f.failures[key] {
+		if now.Sub(at) < overrideLockout {
+			kept = append(kept, at)
+		}
+	}
+	if len(kept) == 0 {
+		delete(f.failures, key)
+	} else {
+		f.failures[key] = kept
+	}
+	return kept
+}
+
+// locked reports whether the operator has failed too often with the
+// supervisor, and how long until the next attempt
+func (f *overrideFailures) locked(key string, now time.Time) (bool, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failures := f.recent(key, now)
+	if len(failures) < maxOverrideFailures {
+		return false, 0
+	}
+	return true, failures[len(failures)-maxOverrideFailures].Add(overrideLockout).Sub(now)
+}
+
+// record adds a failure, first dropping every pair whose failures have all
+// expired so the map does not grow with each name ever tried
+func (f *overrideFailures) record(key string, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for other := range f.failures {
+		f.recent(other, now)
+	}
+	f.failures[key] = append(f.failures[key], now)
+}
+
+func (f *overrideFailures) reset(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.failures, key)
+}
+
+// authorizeOverride returns the username of the supervisor approving the override.
+// The supervisor's password is checked without signing them in, so directory
+// accounts are never provisioned or refreshed by someone else's request.
+func authorizeOverride(c *gin.Context, user *models.User, override *WatchlistOverride, locationID uint) (string, error) {
+	if override.SupervisorUsername == "" {
+		// Admins (not service accounts) can approve on their own authority
+		if user.Role == models.RoleAdmin {
+			return user.Username, nil
+		}
+		return "", errOverrideDenied
+	}
+
+	key := overrideKey(user, override.SupervisorUsername)
+	if locked, wait := failedOverrides.locked(key, time.Now()); locked {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return "", errOverrideLocked
+	}
+
+	supervisor, err := authenticators.Verify(c.Request.Context(), override.SupervisorUsername, override.SupervisorPassword)
+	if err != nil || supervisor.Role != models.RoleAdmin ||
+		(supervisor.LocationID != nil && *supervisor.LocationID != locationID) {
+		failedOverrides.record(key, time.Now())
+		return "", errOverrideDenied
+	}
+	failedOverrides.reset(key)
+	return supervisor.Username, nil
+}
+
+// screenVisitor checks the visitor against the watchlist before sign-in. It
+// writes the response and returns false when sign-in must not proceed.
+func screenVisitor(c *gin.Context, user *models.User, visitorID uint, name, idNumber string, locationID uint, override *WatchlistOverride) bool {
+	if status, response := screenWatchlist(c, user, visitorID, name, idNumber, locationID, override); status != 0 {
+		c.JSON(status, response)
+		return false
+	}
+	return true
+}
+
+// screenWatchlist audits the watchlist check for a visitor. It returns a zero
+// status when sign-in may proceed, otherwise the response to send.
+func screenWatchlist(c *gin.Context, user *models.User, visitorID uint, name, idNumber string, locationID uint, override *WatchlistOverride) (int, gin.H) {
+	matches := database.DB.ScreenWatchlist(name, idNumber, locationID)
+	if len(matches) == 0 {
+		return 0, nil
+	}
+
+	blocked := false
+	entryIDs := make([]uint, 0, len(matches))
+	for _, match := range matches {
+		entryIDs = append(entryIDs, match.Entry.ID)
+		if match.Entry.Severity == models.WatchlistBlock {
+			blocked = true
+		}
+	}
+	details := map[string]interface{}{
+		"name":                name,
+		"id_number":           idNumber,
+		"watchlist_entry_ids": entryIDs,
+	}
+
+	if blocked {
+		recordAudit(c, models.AuditWatchlistBlocked, "visitor", visitorID, &locationID, details)
+		return http.StatusForbidden, gin.H{
+			"error":    "Entry denied: visitor is on the watchlist",
+			"severity": models.WatchlistBlock,
+			"matches":  matches,
+		}
+	}
+
+	if override == nil || override.Reason == "" {
+		recordAudit(c, models.AuditWatchlistWarned, "visitor", visitorID, &locationID, details)
+		return http.StatusConflict, gin.H{
+			"error":             "Visitor matches the watchlist; a supervisor override with a reason is required",
+			"severity":          models.WatchlistWarn,
+			"matches":           matches,
+			"override_required": true,
+		}
+	}
+
+	supervisor, err := authorizeOverride(c, user, override, locationID)
+	if err != nil {
+		details["override_reason"] = override.Reason
+		details["supervisor"] = override.SupervisorUsername
+		details["error"] = err.Error()
+		recordAudit(c, models.AuditWatchlistOverrideDenied, "visitor", visitorID, &locationID, details)
+		if errors.Is(err, errOverrideLocked) {
+			return http.StatusTooManyRequests, gin.H{"error": "Too many failed override attempts for this supervisor; try again later"}
+		}
+		return http.StatusForbidden, gin.H{"error": "Override requires valid credentials of an admin for this location"}
+	}
+
+	details["override_reason"] = override.Reason
+	details["supervisor"] = supervisor
+	recordAudit(c, models.AuditWatchlistOverride, "visitor", visitorID, &locationID, details)
+	return 0, nil
+}
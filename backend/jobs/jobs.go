@@ -0,0 +1,150 @@
+// Package jobs runs the periodic background checks: flagging visitors who
+// overstay their expected departure, lapsing pre-registrations nobody arrived
+// for, the end-of-day sweep of each location and purging old visits.
+package jobs
+
+import (
+	"context"
+	"digital-logbook/database"
+	"digital-logbook/models"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultInterval is how often the jobs run when JOB_INTERVAL_SECONDS is unset
+const DefaultInterval = time.Minute
+
+// IntervalFromEnv reads JOB_INTERVAL_SECONDS
+func IntervalFromEnv() time.Duration {
+	if v := os.Getenv("JOB_INTERVAL_SECONDS"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		log.Printf("Ignoring invalid JOB_INTERVAL_SECONDS %q", v)
+	}
+	return DefaultInterval
+}
+
+// Start runs the jobs every interval until ctx is cancelled
+func Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		RunOnce(time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				RunOnce(now)
+			}
+		}
+	}()
+}
+
+// departed is told about each visit a sweep signs out; nil when nobody listens
+var departed func(visit *models.Visit)
+
+// ConfigureDepartures sets the function told about each visit a sweep signs
+// out, so hosts hear about auto sign-outs as they do about any other
+func ConfigureDepartures(notify func(visit *models.Visit)) {
+	departed = notify
+}
+
+// RunOnce runs every job as of now
+func RunOnce(now time.Time) {
+	CheckOverdueVisits(now)
+	LapsePreregistrations(now)
+	RunEndOfDaySweeps(now)
+	PurgeExpiredVisits(now)
+}
+
+// LapsePreregistrations cancels pre-registrations whose arrival window has passed
+func LapsePreregistrations(now time.Time) int {
+	lapsed := database.DB.LapseExpectedVisits(now)
+	if len(lapsed) > 0 {
+		log.Printf("%d pre-registrations lapsed", len(lapsed))
+	}
+	return len(lapsed)
+}
+
+// CheckOverdueVisits raises an alert for each visitor newly past their expected departure
+func CheckOverdueVisits(now time.Time) int {
+	flagged := database.DB.MarkOverdueVisits(now)
+	for _, visit := range flagged {
+		name := fmt.Sprintf("Visitor %d", visit.VisitorID)
+		if visit.Visitor != nil {
+			name = visit.Visitor.Name
+		}
+		visitID := visit.ID
+		database.DB.CreateAlert(&models.Alert{
+			Type:       models.AlertOverdueVisit,
+			LocationID: visit.LocationID,
+			VisitID:    &visitID,
+			Message:    fmt.Sprintf("%s was expected to leave by %s", name, visit.ExpectedDeparture.In(locationTime(visit.LocationID)).Format("15:04")),
+		})
+	}
+	return len(flagged)
+}
+
+// RunEndOfDaySweeps sweeps every location whose end-of-day time has passed today
+func RunEndOfDaySweeps(now time.Time) {
+	for _, loc := range database.DB.GetAllLocations() {
+		due, ok := loc.EndOfDay(now)
+		if !ok || now.Before(due) {
+			continue
+		}
+		report, ran := SweepLocation(loc, models.SweepScheduled, nil, now)
+		if ran {
+			log.Printf("End-of-day sweep of %s: %d visits auto signed out, %d badges unreturned",
+				loc.Code, len(report.SignedOutVisits), len(report.UnreturnedBadges))
+		}
+	}
+}
+
+// SweepLocation auto signs out the visitors still at a location, tells their
+// hosts and raises an alert for each badge they did not hand back. It returns false without doing
+// anything if a scheduled sweep already ran for the location today.
+func SweepLocation(loc *models.Location, trigger models.SweepTrigger, triggeredBy *uint, now time.Time) (*models.SweepReport, bool) {
+	report, ran := database.DB.SweepLocationVisits(&models.SweepReport{
+		LocationID:  loc.ID,
+		Date:        now.In(loc.TimeLocation()).Format("2006-01-02"),
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+	}, now)
+	if !ran {
+		return report, false
+	}
+
+	for _, badge := range report.UnreturnedBadges {
+		badgeID, visitID := badge.BadgeID, badge.VisitID
+		database.DB.CreateAlert(&models.Alert{
+			Type:       models.AlertUnreturnedBadge,
+			LocationID: loc.ID,
+			VisitID:    &visitID,
+			BadgeID:    &badgeID,
+			Message:    fmt.Sprintf("Badge %s was not returned by %s", badge.Number, badge.VisitorName),
+		})
+	}
+
+	if departed != nil {
+		for _, visitID := range report.SignedOutVisits {
+			if visit, err := database.DB.GetVisitByID(visitID); err == nil {
+				departed(visit)
+			}
+		}
+	}
+	return report, true
+}
+
+// locationTime returns the time zone of a location for formatting messages
+func locationTime(locationID uint) *time.Location {
+	if loc, err := database.DB.GetLocationByID(locationID); err == nil {
+		return loc.TimeLocation()
+	}
+	return time.Local
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"digital-logbook/database"
+	"digital-logbook/storage"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	retentionDays int               // Zero keeps visits forever
+	blobStore     storage.BlobStore // Holds visit images; nil when uploads are off
+)
+
+// RetentionFromEnv reads VISIT_RETENTION_DAYS. Zero, the default, keeps visits forever.
+func RetentionFromEnv() int {
+	if v := os.Getenv("VISIT_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return days
+		}
+		log.Printf("Ignoring invalid VISIT_RETENTION_DAYS %q", v)
+	}
+	return 0
+}
+
+// ConfigureRetention sets how long ended visits are kept and the store their images are in
+func ConfigureRetention(days int, store storage.BlobStore) {
+	retentionDays = days
+	blobStore = store
+}
+
+// PurgeExpiredVisits deletes visits that ended more than the retention period
+// ago, along with their images
+func PurgeExpiredVisits(now time.Time) int {
+	purged := 0
+	if retentionDays > 0 {
+		purged = database.DB.PurgeVisits(now.AddDate(0, 0, -retentionDays))
+		if purged > 0 {
+			log.Printf("Retention: purged %d visits older than %d days", purged, retentionDays)
+		}
+	}
+	RemoveOrphanedBlobs(context.Background(), blobStore)
+	return purged
+}
+
+// RemoveOrphanedBlobs deletes the files of images whose records are gone.
+// Files that cannot be deleted are retried on the next run.
+func RemoveOrphanedBlobs(ctx context.Context, store storage.BlobStore) int {
+	if store == nil {
+		return 0
+	}
+	keys := database.DB.TakeOrphanedBlobs()
+	var failed []string
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+			failed = append(failed, key)
+		}
+	}
+	if len(failed) > 0 {
+		database.DB.RequeueOrphanedBlobs(failed)
+	}
+	return len(keys) - len(failed)
+}
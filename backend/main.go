@@ -1,9 +1,18 @@
 package main
 
 import (
+	"context"
+	"digital-logbook/auth"
+	"digital-logbook/badgeprint"
 	"digital-logbook/database"
+	"digital-logbook/handlers"
+	"digital-logbook/jobs"
+	"digital-logbook/models"
+	"digital-logbook/notify"
 	"digital-logbook/routes"
+	"digital-logbook/storage"
 	"log"
+	"os"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -15,13 +24,54 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Password logins go through the directory first when LDAP is configured
+	chain := auth.NewChainFromEnv()
+	handlers.ConfigureAuthenticators(chain)
+	for _, authenticator := range chain {
+		log.Printf("Password authenticator enabled: %s", authenticator.Name())
+	}
+
+	// Enable single sign-on when an identity provider is configured
+	if cfg, ok := auth.LoadOIDCConfigFromEnv(); ok {
+		handlers.ConfigureOIDC(auth.NewOIDCProvider(cfg))
+		log.Printf("OpenID Connect single sign-on enabled (issuer: %s)", cfg.IssuerURL)
+	}
+
+	// Users created by the SCIM provisioning client sign in through this provider
+	if provider := os.Getenv("SCIM_USER_AUTH_PROVIDER"); provider != "" {
+		handlers.ConfigureSCIM(models.AuthProvider(provider))
+	}
+
+	// Tell hosts when their visitors arrive and leave
+	if notifiers := notify.NotifiersFromEnv(); len(notifiers) > 0 {
+		handlers.ConfigureNotifier(notify.NewDispatcher(notifiers...))
+		for _, n := range notifiers {
+			log.Printf("Host notifications enabled: %s", n.Name())
+		}
+	}
+
+	// Printed badges carry QR codes signed with this key
+	handlers.ConfigureBadgeSigner(badgeprint.SignerFromEnv())
+
+	// Visitor photos and ID scans are kept in the blob store
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Printf("Image uploads disabled: %v", err)
+	} else {
+		handlers.ConfigureImageStore(store)
+	}
+	jobs.ConfigureRetention(jobs.RetentionFromEnv(), store)
+
+	// Flag overstaying visitors, run the end-of-day sweeps and purge old visits in the background
+	jobs.Start(context.Background(), jobs.IntervalFromEnv())
+
 	// Create Gin router
 	router := gin.Default()
 
 	// CORS configuration
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:3001", "http://localhost:5173", "http://localhost:5174", "http://localhost:5175", "http://10.32.10.153:3001", "http://10.32.10.153:3000"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key", "X-Location-ID"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	router.Use(cors.New(config))
 
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"digital-logbook/database"
+	"digital-logbook/models"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// APIKeyHeader is the header service accounts use to present their key
+	APIKeyHeader = "X-API-Key"
+	// LocationHeader selects the acting location for keys bound to several locations
+	LocationHeader = "X-Location-ID"
+
+	apiKeyPrefix = "dlk"
+)
+
+// apiKeyRoutePermissions maps the routes reachable with an API key to the
+// permission they require. Routes not listed here reject API keys outright.
+var apiKeyRoutePermissions = map[string]models.Permission{
+	"GET /api/visitors":                     models.PermVisitorsRead,
+	"GET /api/visitors/:id":                 models.PermVisitorsRead,
+	"GET /api/visitors/lookup":              models.PermVisitorsRead,
+	"POST /api/visitors/mrz":                models.PermVisitorsWrite,
+	"POST /api/visitors":                    models.PermVisitorsWrite,
+	"POST /api/visitors/:id/signin":         models.PermVisitorsSignInOut,
+	"POST /api/visitors/:id/signout":        models.PermVisitorsSignInOut,
+	"POST /api/visitors/signout-by-badge":   models.PermVisitorsSignInOut,
+	"POST /api/visitors/signout-by-qr":      models.PermVisitorsSignInOut,
+	"GET /api/visitors/:id/visits":          models.PermVisitorsRead,
+	"GET /api/visits":                       models.PermVisitorsRead,
+	"GET /api/visits/report":                models.PermVisitorsRead,
+	"GET /api/visits/:id":                   models.PermVisitorsRead,
+	"GET /api/visits/:id/history":           models.PermVisitorsRead,
+	"GET /api/visits/:id/badge":             models.PermVisitorsRead,
+	"POST /api/visits/:id/signout":          models.PermVisitorsSignInOut,
+	"POST /api/visits/:id/confirm":          models.PermVisitorsSignInOut,
+	"POST /api/visits/:id/deny":             models.PermVisitorsSignInOut,
+	"GET /api/items":                        models.PermVisitorsRead,
+	"GET /api/items/outstanding":            models.PermVisitorsRead,
+	"GET /api/hosts":                        models.PermVisitorsRead,
+	"GET /api/hosts/:id":                    models.PermVisitorsRead,
+	"GET /api/ack-documents":                models.PermVisitorsRead,
+	"GET /api/ack-documents/required":       models.PermVisitorsRead,
+	"GET /api/ack-documents/:id":            models.PermVisitorsRead,
+	"GET /api/ack-documents/:id/versions":   models.PermVisitorsRead,
+	"GET /api/groups":                       models.PermVisitorsRead,
+	"GET /api/groups/:id":                   models.PermVisitorsRead,
+	"POST /api/groups":                      models.PermVisitorsWrite,
+	"POST /api/groups/:id/signout":          models.PermVisitorsSignInOut,
+	"GET /api/preregistrations":             models.PermVisitorsRead,
+	"POST /api/preregistrations":            models.PermVisitorsWrite,
+	"POST /api/preregistrations/:id/arrive": models.PermVisitorsSignInOut,
+	"POST /api/preregistrations/:id/deny":   models.PermVisitorsSignInOut,
+	"GET /api/badges":                       models.PermVisitorsRead,
+	"GET /api/badges/outstanding":           models.PermVisitorsRead,
+	"GET /api/badges/:id":                   models.PermVisitorsRead,
+	"GET /api/visits/:id/escorts":           models.PermVisitorsRead,
+	"GET /api/escorts":                      models.PermVisitorsRead,
+	"GET /api/areas":                        models.PermVisitorsRead,
+	"GET /api/areas/occupancy":              models.PermVisitorsRead,
+	"GET /api/areas/:id":                    models.PermVisitorsRead,
+	"GET /api/parking-bays":                 models.PermVisitorsRead,
+	"GET /api/parking-bays/occupancy":       models.PermVisitorsRead,
+	"GET /api/parking-bays/:id":             models.PermVisitorsRead,
+	"GET /api/alerts":                       models.PermVisitorsRead,
+	"GET /api/sweeps":                       models.PermVisitorsRead,
+	"GET /api/sweeps/:id":                   models.PermVisitorsRead,
+	"GET /api/locations/:id/muster":         models.PermVisitorsRead,
+	"GET /api/locations/:id/muster/export":  models.PermVisitorsRead,
+	"GET /api/locations/:id/drills":         models.PermVisitorsRead,
+	"GET /api/cargo":                        models.PermCargoRead,
+	"GET /api/cargo/:id":                    models.PermCargoRead,
+	"POST /api/cargo":                       models.PermCargoWrite,
+	"POST /api/cargo/:id/release":           models.PermCargoWrite,
+	"GET /api/fitness/members":              models.PermFitnessRead,
+	"GET /api/fitness/members/:id":          models.PermFitnessRead,
+	"GET /api/fitness/attendance":           models.PermFitnessRead,
+	"GET /api/fitness/attendance/:id":       models.PermFitnessRead,
+	"POST /api/fitness/checkin":             models.PermFitnessCheckIn,
+	"POST /api/fitness/checkout":            models.PermFitnessCheckIn,
+}
+
+// APIKeyRoutePermission returns the permission an API key needs for a route,
+// given as its method and registered path. ok is false for routes that reject API keys.
+func APIKeyRoutePermission(method, path string) (perm models.Permission, ok bool) {
+	perm, ok = apiKeyRoutePermissions[method+" "+path]
+	return perm, ok
+}
+
+// GenerateAPIKey creates a new random key and returns the plaintext key,
+// its public prefix and the hash to store
+func GenerateAPIKey() (plaintext, prefix, hash string, err error) {
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 24)
+	if _, err = rand.Read(prefixBytes); err != nil {
+		return "", "", "", err
+	}
+	if _, err = rand.Read(secretBytes); err != nil {
+		return "", "", "", err
+	}
+
+	prefix = apiKeyPrefix + "_" + hex.EncodeToString(prefixBytes)
+	plaintext = prefix + "_" + hex.EncodeToString(secretBytes)
+	return plaintext, prefix, HashAPIKey(plaintext), nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash of a plaintext key
+func HashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAPIKeyPrefix extracts the public prefix from a plaintext key
+func parseAPIKeyPrefix(plaintext string) (string, error) {
+	parts := strings.Split(plaintext, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return "", errors.New("malformed api key")
+	}
+	return parts[0] + "_" + parts[1], nil
+}
+
+// lookupAPIKey resolves a plaintext key to an active key and its service account
+func lookupAPIKey(plaintext string) (*models.APIKey, *models.ServiceAccount, error) {
+	prefix, err := parseAPIKeyPrefix(plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := database.DB.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(HashAPIKey(plaintext))) != 1 {
+		return nil, nil, errors.New("api key mismatch")
+	}
+	if !key.IsActive() {
+		return nil, nil, errors.New("api key revoked or expired")
+	}
+
+	account, err := database.DB.GetServiceAccountByID(key.ServiceAccountID)
+	if err != nil || account.Disabled {
+		return nil, nil, errors.New("service account disabled")
+	}
+	return key, account, nil
+}
+
+// authenticateAPIKey validates an API key for the current route and attaches
+// a service principal to the context
+func authenticateAPIKey(c *gin.Context, plaintext string) {
+	key, account, err := lookupAPIKey(plaintext)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+		c.Abort()
+		return
+	}
+
+	perm, allowed := APIKeyRoutePermission(c.Request.Method, c.FullPath())
+	if !allowed || !account.HasPermission(perm) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks permission for this endpoint"})
+		c.Abort()
+		return
+	}
+
+	// Resolve the location the key is acting for
+	var locationID *uint
+	if header := c.GetHeader(LocationHeader); header != "" {
+		id, err := strconv.ParseUint(header, 10, 32)
+		if err != nil || !account.CanAccessLocation(uint(id)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key not permitted for this location"})
+			c.Abort()
+			return
+		}
+		loc := uint(id)
+		locationID = &loc
+	} else if len(account.LocationIDs) == 1 {
+		loc := account.LocationIDs[0]
+		locationID = &loc
+	} else if len(account.LocationIDs) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": LocationHeader + " header required for multi-location API keys"})
+		c.Abort()
+		return
+	}
+
+	database.DB.TouchAPIKey(key.ID, c.ClientIP())
+
+	// Handlers work with a user, so present the service account as one
+	principal := &models.User{
+		Username:   "svc:" + account.Name,
+		Role:       models.RoleServiceAccount,
+		FullName:   account.Name,
+		LocationID: locationID,
+	}
+
+	c.Set("user", principal)
+	c.Set("service_account", account)
+	c.Set("api_key", key)
+	c.Next()
+}
+
+// GetServiceAccount retrieves the service account from the context when the
+// request was authenticated with an API key
+func GetServiceAccount(c *gin.Context) (*models.ServiceAccount, bool) {
+	accountInterface, exists := c.Get("service_account")
+	if !exists {
+		return nil, false
+	}
+	return accountInterface.(*models.ServiceAccount), true
+}
@@ -4,6 +4,7 @@ import (
 	"digital-logbook/database"
 	"digital-logbook/models"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -19,9 +20,15 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// AuthMiddleware validates JWT tokens and attaches user to context
+// AuthMiddleware validates JWT tokens or service account API keys and attaches user to context
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Service accounts authenticate with an API key instead of a JWT
+		if apiKey := c.GetHeader(APIKeyHeader); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
@@ -57,6 +64,21 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Check that the session behind the token has not been revoked
+		sessionID, err := strconv.ParseUint(claims.ID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.Abort()
+			return
+		}
+
+		session, err := database.DB.GetSessionByID(uint(sessionID))
+		if err != nil || session.UserID != claims.UserID || !session.IsActive() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked or expired"})
+			c.Abort()
+			return
+		}
+
 		// Fetch user from database
 		user, err := database.DB.GetUserByID(claims.UserID)
 		if err != nil {
@@ -64,13 +86,30 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if user.Disabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account disabled"})
+			c.Abort()
+			return
+		}
 
-		// Attach user to context
+		database.DB.TouchSession(session.ID, c.ClientIP())
+
+		// Attach user and session to context
 		c.Set("user", user)
+		c.Set("session", session)
 		c.Next()
 	}
 }
 
+// GetCurrentSession retrieves the session of a JWT authenticated request
+func GetCurrentSession(c *gin.Context) (*models.Session, bool) {
+	sessionInterface, exists := c.Get("session")
+	if !exists {
+		return nil, false
+	}
+	return sessionInterface.(*models.Session), true
+}
+
 // GetCurrentUser retrieves the user from the context
 func GetCurrentUser(c *gin.Context) (*models.User, error) {
 	userInterface, exists := c.Get("user")
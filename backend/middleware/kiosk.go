@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/models"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultKioskRateLimit is how many requests a kiosk may make a minute when KIOSK_RATE_LIMIT is unset
+const DefaultKioskRateLimit = 30
+
+// KioskRateLimitFromEnv reads KIOSK_RATE_LIMIT, in requests per minute
+func KioskRateLimitFromEnv() int {
+	if v := os.Getenv("KIOSK_RATE_LIMIT"); v != "" {
+		if limit, err := strconv.Atoi(v); err == nil && limit > 0 {
+			return limit
+		}
+		log.Printf("Ignoring invalid KIOSK_RATE_LIMIT %q", v)
+	}
+	return DefaultKioskRateLimit
+}
+
+// rateLimiter keeps a token bucket per key: each key may make up to limit
+// requests at once and regains them evenly over the period
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	period  time.Duration
+	buckets map[uint]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(limit int, period time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   float64(limit),
+		period:  period,
+		buckets: make(map[uint]*tokenBucket),
+	}
+}
+
+// allow takes a request from the key's bucket, or returns how long to wait
+// until one is available
+func (l *rateLimiter) allow(key uint, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &tokenBucket{tokens: l.limit, updated: now}
+		l.buckets[key] = b
+	}
+	perToken := l.period / time.Duration(l.limit)
+	b.tokens = math.Min(l.limit, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+	b.updated = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// KioskAuth authenticates a self-service kiosk by its API key, sent in the
+// X-API-Key header. The key's service account must hold the kiosk permission
+// and be bound to exactly one location, which the kiosk acts for. Each key is
+// limited to KIOSK_RATE_LIMIT requests a minute.
+func KioskAuth() gin.HandlerFunc {
+	limiter := newRateLimiter(KioskRateLimitFromEnv(), time.Minute)
+
+	return func(c *gin.Context) {
+		key, account, err := lookupAPIKey(c.GetHeader(APIKeyHeader))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked kiosk key"})
+			c.Abort()
+			return
+		}
+		if !account.IsKiosk() || len(account.LocationIDs) != 1 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is not a kiosk key bound to one location"})
+			c.Abort()
+			return
+		}
+		if ok, wait := limiter.allow(key.ID, time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests from this kiosk; try again shortly"})
+			c.Abort()
+			return
+		}
+
+		database.DB.TouchAPIKey(key.ID, c.ClientIP())
+
+		// Kiosk handlers share helpers with the operator endpoints, which work with a user
+		locationID := account.LocationIDs[0]
+		principal := &models.User{
+			Username:   "kiosk:" + account.Name,
+			Role:       models.RoleServiceAccount,
+			FullName:   account.Name,
+			LocationID: &locationID,
+		}
+
+		c.Set("user", principal)
+		c.Set("service_account", account)
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
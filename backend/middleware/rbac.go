@@ -17,6 +17,12 @@ func RequireRole(roles ...models.UserRole) gin.HandlerFunc {
 			return
 		}
 
+		// API key permissions are enforced per route by AuthMiddleware
+		if _, ok := GetServiceAccount(c); ok {
+			c.Next()
+			return
+		}
+
 		// Check if user has any of the required roles
 		hasRole := false
 		for _, role := range roles {
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"digital-logbook/database"
+	"digital-logbook/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMErrorSchema identifies SCIM error responses (RFC 7644 section 3.12)
+const SCIMErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+// SCIMError writes an error in the format SCIM clients expect
+func SCIMError(c *gin.Context, status int, scimType, detail string) {
+	body := gin.H{
+		"schemas": []string{SCIMErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
+
+// SCIMAuth accepts a service account API key sent as a bearer token, which is
+// how provisioning clients present their credentials
+func SCIMAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			SCIMError(c, http.StatusUnauthorized, "", "Bearer token required")
+			c.Abort()
+			return
+		}
+
+		key, account, err := lookupAPIKey(strings.TrimSpace(parts[1]))
+		if err != nil {
+			SCIMError(c, http.StatusUnauthorized, "", "Invalid or revoked token")
+			c.Abort()
+			return
+		}
+		if !account.HasPermission(models.PermSCIMProvision) {
+			SCIMError(c, http.StatusForbidden, "", "Token lacks the scim:provision permission")
+			c.Abort()
+			return
+		}
+
+		database.DB.TouchAPIKey(key.ID, c.ClientIP())
+
+		c.Set("service_account", account)
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AckDocumentKind is the kind of text a visitor acknowledges
+type AckDocumentKind string
+
+const (
+	AckSafetyInduction AckDocumentKind = "safety_induction"
+	AckNDA             AckDocumentKind = "nda"
+)
+
+// IsValid returns true if the kind is known
+func (k AckDocumentKind) IsValid() bool {
+	return k == AckSafetyInduction || k == AckNDA
+}
+
+// AckDocument is a text visitors must accept before signing in at a location,
+// or only before visiting one of its areas. Changing the title or body
+// publishes a new version, which every visitor has to accept again.
+type AckDocument struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	Kind        AckDocumentKind `gorm:"not null" json:"kind"`
+	Title       string          `gorm:"not null" json:"title"`
+	Body        string          `gorm:"not null" json:"body"` // Text of the current version
+	Version     int             `gorm:"not null" json:"version"`
+	LocationID  uint            `gorm:"not null;index" json:"location_id"`
+	Location    *Location       `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	AreaOfVisit string          `json:"area_of_visit,omitempty"` // Empty applies to every area
+	// SignatureRequired asks for a drawn signature with the acceptance
+	SignatureRequired bool `json:"signature_required"`
+	// ValidDays limits how long an acceptance lasts; 0 keeps it until the next version
+	ValidDays int       `json:"valid_days"`
+	Active    bool      `gorm:"default:true" json:"active"` // Retired documents are kept for audits
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AppliesTo returns true if visitors to the area at the location must accept the document
+func (d *AckDocument) AppliesTo(locationID uint, area string) bool {
+	if d.LocationID != locationID {
+		return false
+	}
+	return d.AreaOfVisit == "" || strings.EqualFold(strings.TrimSpace(d.AreaOfVisit), strings.TrimSpace(area))
+}
+
+// AckDocumentVersion keeps the text of each published version for audits
+type AckDocumentVersion struct {
+	DocumentID  uint      `gorm:"primaryKey" json:"document_id"`
+	Version     int       `gorm:"primaryKey" json:"version"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	PublishedBy uint      `json:"published_by"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Acknowledgement records a visitor accepting a version of a document
+type Acknowledgement struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	DocumentID    uint         `gorm:"not null;index" json:"document_id"`
+	Document      *AckDocument `gorm:"foreignKey:DocumentID" json:"document,omitempty"`
+	Version       int          `gorm:"not null" json:"version"`
+	VisitorID     uint         `gorm:"not null;index" json:"visitor_id"`
+	VisitorName   string       `gorm:"-" json:"visitor_name,omitempty"`
+	VisitID       *uint        `json:"visit_id,omitempty"` // The visit signed in with it; kept after the visit is purged
+	LocationID    uint         `gorm:"not null" json:"location_id"`
+	AcceptedAt    time.Time    `gorm:"not null" json:"accepted_at"`
+	SignatureKey  string       `json:"-"` // Blob store key of the signature image
+	SignatureType string       `json:"-"`
+	HasSignature  bool         `gorm:"-" json:"has_signature"`
+	RecordedBy    *uint        `json:"recorded_by,omitempty"` // The operator who captured it; nil for service accounts
+}
+
+// Covers returns true if the acknowledgement still satisfies the document's current version
+func (a *Acknowledgement) Covers(doc *AckDocument, now time.Time) bool {
+	if a.DocumentID != doc.ID || a.Version != doc.Version {
+		return false
+	}
+	return doc.ValidDays == 0 || now.Before(a.AcceptedAt.AddDate(0, 0, doc.ValidDays))
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// AlertType identifies what an alert is about
+type AlertType string
+
+const (
+	AlertOverdueVisit    AlertType = "overdue_visit"
+	AlertUnreturnedBadge AlertType = "unreturned_badge"
+)
+
+// Alert is raised by background checks for operators to follow up
+type Alert struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Type           AlertType  `gorm:"not null;index" json:"type"`
+	LocationID     uint       `gorm:"not null" json:"location_id"`
+	VisitID        *uint      `json:"visit_id,omitempty"`
+	BadgeID        *uint      `json:"badge_id,omitempty"`
+	Message        string     `json:"message"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy *uint      `json:"acknowledged_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// SweepTrigger records whether a sweep ran on schedule or was started by an admin
+type SweepTrigger string
+
+const (
+	SweepScheduled SweepTrigger = "scheduled"
+	SweepManual    SweepTrigger = "manual"
+)
+
+// SweepReport records one end-of-day sweep of a location
+type SweepReport struct {
+	ID               uint              `gorm:"primaryKey" json:"id"`
+	LocationID       uint              `gorm:"not null;index" json:"location_id"`
+	Date             string            `gorm:"not null" json:"date"` // Local date swept, 2006-01-02
+	Trigger          SweepTrigger      `json:"trigger"`
+	TriggeredBy      *uint             `json:"triggered_by,omitempty"` // User who started a manual sweep
+	RanAt            time.Time         `json:"ran_at"`
+	SignedOutVisits  []uint            `gorm:"serializer:json" json:"signed_out_visits"`
+	UnreturnedBadges []UnreturnedBadge `gorm:"serializer:json" json:"unreturned_badges"`
+}
+
+// UnreturnedBadge lists a badge still out when its visit was auto signed out
+type UnreturnedBadge struct {
+	BadgeID     uint   `json:"badge_id"`
+	Number      string `json:"number"`
+	VisitID     uint   `json:"visit_id"`
+	VisitorID   uint   `json:"visitor_id"`
+	VisitorName string `json:"visitor_name"`
+}
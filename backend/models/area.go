@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// AreaAccessLevel grades how closely access to an area is controlled
+type AreaAccessLevel string
+
+const (
+	AccessPublic     AreaAccessLevel = "public"     // Receptions, meeting rooms; any badge
+	AccessRestricted AreaAccessLevel = "restricted" // Badge must open the area's zone
+	AccessSecure     AreaAccessLevel = "secure"     // As restricted; server rooms, airside
+)
+
+// IsValid returns true if the access level is known
+func (l AreaAccessLevel) IsValid() bool {
+	switch l {
+	case AccessPublic, AccessRestricted, AccessSecure:
+		return true
+	}
+	return false
+}
+
+// Area is a part of a location visitors can be signed in to
+type Area struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	LocationID     uint            `gorm:"not null;uniqueIndex:idx_area_name" json:"location_id"`
+	Location       *Location       `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	Name           string          `gorm:"not null;uniqueIndex:idx_area_name" json:"name"`
+	AccessZone     string          `json:"access_zone"` // Matched against the badge's access zone
+	AccessLevel    AreaAccessLevel `gorm:"not null;default:'public'" json:"access_level"`
+	BadgeTypes     []string        `gorm:"serializer:json" json:"badge_types"` // Badge types allowed in; empty allows any
+	EscortRequired bool            `json:"escort_required"`
+	MaxOccupancy   int             `json:"max_occupancy"`                       // Visitors on site at once; 0 is unlimited
+	Active         bool            `gorm:"not null;default:true" json:"active"` // Inactive areas stay on past visits but cannot be picked
+	OnSite         int             `gorm:"-" json:"on_site"`                    // Visitors signed in to the area now
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Admits returns true if the badge may be issued for a visit to the area: its
+// type must be allowed and, above the public level, it must open the area's zone
+func (a *Area) Admits(badge *Badge) bool {
+	if len(a.BadgeTypes) > 0 {
+		allowed := false
+		for _, t := range a.BadgeTypes {
+			if strings.EqualFold(t, badge.Type) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if a.AccessLevel == AccessPublic || a.AccessZone == "" {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(badge.AccessZone), a.AccessZone)
+}
+
+// IsFull returns true if another visitor would exceed the area's maximum occupancy
+func (a *Area) IsFull(onSite int) bool {
+	return a.MaxOccupancy > 0 && onSite >= a.MaxOccupancy
+}
+
+// AreaOccupancy is how many visitors are signed in to an area
+type AreaOccupancy struct {
+	AreaID       uint            `json:"area_id"`
+	Name         string          `json:"name"`
+	LocationID   uint            `json:"location_id"`
+	AccessLevel  AreaAccessLevel `json:"access_level"`
+	OnSite       int             `json:"on_site"`
+	MaxOccupancy int             `json:"max_occupancy"`
+	Available    *int            `json:"available,omitempty"` // Places left; omitted when unlimited
+	Full         bool            `json:"full"`
+}
+
+// SameAreaName compares area names ignoring case and surrounding spaces
+func SameAreaName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
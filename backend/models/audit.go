@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// AuditAction identifies a security-relevant event
+type AuditAction string
+
+const (
+	AuditWatchlistBlocked        AuditAction = "watchlist.blocked"
+	AuditWatchlistWarned         AuditAction = "watchlist.warned"
+	AuditWatchlistOverride       AuditAction = "watchlist.override"
+	AuditWatchlistOverrideDenied AuditAction = "watchlist.override_denied"
+	AuditIDDocumentViewed        AuditAction = "id_document.viewed"
+	AuditAckDocumentPublished    AuditAction = "ack_document.published"
+)
+
+// AuditLog records who did what, where and when
+type AuditLog struct {
+	ID         uint                   `gorm:"primaryKey" json:"id"`
+	Action     AuditAction            `gorm:"not null;index" json:"action"`
+	ActorID    *uint                  `json:"actor_id,omitempty"` // Nil for service accounts
+	ActorName  string                 `json:"actor_name"`
+	EntityType string                 `json:"entity_type"`
+	EntityID   uint                   `json:"entity_id,omitempty"`
+	LocationID *uint                  `json:"location_id,omitempty"`
+	Details    map[string]interface{} `gorm:"serializer:json" json:"details,omitempty"`
+	IPAddress  string                 `json:"ip_address"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// BadgeStatus represents where a badge is in its lifecycle
+type BadgeStatus string
+
+const (
+	BadgeAvailable BadgeStatus = "available"
+	BadgeIssued    BadgeStatus = "issued"
+	BadgeLost      BadgeStatus = "lost"
+	BadgeRetired   BadgeStatus = "retired"
+	// BadgeUnreturned is a badge still out when its visit was auto signed out
+	BadgeUnreturned BadgeStatus = "unreturned"
+)
+
+// IsValid returns true if the status is known
+func (s BadgeStatus) IsValid() bool {
+	switch s {
+	case BadgeAvailable, BadgeIssued, BadgeLost, BadgeRetired, BadgeUnreturned:
+		return true
+	}
+	return false
+}
+
+// Badge is a physical visitor badge held in a location's inventory
+type Badge struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	LocationID uint        `gorm:"not null;uniqueIndex:idx_badge_number" json:"location_id"`
+	Location   *Location   `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	Number     string      `gorm:"not null;uniqueIndex:idx_badge_number" json:"number"`
+	Type       string      `json:"type"`        // e.g. visitor, contractor, escort
+	AccessZone string      `json:"access_zone"` // Zone the badge opens, e.g. Terminal A
+	Status     BadgeStatus `gorm:"not null;default:'available'" json:"status"`
+	VisitID    *uint       `json:"visit_id,omitempty"` // Visit holding the badge, kept when lost
+	Visit      *Visit      `gorm:"foreignKey:VisitID" json:"visit,omitempty"`
+	IssuedAt   *time.Time  `json:"issued_at,omitempty"`
+	LostAt     *time.Time  `json:"lost_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// NormalizeBadgeNumber canonicalises a badge number for lookups
+func NormalizeBadgeNumber(number string) string {
+	return strings.ToUpper(strings.TrimSpace(number))
+}
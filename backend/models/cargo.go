@@ -26,6 +26,12 @@ type Cargo struct {
 	LocationID           uint          `gorm:"not null" json:"location_id"`
 	Location             *Location     `gorm:"foreignKey:LocationID" json:"location,omitempty"`
 	TimeIn               time.Time     `gorm:"not null" json:"time_in"`
+	TimeOut              *time.Time    `json:"time_out,omitempty"` // When the cargo was released and the driver left
 	CreatedAt            time.Time     `json:"created_at"`
 	UpdatedAt            time.Time     `json:"updated_at"`
 }
+
+// IsReleased returns true once the cargo has been released and the driver has left
+func (c *Cargo) IsReleased() bool {
+	return c.TimeOut != nil
+}
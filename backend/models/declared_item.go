@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ItemType is the kind of equipment a visitor brings on site
+type ItemType string
+
+const (
+	ItemLaptop       ItemType = "laptop"
+	ItemPhone        ItemType = "phone"
+	ItemTablet       ItemType = "tablet"
+	ItemCamera       ItemType = "camera"
+	ItemStorageMedia ItemType = "storage_media" // USB drives, external disks
+	ItemTool         ItemType = "tool"
+	ItemOther        ItemType = "other"
+)
+
+// IsValid returns true if the item type is known
+func (t ItemType) IsValid() bool {
+	switch t {
+	case ItemLaptop, ItemPhone, ItemTablet, ItemCamera, ItemStorageMedia, ItemTool, ItemOther:
+		return true
+	}
+	return false
+}
+
+// ItemStatus tracks a declared item from the entrance to the exit
+type ItemStatus string
+
+const (
+	ItemDeclared       ItemStatus = "declared"        // Brought in and not checked at the exit yet
+	ItemOut            ItemStatus = "out"             // Left with the visitor
+	ItemMissing        ItemStatus = "missing"         // Not with the visitor at the exit
+	ItemSerialMismatch ItemStatus = "serial_mismatch" // Left with a different serial number
+	ItemUndeclared     ItemStatus = "undeclared"      // Carried out without being declared at sign-in
+)
+
+// IsMismatch returns true if the status means the items leaving differ from those declared
+func (s ItemStatus) IsMismatch() bool {
+	return s == ItemMissing || s == ItemSerialMismatch || s == ItemUndeclared
+}
+
+// DeclaredItem is a laptop, tool or other equipment a visitor declares at sign-in
+type DeclaredItem struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	VisitID      uint       `gorm:"not null;index" json:"visit_id"`
+	Visit        *Visit     `gorm:"foreignKey:VisitID" json:"visit,omitempty"`
+	LocationID   uint       `gorm:"not null;index" json:"location_id"`
+	Type         ItemType   `gorm:"not null" json:"type"`
+	Description  string     `gorm:"not null" json:"description"`
+	SerialNumber string     `gorm:"index" json:"serial_number,omitempty"`
+	Status       ItemStatus `gorm:"not null;default:'declared'" json:"status"`
+	// Exit check: the serial number read off the item, a note and who checked it
+	ExitSerialNumber string     `json:"exit_serial_number,omitempty"`
+	ExitNote         string     `json:"exit_note,omitempty"`
+	CheckedAt        *time.Time `json:"checked_at,omitempty"`
+	CheckedBy        *uint      `json:"checked_by,omitempty"` // Nil for service accounts
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// sameSerial compares serial numbers ignoring case, spaces and dashes
+func sameSerial(a, b string) bool {
+	return NormalizeIDNumber(a) == NormalizeIDNumber(b)
+}
+
+// Check records the item at the exit: whether it is leaving and, optionally,
+// the serial number read off it
+func (i *DeclaredItem) Check(present bool, serialNumber, note string, by *uint, at time.Time) {
+	serialNumber = strings.TrimSpace(serialNumber)
+	switch {
+	case !present:
+		i.Status = ItemMissing
+	case serialNumber != "" && i.SerialNumber != "" && !sameSerial(serialNumber, i.SerialNumber):
+		i.Status = ItemSerialMismatch
+	default:
+		i.Status = ItemOut
+	}
+	i.ExitSerialNumber = serialNumber
+	i.ExitNote = note
+	i.CheckedAt = &at
+	i.CheckedBy = by
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// EscortAssignment records a staff member escorting a visitor for part of a
+// visit. A handover ends one assignment and starts the next, so the visit's
+// assignments show who was responsible for the visitor at any time.
+type EscortAssignment struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	VisitID      uint       `gorm:"not null;index" json:"visit_id"`
+	Visit        *Visit     `gorm:"foreignKey:VisitID" json:"visit,omitempty"`
+	LocationID   uint       `gorm:"not null;index" json:"location_id"`
+	EscortID     uint       `gorm:"not null;index" json:"escort_id"` // Host directory entry of the staff member
+	EscortName   string     `json:"escort_name"`
+	StartedAt    time.Time  `gorm:"not null" json:"started_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`    // Set at handover or sign-out
+	AssignedBy   *uint      `json:"assigned_by,omitempty"` // Nil for service accounts
+	HandoverNote string     `json:"handover_note,omitempty"`
+}
+
+// IsCurrent returns true if the escort is still responsible for the visitor
+func (a *EscortAssignment) IsCurrent() bool {
+	return a.EndedAt == nil
+}
+
+// Covers returns true if the escort was responsible for the visitor at t
+func (a *EscortAssignment) Covers(t time.Time) bool {
+	return !t.Before(a.StartedAt) && (a.EndedAt == nil || t.Before(*a.EndedAt))
+}
@@ -33,6 +33,7 @@ type FitnessAttendance struct {
 	Date       time.Time      `gorm:"not null" json:"date"`
 	CheckIn    time.Time      `gorm:"not null" json:"check_in"`
 	CheckOut   *time.Time     `json:"check_out,omitempty"`
+	LocationID *uint          `json:"location_id,omitempty"` // Where the gym is; used by the muster roll
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 }
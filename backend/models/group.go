@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// VisitGroup is a delegation or crew signed in together. Members share the
+// group's host, area and purpose; each still has their own visit and badge.
+type VisitGroup struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"not null" json:"name"`
+	LeadVisitorID uint      `gorm:"not null" json:"lead_visitor_id"`
+	LeadVisitor   *Visitor  `gorm:"foreignKey:LeadVisitorID" json:"lead_visitor,omitempty"`
+	AreaOfVisit   string    `gorm:"not null" json:"area_of_visit"`
+	AreaID        *uint     `json:"area_id,omitempty"`
+	Purpose       string    `gorm:"not null" json:"purpose"`
+	HostName      string    `json:"host_name"`
+	HostID        *uint     `json:"host_id,omitempty"`
+	LocationID    uint      `gorm:"not null;index" json:"location_id"`
+	Location      *Location `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	CreatedBy     *uint     `json:"created_by,omitempty"`
+	Members       []*Visit  `gorm:"foreignKey:GroupID" json:"members,omitempty"`
+	OnSite        int       `gorm:"-" json:"on_site"` // Members still signed in
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// GroupMemberBadge names a group member who has not handed their badge back
+type GroupMemberBadge struct {
+	VisitID     uint   `json:"visit_id"`
+	VisitorID   uint   `json:"visitor_id"`
+	VisitorName string `json:"visitor_name"`
+	BadgeNumber string `json:"badge_number"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Host is an employee at a location who receives visitors
+type Host struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	LocationID uint      `gorm:"not null;index" json:"location_id"`
+	Location   *Location `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	Name       string    `gorm:"not null" json:"name"`
+	Department string    `json:"department"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	Active     bool      `gorm:"not null;default:true" json:"active"` // Inactive hosts stay on past visits but cannot be picked
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -4,10 +4,35 @@ import "time"
 
 // Location represents a physical location or site
 type Location struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"unique;not null" json:"name"`
-	Code      string    `gorm:"unique;not null" json:"code"`
-	Address   string    `json:"address"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"unique;not null" json:"name"`
+	Code         string    `gorm:"unique;not null" json:"code"`
+	Address      string    `json:"address"`
+	EndOfDayTime string    `json:"end_of_day_time"` // HH:MM local time of the daily visitor sweep; empty disables it
+	Timezone     string    `json:"timezone"`        // IANA name, e.g. Africa/Nairobi; empty uses the server's
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TimeLocation returns the location's time zone, falling back to the server's
+func (l *Location) TimeLocation() *time.Location {
+	if l.Timezone != "" {
+		if tz, err := time.LoadLocation(l.Timezone); err == nil {
+			return tz
+		}
+	}
+	return time.Local
+}
+
+// EndOfDay returns when the sweep is due on the day containing t
+func (l *Location) EndOfDay(t time.Time) (time.Time, bool) {
+	if l.EndOfDayTime == "" {
+		return time.Time{}, false
+	}
+	clock, err := time.Parse("15:04", l.EndOfDayTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+	local := t.In(l.TimeLocation())
+	return time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, local.Location()), true
 }
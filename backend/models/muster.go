@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// MusterPersonType identifies which record put a person on site
+type MusterPersonType string
+
+const (
+	MusterVisitor MusterPersonType = "visitor" // Signed-in visit
+	MusterFitness MusterPersonType = "fitness" // Open gym attendance
+	MusterDriver  MusterPersonType = "driver"  // Cargo not yet released
+)
+
+// Areas used on the muster roll for people without an area of visit
+const (
+	MusterAreaFitness = "Fitness Centre"
+	MusterAreaCargo   = "Cargo"
+)
+
+// MusterPerson is one person on site. RecordID is the visit, attendance or
+// cargo ID, depending on Type.
+type MusterPerson struct {
+	Type         MusterPersonType `json:"type"`
+	RecordID     uint             `json:"record_id"`
+	Name         string           `json:"name"`
+	IDNumber     string           `json:"id_number,omitempty"`
+	Company      string           `json:"company,omitempty"`
+	Area         string           `json:"area"`
+	BadgeNumber  string           `json:"badge_number,omitempty"`
+	Vehicle      string           `json:"vehicle,omitempty"`
+	Since        time.Time        `json:"since"`
+	AccountedFor bool             `json:"accounted_for"`
+	AccountedAt  *time.Time       `json:"accounted_at,omitempty"`
+}
+
+// MusterArea groups the people on site in one area
+type MusterArea struct {
+	Area   string         `json:"area"`
+	People []MusterPerson `json:"people"`
+}
+
+// MusterRoll lists everyone on site at a location
+type MusterRoll struct {
+	Location     *Location    `json:"location"`
+	GeneratedAt  time.Time    `json:"generated_at"`
+	Drill        *Drill       `json:"drill,omitempty"` // The drill in progress, if any
+	Total        int          `json:"total"`
+	AccountedFor int          `json:"accounted_for"`
+	Areas        []MusterArea `json:"areas"`
+}
+
+// DrillMark records that a person was accounted for during a drill
+type DrillMark struct {
+	Type        MusterPersonType `json:"type"`
+	RecordID    uint             `json:"record_id"`
+	AccountedAt time.Time        `json:"accounted_at"`
+	AccountedBy uint             `json:"accounted_by"`
+}
+
+// Drill is an evacuation drill or real muster at a location. While it runs,
+// people on the muster roll are marked as accounted for; ending it keeps the
+// people still missing.
+type Drill struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	LocationID  uint           `gorm:"not null;index" json:"location_id"`
+	StartedAt   time.Time      `json:"started_at"`
+	StartedBy   uint           `json:"started_by"`
+	EndedAt     *time.Time     `json:"ended_at,omitempty"`
+	EndedBy     *uint          `json:"ended_by,omitempty"`
+	Marks       []DrillMark    `gorm:"serializer:json" json:"marks"`
+	Total       int            `json:"total,omitempty"`                              // Set when the drill ends
+	Unaccounted []MusterPerson `gorm:"serializer:json" json:"unaccounted,omitempty"` // Set when the drill ends
+}
+
+// IsActive returns true while the drill is running
+func (d *Drill) IsActive() bool {
+	return d.EndedAt == nil
+}
+
+// Mark returns the mark for a person, if they have been accounted for
+func (d *Drill) Mark(personType MusterPersonType, recordID uint) (DrillMark, bool) {
+	for _, mark := range d.Marks {
+		if mark.Type == personType && mark.RecordID == recordID {
+			return mark, true
+		}
+	}
+	return DrillMark{}, false
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ParkingBayStatus tracks whether a bay can be assigned to a visitor's vehicle
+type ParkingBayStatus string
+
+const (
+	BayAvailable    ParkingBayStatus = "available"
+	BayOccupied     ParkingBayStatus = "occupied"
+	BayOutOfService ParkingBayStatus = "out_of_service" // Closed for works, reserved and so on
+)
+
+// IsValid returns true if the status is known
+func (s ParkingBayStatus) IsValid() bool {
+	switch s {
+	case BayAvailable, BayOccupied, BayOutOfService:
+		return true
+	}
+	return false
+}
+
+// ParkingBay is a visitor parking space held in a location's inventory
+type ParkingBay struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	LocationID uint             `gorm:"not null;uniqueIndex:idx_bay_number" json:"location_id"`
+	Location   *Location        `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	Number     string           `gorm:"not null;uniqueIndex:idx_bay_number" json:"number"`
+	Zone       string           `json:"zone"` // e.g. Basement, North car park
+	Status     ParkingBayStatus `gorm:"not null;default:'available'" json:"status"`
+	VisitID    *uint            `json:"visit_id,omitempty"` // Visit whose vehicle is parked in the bay
+	Visit      *Visit           `gorm:"foreignKey:VisitID" json:"visit,omitempty"`
+	OccupiedAt *time.Time       `json:"occupied_at,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// ParkingOccupancy counts a location's bays by status
+type ParkingOccupancy struct {
+	LocationID   uint    `json:"location_id"`
+	LocationCode string  `json:"location_code"`
+	Total        int     `json:"total"`
+	Available    int     `json:"available"`
+	Occupied     int     `json:"occupied"`
+	OutOfService int     `json:"out_of_service"`
+	Rate         float64 `json:"occupancy_rate"` // Occupied share of the bays in service, 0 to 1
+}
+
+// NormalizeBayNumber canonicalises a parking bay number for lookups
+func NormalizeBayNumber(number string) string {
+	return strings.ToUpper(strings.TrimSpace(number))
+}
+
+// NormalizeVehicleRegistration canonicalises a number plate: upper case, no
+// surrounding or doubled spaces
+func NormalizeVehicleRegistration(registration string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(registration)), " ")
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+)
+
+// Permission represents a scoped capability that can be granted to an API key
+type Permission string
+
+const (
+	PermVisitorsRead      Permission = "visitors:read"
+	PermVisitorsWrite     Permission = "visitors:write"
+	PermVisitorsSignInOut Permission = "visitors:signinout"
+	PermCargoRead         Permission = "cargo:read"
+	PermCargoWrite        Permission = "cargo:write"
+	PermFitnessRead       Permission = "fitness:read"
+	PermFitnessCheckIn    Permission = "fitness:checkin"
+	PermSCIMProvision     Permission = "scim:provision"
+	// PermKiosk makes the account a self-service kiosk, limited to the kiosk endpoints at one location
+	PermKiosk Permission = "kiosk"
+)
+
+// AllPermissions lists every permission that can be granted to a service account
+var AllPermissions = []Permission{
+	PermVisitorsRead,
+	PermVisitorsWrite,
+	PermVisitorsSignInOut,
+	PermCargoRead,
+	PermCargoWrite,
+	PermFitnessRead,
+	PermFitnessCheckIn,
+	PermSCIMProvision,
+	PermKiosk,
+}
+
+// IsValid returns true if the permission is a known permission
+func (p Permission) IsValid() bool {
+	for _, perm := range AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceAccount represents a non-human API client such as a turnstile controller or kiosk
+type ServiceAccount struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"unique;not null" json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `gorm:"serializer:json" json:"permissions"`
+	LocationIDs []uint       `gorm:"serializer:json" json:"location_ids"` // Empty means all locations
+	Disabled    bool         `gorm:"not null;default:false" json:"disabled"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// HasPermission checks if the service account was granted the permission
+func (s *ServiceAccount) HasPermission(perm Permission) bool {
+	for _, p := range s.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKiosk returns true if the account is a self-service kiosk
+func (s *ServiceAccount) IsKiosk() bool {
+	return s.HasPermission(PermKiosk)
+}
+
+// CanAccessLocation checks if the service account may act on the given location
+func (s *ServiceAccount) CanAccessLocation(locationID uint) bool {
+	if len(s.LocationIDs) == 0 {
+		return true
+	}
+	for _, id := range s.LocationIDs {
+		if id == locationID {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey represents a revocable credential issued to a service account.
+// Only the SHA-256 hash of the secret is stored; the prefix identifies the key.
+type APIKey struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ServiceAccountID uint       `gorm:"not null" json:"service_account_id"`
+	Name             string     `json:"name"`
+	Prefix           string     `gorm:"unique;not null" json:"prefix"`
+	KeyHash          string     `gorm:"not null" json:"-"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP       string     `json:"last_used_ip,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// IsActive returns true if the key is neither revoked nor expired
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	if k.ExpiresAt != nil && time.Now().After(*k.ExpiresAt) {
+		return false
+	}
+	return true
+}
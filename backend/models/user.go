@@ -12,19 +12,43 @@ const (
 	RoleDashboardVisitor UserRole = "dashboard_visitor"
 	RoleDashboardCargo   UserRole = "dashboard_cargo"
 	RoleAdmin            UserRole = "admin"
+
+	// RoleServiceAccount is assigned to the principal built for API key requests.
+	// It cannot be given to user accounts.
+	RoleServiceAccount UserRole = "service_account"
+)
+
+// AuthProvider identifies where a user's credentials are verified
+type AuthProvider string
+
+const (
+	AuthProviderLocal AuthProvider = "local"
+	AuthProviderOIDC  AuthProvider = "oidc"
+	AuthProviderLDAP  AuthProvider = "ldap"
 )
 
 // User represents a system user with role-based permissions
 type User struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	Username     string    `gorm:"unique;not null" json:"username"`
-	PasswordHash string    `gorm:"not null" json:"-"`
-	Role         UserRole  `gorm:"not null" json:"role"`
-	FullName     string    `gorm:"not null" json:"full_name"`
-	LocationID   *uint     `json:"location_id"` // Nullable, as super admins might not have a location
-	Location     *Location `gorm:"foreignKey:LocationID" json:"location,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	Username       string       `gorm:"unique;not null" json:"username"`
+	PasswordHash   string       `gorm:"not null" json:"-"`
+	Role           UserRole     `gorm:"not null" json:"role"`
+	FullName       string       `gorm:"not null" json:"full_name"`
+	Email          string       `json:"email,omitempty"`
+	LocationID     *uint        `json:"location_id"` // Nullable, as super admins might not have a location
+	Location       *Location    `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	AuthProvider   AuthProvider `gorm:"not null;default:'local'" json:"auth_provider"`
+	ExternalID     string       `json:"external_id,omitempty"`      // Subject at the identity provider
+	SCIMExternalID string       `json:"scim_external_id,omitempty"` // externalId set by the SCIM provisioning client
+	SCIMCreated    bool         `json:"scim_created,omitempty"`     // Created by the SCIM provisioning client
+	Disabled       bool         `gorm:"not null;default:false" json:"disabled"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// IsLocal returns true if the user signs in with a password stored in this system
+func (u *User) IsLocal() bool {
+	return u.AuthProvider == "" || u.AuthProvider == AuthProviderLocal
 }
 
 // CanCreateVisitor checks if user can create visitor entries
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"time"
+)
+
+// Visit represents one time a visitor came on site, from sign-in to sign-out
+type Visit struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	VisitorID   uint          `gorm:"not null;index" json:"visitor_id"`
+	Visitor     *Visitor      `gorm:"foreignKey:VisitorID" json:"visitor,omitempty"`
+	AreaOfVisit string        `gorm:"not null" json:"area_of_visit"`
+	AreaID      *uint         `gorm:"index" json:"area_id,omitempty"` // Managed area; area_of_visit holds its name
+	Purpose     string        `gorm:"not null" json:"purpose"`
+	HostName    string        `json:"host_name"` // Optional; the host's name when HostID is set
+	HostID      *uint         `json:"host_id,omitempty"`
+	Host        *Host         `gorm:"foreignKey:HostID" json:"host,omitempty"`
+	EscortID    *uint         `json:"escort_id,omitempty"` // Staff member escorting the visitor now, from the host directory
+	EscortName  string        `json:"escort_name,omitempty"`
+	BadgeNumber string        `json:"badge_number"`
+	BadgeID     *uint         `json:"badge_id,omitempty"`
+	GroupID     *uint         `json:"group_id,omitempty"` // Set when signed in as part of a group
+	KioskID     *uint         `json:"kiosk_id,omitempty"` // Service account of the kiosk the visitor checked in at
+	Status      VisitorStatus `gorm:"not null;default:'signed_in'" json:"status"`
+	SignInTime  time.Time     `gorm:"not null" json:"sign_in_time"`
+	SignOutTime *time.Time    `json:"sign_out_time,omitempty"`
+	// Equipment declared at sign-in; ItemMismatch is set when what left differs
+	Items        []*DeclaredItem `gorm:"foreignKey:VisitID" json:"items,omitempty"`
+	ItemMismatch bool            `json:"item_mismatch,omitempty"`
+	// Vehicle the visitor came in and the parking bay assigned to it, released at sign-out
+	VehicleRegistration string `gorm:"index" json:"vehicle_registration,omitempty"`
+	VehicleMake         string `json:"vehicle_make,omitempty"`
+	VehicleColour       string `json:"vehicle_colour,omitempty"`
+	ParkingBay          string `json:"parking_bay,omitempty"`
+	ParkingBayID        *uint  `json:"parking_bay_id,omitempty"`
+	// ExpectedDeparture is when the visitor should leave; OverdueAt is set once they are flagged
+	ExpectedDeparture *time.Time `json:"expected_departure,omitempty"`
+	OverdueAt         *time.Time `json:"overdue_at,omitempty"`
+	// Last status change and who made it (nil for jobs); every change is kept as a VisitStatusChange
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	StatusChangedBy *uint      `json:"status_changed_by,omitempty"`
+	// Pre-registration: the arrival window, who registered the visit and its approval
+	ExpectedFrom     *time.Time `json:"expected_from,omitempty"`
+	ExpectedUntil    *time.Time `json:"expected_until,omitempty"`
+	RegisteredBy     *uint      `json:"registered_by,omitempty"`
+	ApprovalRequired bool       `json:"approval_required,omitempty"`
+	ApprovedBy       *uint      `json:"approved_by,omitempty"`
+	ApprovedAt       *time.Time `json:"approved_at,omitempty"`
+	CancelReason     string     `json:"cancel_reason,omitempty"`
+	DenyReason       string     `json:"deny_reason,omitempty"`
+	LocationID       uint       `gorm:"not null" json:"location_id"`
+	Location         *Location  `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// VisitStatusChange records one transition of a visit's status
+type VisitStatusChange struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	VisitID   uint          `gorm:"not null;index" json:"visit_id"`
+	From      VisitorStatus `json:"from,omitempty"` // Empty when the visit was created
+	To        VisitorStatus `gorm:"not null" json:"to"`
+	ChangedAt time.Time     `gorm:"not null" json:"changed_at"`
+	ChangedBy *uint         `json:"changed_by,omitempty"` // Nil for the end-of-day sweep and other jobs
+	Reason    string        `json:"reason,omitempty"`
+}
+
+// Reasons a pre-registration is cancelled other than a free-text one
+const (
+	CancelReasonLapsed   = "lapsed"   // The visitor did not arrive within the window
+	CancelReasonRejected = "rejected" // An approver turned the visit down
+	// CancelReasonUnconfirmed is set by the end-of-day sweep on kiosk submissions nobody confirmed
+	CancelReasonUnconfirmed = "unconfirmed"
+)
+
+// DenyReasonWatchlist is recorded when the watchlist blocks a pre-registered visitor at the gate
+const DenyReasonWatchlist = "watchlist"
+
+// DefaultVisitDuration is the expected length of a visit when none is given
+const DefaultVisitDuration = 8 * time.Hour
+
+// IsActive returns true if the visitor is still on site for this visit
+func (v *Visit) IsActive() bool {
+	return v.Status == StatusSignedIn
+}
+
+// IsExpected returns true if the visit is pre-registered and the visitor has not arrived
+func (v *Visit) IsExpected() bool {
+	return v.Status == StatusExpected
+}
+
+// IsPending returns true if the visit was submitted at a kiosk and awaits an operator
+func (v *Visit) IsPending() bool {
+	return v.Status == StatusPending
+}
+
+// AwaitingApproval returns true if a pre-registration still needs to be approved
+func (v *Visit) AwaitingApproval() bool {
+	return v.IsExpected() && v.ApprovalRequired && v.ApprovedAt == nil
+}
+
+// HasStarted returns true once the visitor has signed in for the visit
+func (v *Visit) HasStarted() bool {
+	switch v.Status {
+	case StatusSignedIn, StatusSignedOut, StatusAutoSignedOut:
+		return true
+	}
+	return false
+}
+
+// transition moves the visit to the next status if the transition table
+// allows it, recording when and by whom
+func (v *Visit) transition(next VisitorStatus, at time.Time, by *uint) error {
+	if !v.Status.CanBecome(next) {
+		return &TransitionError{From: v.Status, To: next}
+	}
+	v.Status = next
+	v.StatusChangedAt = &at
+	v.StatusChangedBy = by
+	return nil
+}
+
+// Expect registers a new visit ahead of the visitor's arrival
+func (v *Visit) Expect(at time.Time, by *uint) error {
+	return v.transition(StatusExpected, at, by)
+}
+
+// Submit records a visitor checking in at a kiosk, for an operator to confirm
+func (v *Visit) Submit(at time.Time) error {
+	return v.transition(StatusPending, at, nil)
+}
+
+// SignIn starts a new visit, or an expected or pending one when the visitor arrives
+func (v *Visit) SignIn(at time.Time, by *uint) error {
+	if err := v.transition(StatusSignedIn, at, by); err != nil {
+		return err
+	}
+	v.SignInTime = at
+	return nil
+}
+
+// Cancel withdraws a pre-registration
+func (v *Visit) Cancel(reason string, at time.Time, by *uint) error {
+	if err := v.transition(StatusCancelled, at, by); err != nil {
+		return err
+	}
+	v.CancelReason = reason
+	return nil
+}
+
+// Deny records that a pre-registered or kiosk visitor was turned away at the gate
+func (v *Visit) Deny(reason string, at time.Time, by *uint) error {
+	if err := v.transition(StatusDenied, at, by); err != nil {
+		return err
+	}
+	v.DenyReason = reason
+	return nil
+}
+
+// SignOut closes the visit
+func (v *Visit) SignOut(at time.Time, by *uint) error {
+	if err := v.transition(StatusSignedOut, at, by); err != nil {
+		return err
+	}
+	v.SignOutTime = &at
+	return nil
+}
+
+// Duration returns how long the visit lasted, or has lasted so far if still open
+func (v *Visit) Duration() time.Duration {
+	if v.SignOutTime != nil {
+		return v.SignOutTime.Sub(v.SignInTime)
+	}
+	return time.Since(v.SignInTime)
+}
+
+// IsOverdue returns true if the visitor is still on site past their expected departure
+func (v *Visit) IsOverdue(now time.Time) bool {
+	return v.IsActive() && v.ExpectedDeparture != nil && now.After(*v.ExpectedDeparture)
+}
+
+// AutoSignOut closes a visit left open at the end of the day; by is set when
+// the sweep was started by hand
+func (v *Visit) AutoSignOut(at time.Time, by *uint) error {
+	if err := v.transition(StatusAutoSignedOut, at, by); err != nil {
+		return err
+	}
+	v.SignOutTime = &at
+	return nil
+}
+
+// StatusReason is why the visit was cancelled or denied, if it was
+func (v *Visit) StatusReason() string {
+	switch v.Status {
+	case StatusCancelled:
+		return v.CancelReason
+	case StatusDenied:
+		return v.DenyReason
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// VisitImageKind is what a visit image shows
+type VisitImageKind string
+
+const (
+	ImageVisitorPhoto VisitImageKind = "photo"       // Taken at the gate
+	ImageIDDocument   VisitImageKind = "id_document" // Scan of the ID card retained during the visit
+)
+
+// IsValid checks the kind is one of the known kinds
+func (k VisitImageKind) IsValid() bool {
+	return k == ImageVisitorPhoto || k == ImageIDDocument
+}
+
+// VisitImage is a picture attached to a visit. The files live in the blob
+// store and are deleted along with the visit.
+type VisitImage struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	VisitID      uint           `gorm:"not null;index" json:"visit_id"`
+	LocationID   uint           `gorm:"not null" json:"location_id"`
+	Kind         VisitImageKind `gorm:"not null" json:"kind"`
+	ContentType  string         `gorm:"not null" json:"content_type"`
+	Size         int64          `json:"size"`
+	Width        int            `json:"width"`
+	Height       int            `json:"height"`
+	BlobKey      string         `gorm:"not null" json:"-"`
+	ThumbnailKey string         `json:"-"`
+	UploadedBy   uint           `json:"uploaded_by"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
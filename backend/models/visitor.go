@@ -1,50 +1,89 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
-// VisitorStatus represents the current status of a visitor
+// VisitorStatus represents the status of a visit
 type VisitorStatus string
 
 const (
 	StatusSignedIn  VisitorStatus = "signed_in"
 	StatusSignedOut VisitorStatus = "signed_out"
+	// StatusAutoSignedOut marks visits closed by the end-of-day sweep
+	StatusAutoSignedOut VisitorStatus = "auto_signed_out"
+	// StatusExpected marks a pre-registered visit the visitor has not arrived for yet
+	StatusExpected VisitorStatus = "expected"
+	// StatusCancelled marks a pre-registration that was cancelled, rejected or lapsed
+	StatusCancelled VisitorStatus = "cancelled"
+	// StatusDenied marks a pre-registered or kiosk visitor turned away at the gate
+	StatusDenied VisitorStatus = "denied"
+	// StatusPending marks a visit submitted at a kiosk, waiting for an operator to confirm it
+	StatusPending VisitorStatus = "pending"
 )
 
-// Visitor represents a visitor entry in the logbook
+// visitTransitions lists the statuses a visit can move to from each status.
+// New visits start from the empty status; statuses not listed are final.
+var visitTransitions = map[VisitorStatus][]VisitorStatus{
+	"":             {StatusExpected, StatusSignedIn, StatusPending},
+	StatusExpected: {StatusSignedIn, StatusPending, StatusDenied, StatusCancelled},
+	StatusPending:  {StatusSignedIn, StatusDenied, StatusCancelled},
+	StatusSignedIn: {StatusSignedOut, StatusAutoSignedOut},
+}
+
+// CanBecome returns true if a visit in this status may move to next
+func (s VisitorStatus) CanBecome(next VisitorStatus) bool {
+	for _, allowed := range visitTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal returns true once a visit is over and its status can no longer change
+func (s VisitorStatus) IsFinal() bool {
+	return s != "" && len(visitTransitions[s]) == 0
+}
+
+// TransitionError reports a status change the transition table does not allow
+type TransitionError struct {
+	From VisitorStatus
+	To   VisitorStatus
+}
+
+func (e *TransitionError) Error() string {
+	from := string(e.From)
+	if from == "" {
+		from = "new"
+	}
+	return "visit cannot go from " + from + " to " + string(e.To)
+}
+
+// Visitor represents a person who visits; each time they come on site is a Visit
 type Visitor struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Name         string         `gorm:"not null" json:"name"`
-	IDNumber     string         `gorm:"not null" json:"id_number"`
-	AreaOfVisit  string         `gorm:"not null" json:"area_of_visit"`
-	CompanyFrom  string         `json:"company_from"` // Optional
-	Purpose      string         `gorm:"not null" json:"purpose"`
-	BadgeNumber  string         `json:"badge_number"`
-	Status       VisitorStatus  `gorm:"not null;default:'signed_in'" json:"status"`
-	SignInTime   time.Time      `gorm:"not null" json:"sign_in_time"`
-	SignOutTime  *time.Time     `json:"sign_out_time,omitempty"`
-	LocationID   uint           `gorm:"not null" json:"location_id"`
-	Location     *Location      `gorm:"foreignKey:LocationID" json:"location,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-}
-
-// IsActive returns true if the visitor is currently signed in
-func (v *Visitor) IsActive() bool {
-	return v.Status == StatusSignedIn
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"not null" json:"name"`
+	IDNumber     string    `gorm:"not null" json:"id_number"`
+	CompanyFrom  string    `json:"company_from"`                     // Optional
+	CurrentVisit *Visit    `gorm:"-" json:"current_visit,omitempty"` // Latest visit, populated on read
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-// SignIn marks the visitor as signed in with a badge number
-func (v *Visitor) SignIn(badgeNumber string) {
-	v.BadgeNumber = badgeNumber
-	v.SignInTime = time.Now()
-	v.Status = StatusSignedIn
+// IsActive returns true if the visitor's latest visit is still signed in
+func (v *Visitor) IsActive() bool {
+	return v.CurrentVisit != nil && v.CurrentVisit.IsActive()
 }
 
-// SignOut marks the visitor as signed out
-func (v *Visitor) SignOut() {
-	now := time.Now()
-	v.SignOutTime = &now
-	v.Status = StatusSignedOut
+// NormalizeIDNumber canonicalises an ID number for matching, ignoring case,
+// spaces and dashes
+func NormalizeIDNumber(idNumber string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(idNumber)))
 }
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WatchlistSeverity decides what happens when a visitor matches an entry
+type WatchlistSeverity string
+
+const (
+	WatchlistBlock WatchlistSeverity = "block" // Entry is denied outright
+	WatchlistWarn  WatchlistSeverity = "warn"  // Entry requires a supervisor override
+)
+
+// IsValid returns true if the severity is known
+func (s WatchlistSeverity) IsValid() bool {
+	return s == WatchlistBlock || s == WatchlistWarn
+}
+
+// WatchlistEntry identifies a person who must not be signed in without review
+type WatchlistEntry struct {
+	ID         uint              `gorm:"primaryKey" json:"id"`
+	IDNumber   string            `gorm:"index" json:"id_number"` // Optional when a name is given
+	Name       string            `json:"name"`                   // Optional when an ID number is given
+	Reason     string            `gorm:"not null" json:"reason"`
+	Severity   WatchlistSeverity `gorm:"not null" json:"severity"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	LocationID *uint             `json:"location_id"` // Nil applies to all locations
+	Location   *Location         `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	CreatedBy  uint              `json:"created_by"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// WatchlistMatch describes why a visitor matched an entry
+type WatchlistMatch struct {
+	Entry     *WatchlistEntry `json:"entry"`
+	MatchedOn string          `json:"matched_on"` // id_number or name
+}
+
+// IsActive returns true if the entry has not expired
+func (w *WatchlistEntry) IsActive() bool {
+	return w.ExpiresAt == nil || time.Now().Before(*w.ExpiresAt)
+}
+
+// AppliesTo returns true if the entry covers the location
+func (w *WatchlistEntry) AppliesTo(locationID uint) bool {
+	return w.LocationID == nil || *w.LocationID == locationID
+}
+
+// Match checks a visitor against the entry: exact on ID number, fuzzy on name
+func (w *WatchlistEntry) Match(name, idNumber string) (string, bool) {
+	if w.IDNumber != "" && NormalizeIDNumber(w.IDNumber) == NormalizeIDNumber(idNumber) {
+		return "id_number", true
+	}
+	if w.Name != "" && NamesMatch(w.Name, name) {
+		return "name", true
+	}
+	return "", false
+}
+
+// nameTokens lowercases a name and splits it into letter-only words
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// NamesMatch reports whether two names likely refer to the same person. It
+// tolerates a typo per word, reordered words and an added or missing middle
+// name, but needs at least two words in common.
+func NamesMatch(a, b string) bool {
+	short, long := nameTokens(a), nameTokens(b)
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len(short) == 0 {
+		return false
+	}
+	if len(short) == 1 {
+		// A single word is too weak to match on its own unless both names are that word
+		return len(long) == 1 && tokensMatch(short[0], long[0])
+	}
+
+	used := make([]bool, len(long))
+	for _, token := range short {
+		found := false
+		for i, candidate := range long {
+			if !used[i] && tokensMatch(token, candidate) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// tokensMatch allows one edit in words of four letters or more
+func tokensMatch(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len([]rune(a)) < 4 || len([]rune(b)) < 4 {
+		return false
+	}
+	return levenshtein(a, b) <= 1
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
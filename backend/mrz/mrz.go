@@ -0,0 +1,248 @@
+// Package mrz reads the machine readable zone of travel documents as defined
+// by ICAO 9303: TD1 identity cards (3 lines of 30 characters) and TD3
+// passports (2 lines of 44 characters).
+package mrz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is the size of the document's MRZ
+type Format string
+
+const (
+	TD1 Format = "TD1" // Identity cards
+	TD3 Format = "TD3" // Passports
+)
+
+// ErrFormat is returned when the text is not a TD1 or TD3 MRZ
+var ErrFormat = errors.New("MRZ must be 3 lines of 30 characters (ID card) or 2 lines of 44 characters (passport)")
+
+// CheckDigitError lists the fields whose check digits do not match
+type CheckDigitError struct {
+	Fields []string
+}
+
+func (e *CheckDigitError) Error() string {
+	return "check digit mismatch: " + strings.Join(e.Fields, ", ")
+}
+
+// Date is a calendar date, written as 2006-01-02 in JSON. It is zero, and
+// null in JSON, when the MRZ leaves the date blank.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + d.Format("2006-01-02") + `"`), nil
+}
+
+// Document holds the fields read from an MRZ
+type Document struct {
+	Format         Format `json:"format"`
+	DocumentType   string `json:"document_type"` // P for passports, I, A or C for ID cards
+	IssuingCountry string `json:"issuing_country"`
+	Surname        string `json:"surname"`
+	GivenNames     string `json:"given_names"`
+	DocumentNumber string `json:"document_number"`
+	Nationality    string `json:"nationality"`
+	DateOfBirth    Date   `json:"date_of_birth"`
+	Sex            string `json:"sex"` // M, F or X
+	ExpiryDate     Date   `json:"expiry_date"`
+	OptionalData   string `json:"optional_data,omitempty"` // Personal number on passports
+}
+
+// FullName returns the given names followed by the surname, as written on visitor records
+func (d *Document) FullName() string {
+	return strings.TrimSpace(titleCase(d.GivenNames + " " + d.Surname))
+}
+
+// Expired reports whether the document's validity ended before the day of now
+func (d *Document) Expired(now time.Time) bool {
+	if d.ExpiryDate.IsZero() {
+		return false
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return d.ExpiryDate.Before(today)
+}
+
+// Parse reads an MRZ as produced by a document scanner. Surrounding blank
+// lines and spaces are ignored. All check digits must match; on a mismatch
+// the partly read document is returned with a *CheckDigitError.
+func Parse(text string, now time.Time) (*Document, error) {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r", "\n"), "\n") {
+		line = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(line), " ", ""))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	for _, line := range lines {
+		for _, r := range line {
+			if charValue(r) < 0 {
+				return nil, fmt.Errorf("MRZ contains invalid character %q", r)
+			}
+		}
+	}
+
+	switch {
+	case len(lines) == 3 && len(lines[0]) == 30 && len(lines[1]) == 30 && len(lines[2]) == 30:
+		return parseTD1(lines, now)
+	case len(lines) == 2 && len(lines[0]) == 44 && len(lines[1]) == 44:
+		return parseTD3(lines, now)
+	default:
+		return nil, ErrFormat
+	}
+}
+
+// checker collects the fields whose check digits fail
+type checker struct {
+	failed []string
+}
+
+func (c *checker) check(field, data string, digit byte) {
+	if !validCheckDigit(data, digit) {
+		c.failed = append(c.failed, field)
+	}
+}
+
+func (c *checker) err() error {
+	if len(c.failed) == 0 {
+		return nil
+	}
+	return &CheckDigitError{Fields: c.failed}
+}
+
+func parseTD3(lines []string, now time.Time) (*Document, error) {
+	l1, l2 := lines[0], lines[1]
+	doc := &Document{
+		Format:         TD3,
+		DocumentType:   field(l1[0:2]),
+		IssuingCountry: field(l1[2:5]),
+		DocumentNumber: field(l2[0:9]),
+		Nationality:    field(l2[10:13]),
+		DateOfBirth:    birthDate(l2[13:19], now),
+		Sex:            field(l2[20:21]),
+		ExpiryDate:     expiryDate(l2[21:27]),
+		OptionalData:   field(l2[28:42]),
+	}
+	doc.Surname, doc.GivenNames = names(l1[5:44])
+
+	var c checker
+	c.check("document_number", l2[0:9], l2[9])
+	c.check("date_of_birth", l2[13:19], l2[19])
+	c.check("expiry_date", l2[21:27], l2[27])
+	// The personal number's check digit may be a filler when there is none
+	if !(l2[42] == '<' && strings.Trim(l2[28:42], "<") == "") {
+		c.check("optional_data", l2[28:42], l2[42])
+	}
+	c.check("composite", l2[0:10]+l2[13:20]+l2[21:43], l2[43])
+	return doc, c.err()
+}
+
+func parseTD1(lines []string, now time.Time) (*Document, error) {
+	l1, l2, l3 := lines[0], lines[1], lines[2]
+	doc := &Document{
+		Format:         TD1,
+		DocumentType:   field(l1[0:2]),
+		IssuingCountry: field(l1[2:5]),
+		DateOfBirth:    birthDate(l2[0:6], now),
+		Sex:            field(l2[7:8]),
+		ExpiryDate:     expiryDate(l2[8:14]),
+		Nationality:    field(l2[15:18]),
+	}
+	doc.Surname, doc.GivenNames = names(l3)
+
+	var c checker
+	number, digit, optional := l1[5:14], l1[14], l1[15:30]
+	if digit == '<' {
+		// Document numbers over 9 characters continue in the optional data,
+		// ending with their check digit
+		end := strings.IndexByte(optional, '<')
+		if end < 1 {
+			end = len(optional)
+		}
+		number += optional[:end-1]
+		digit = optional[end-1]
+		optional = optional[end:]
+	}
+	doc.DocumentNumber = field(number)
+	doc.OptionalData = strings.TrimSpace(field(optional) + " " + field(l2[18:29]))
+
+	c.check("document_number", number, digit)
+	c.check("date_of_birth", l2[0:6], l2[6])
+	c.check("expiry_date", l2[8:14], l2[14])
+	c.check("composite", l1[5:30]+l2[0:7]+l2[8:15]+l2[18:29], l2[29])
+	return doc, c.err()
+}
+
+// charValue returns the value of an MRZ character for check digits, or -1
+func charValue(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r - '0')
+	case r >= 'A' && r <= 'Z':
+		return int(r-'A') + 10
+	case r == '<':
+		return 0
+	}
+	return -1
+}
+
+// validCheckDigit applies the 7-3-1 weighting of ICAO 9303
+func validCheckDigit(data string, digit byte) bool {
+	weights := [3]int{7, 3, 1}
+	sum := 0
+	for i, r := range data {
+		sum += charValue(r) * weights[i%3]
+	}
+	return digit >= '0' && digit <= '9' && int(digit-'0') == sum%10
+}
+
+// field strips the filler characters from a fixed-width field
+func field(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "<", " "))
+}
+
+// names splits the name field into the surname and the given names
+func names(s string) (string, string) {
+	s = strings.TrimRight(s, "<")
+	surname, given, _ := strings.Cut(s, "<<")
+	return field(surname), strings.Join(strings.Fields(field(given)), " ")
+}
+
+// birthDate reads a YYMMDD date of birth; two-digit years after this year are in the last century
+func birthDate(s string, now time.Time) Date {
+	d, err := time.Parse("20060102", "20"+s)
+	if err == nil && d.Year() > now.Year() {
+		d, err = time.Parse("20060102", "19"+s)
+	}
+	if err != nil {
+		return Date{}
+	}
+	return Date{d}
+}
+
+// expiryDate reads a YYMMDD expiry date, which is always this century
+func expiryDate(s string) Date {
+	d, err := time.Parse("20060102", "20"+s)
+	if err != nil {
+		return Date{}
+	}
+	return Date{d}
+}
+
+// titleCase turns "ANNA MARIA" into "Anna Maria"
+func titleCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
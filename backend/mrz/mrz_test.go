@@ -0,0 +1,260 @@
+package mrz
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Specimens from ICAO 9303 parts 4 (TD3) and 5 (TD1)
+const (
+	specimenTD3 = "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\n" +
+		"L898902C36UTO7408122F1204159ZE184226B<<<<<10"
+	specimenTD1 = "I<UTOD231458907<<<<<<<<<<<<<<<\n" +
+		"7408122F1204159UTO<<<<<<<<<<<6\n" +
+		"ERIKSSON<<ANNA<MARIA<<<<<<<<<<"
+	// A 12 character document number continues in the optional data
+	specimenTD1LongNumber = "I<UTOD23145890<7349<<<<<<<<<<<\n" +
+		"3407127M9507122UTO<<<<<<<<<<<2\n" +
+		"STEVENSON<<PETER<JOHN<<<<<<<<<"
+)
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func date(year int, month time.Month, day int) Date {
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestParseTD3Specimen(t *testing.T) {
+	doc, err := Parse(specimenTD3, testNow)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Document{
+		Format:         TD3,
+		DocumentType:   "P",
+		IssuingCountry: "UTO",
+		Surname:        "ERIKSSON",
+		GivenNames:     "ANNA MARIA",
+		DocumentNumber: "L898902C3",
+		Nationality:    "UTO",
+		DateOfBirth:    date(1974, time.August, 12),
+		Sex:            "F",
+		ExpiryDate:     date(2012, time.April, 15),
+		OptionalData:   "ZE184226B",
+	}
+	if *doc != want {
+		t.Errorf("doc = %+v\nwant  %+v", *doc, want)
+	}
+	if got := doc.FullName(); got != "Anna Maria Eriksson" {
+		t.Errorf("FullName = %q", got)
+	}
+}
+
+func TestParseTD1Specimen(t *testing.T) {
+	doc, err := Parse(specimenTD1, testNow)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Document{
+		Format:         TD1,
+		DocumentType:   "I",
+		IssuingCountry: "UTO",
+		Surname:        "ERIKSSON",
+		GivenNames:     "ANNA MARIA",
+		DocumentNumber: "D23145890",
+		Nationality:    "UTO",
+		DateOfBirth:    date(1974, time.August, 12),
+		Sex:            "F",
+		ExpiryDate:     date(2012, time.April, 15),
+	}
+	if *doc != want {
+		t.Errorf("doc = %+v\nwant  %+v", *doc, want)
+	}
+}
+
+func TestParseTD1LongDocumentNumber(t *testing.T) {
+	doc, err := Parse(specimenTD1LongNumber, testNow)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if doc.DocumentNumber != "D23145890734" || doc.OptionalData != "" {
+		t.Errorf("document_number = %q, optional_data = %q", doc.DocumentNumber, doc.OptionalData)
+	}
+	if doc.FullName() != "Peter John Stevenson" {
+		t.Errorf("FullName = %q", doc.FullName())
+	}
+}
+
+func TestParseIgnoresScannerWhitespace(t *testing.T) {
+	text := "\r\n  " + strings.ToLower(strings.ReplaceAll(specimenTD1, "\n", "\r\n")) + "  \n\n"
+	if _, err := Parse(text, testNow); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+}
+
+// replaceAt replaces the character at index i of the given MRZ line
+func replaceAt(text string, line, i int, c byte) string {
+	lines := strings.Split(text, "\n")
+	b := []byte(lines[line])
+	b[i] = c
+	lines[line] = string(b)
+	return strings.Join(lines, "\n")
+}
+
+func TestParseReportsBadCheckDigits(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		field string
+	}{
+		{"TD3 document number", replaceAt(specimenTD3, 1, 9, '5'), "document_number"},
+		{"TD3 date of birth", replaceAt(specimenTD3, 1, 19, '3'), "date_of_birth"},
+		{"TD3 expiry date", replaceAt(specimenTD3, 1, 27, '8'), "expiry_date"},
+		{"TD3 personal number", replaceAt(specimenTD3, 1, 42, '2'), "optional_data"},
+		{"TD3 composite", replaceAt(specimenTD3, 1, 43, '1'), "composite"},
+		{"TD1 document number", replaceAt(specimenTD1, 0, 14, '8'), "document_number"},
+		{"TD1 long document number", replaceAt(specimenTD1LongNumber, 0, 18, '8'), "document_number"},
+		{"TD1 date of birth", replaceAt(specimenTD1, 1, 6, '3'), "date_of_birth"},
+		{"TD1 expiry date", replaceAt(specimenTD1, 1, 14, '8'), "expiry_date"},
+		{"TD1 composite", replaceAt(specimenTD1, 1, 29, '7'), "composite"},
+		// A misread character in the data rather than the check digit
+		{"TD3 misread number", replaceAt(specimenTD3, 1, 0, 'I'), "document_number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.text, testNow)
+			var checkErr *CheckDigitError
+			if !errors.As(err, &checkErr) {
+				t.Fatalf("err = %v, want a CheckDigitError", err)
+			}
+			found := false
+			for _, f := range checkErr.Fields {
+				found = found || f == tt.field
+			}
+			if !found {
+				t.Errorf("failed fields = %v, want %s among them", checkErr.Fields, tt.field)
+			}
+			// The partly read document is still returned
+			if doc == nil || doc.Surname == "" {
+				t.Errorf("doc = %+v, want the fields read", doc)
+			}
+		})
+	}
+}
+
+func TestParseAcceptsFillerPersonalNumberDigit(t *testing.T) {
+	line1 := "P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\n"
+	for _, line2 := range []string{
+		"L898902C36UTO7408122F1204159<<<<<<<<<<<<<<<8", // Filler check digit
+		"L898902C36UTO7408122F1204159<<<<<<<<<<<<<<08", // Zero check digit
+	} {
+		doc, err := Parse(line1+line2, testNow)
+		if err != nil {
+			t.Errorf("%s: %v", line2, err)
+			continue
+		}
+		if doc.OptionalData != "" {
+			t.Errorf("%s: optional_data = %q", line2, doc.OptionalData)
+		}
+	}
+}
+
+func TestParseBirthCentury(t *testing.T) {
+	// Born 2015; the 1974 and 1934 births are covered by the specimens below
+	text := "I<UTOD231458907<<<<<<<<<<<<<<<\n" +
+		"1503014M3003013UTO<<<<<<<<<<<4\n" +
+		"ERIKSSON<<ANNA<MARIA<<<<<<<<<<"
+	doc, err := Parse(text, testNow)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if doc.DateOfBirth != date(2015, time.March, 1) || doc.ExpiryDate != date(2030, time.March, 1) {
+		t.Errorf("born %v, expires %v", doc.DateOfBirth, doc.ExpiryDate)
+	}
+
+	doc, err = Parse(specimenTD1LongNumber, testNow)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if doc.DateOfBirth != date(1934, time.July, 12) {
+		t.Errorf("born %v, want 1934-07-12", doc.DateOfBirth)
+	}
+
+	tests := []struct {
+		yymmdd string
+		want   Date
+	}{
+		{"261019", date(2026, time.October, 19)}, // This year is still this century
+		{"271019", date(1927, time.October, 19)}, // Next year is last century
+		{"000101", date(2000, time.January, 1)},
+		{"991231", date(1999, time.December, 31)},
+		{"<<<<<<", Date{}},
+	}
+	for _, tt := range tests {
+		if got := birthDate(tt.yymmdd, testNow); got != tt.want {
+			t.Errorf("birthDate(%s) = %v, want %v", tt.yymmdd, got, tt.want)
+		}
+	}
+}
+
+func TestDocumentExpired(t *testing.T) {
+	doc := &Document{ExpiryDate: date(2026, time.October, 19)}
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC), false},
+		{time.Date(2026, 10, 19, 23, 59, 0, 0, time.UTC), false}, // Valid through the expiry day
+		{time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := doc.Expired(tt.now); got != tt.want {
+			t.Errorf("Expired(%v) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+
+	if (&Document{}).Expired(testNow) {
+		t.Error("a document without an expiry date is expired")
+	}
+	specimen, _ := Parse(specimenTD3, testNow)
+	if !specimen.Expired(testNow) {
+		t.Error("specimen expiring 2012-04-15 is not expired")
+	}
+}
+
+func TestParseRejectsOtherFormats(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"P<UTOERIKSSON<<ANNA<MARIA",
+		specimenTD1[:61], // Two lines of a TD1
+	} {
+		if _, err := Parse(text, testNow); !errors.Is(err, ErrFormat) {
+			t.Errorf("Parse(%q) err = %v, want ErrFormat", text, err)
+		}
+	}
+	if _, err := Parse(strings.Replace(specimenTD3, "<<ANNA", "<-ANNA", 1), testNow); err == nil {
+		t.Error("invalid character was accepted")
+	}
+}
+
+func TestValidCheckDigit(t *testing.T) {
+	tests := []struct {
+		data  string
+		digit byte
+		want  bool
+	}{
+		{"L898902C3", '6', true},
+		{"740812", '2', true},
+		{"120415", '9', true},
+		{"<<<<<<", '0', true},
+		{"L898902C3", '7', false},
+		{"L898902C3", '<', false},
+	}
+	for _, tt := range tests {
+		if got := validCheckDigit(tt.data, tt.digit); got != tt.want {
+			t.Errorf("validCheckDigit(%q, %c) = %v, want %v", tt.data, tt.digit, got, tt.want)
+		}
+	}
+}
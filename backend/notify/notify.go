@@ -0,0 +1,198 @@
+// Package notify tells hosts when their visitors arrive and leave. Messages
+// are queued and delivered in the background through pluggable notifiers.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Event is what happened to the visit
+type Event string
+
+const (
+	EventVisitorArrived  Event = "visitor.arrived"
+	EventVisitorDeparted Event = "visitor.departed"
+)
+
+// Message describes a visit event for the visit's host
+type Message struct {
+	Event          Event     `json:"event"`
+	Time           time.Time `json:"time"`
+	HostID         uint      `json:"host_id"`
+	HostName       string    `json:"host_name"`
+	HostEmail      string    `json:"host_email,omitempty"`
+	HostPhone      string    `json:"host_phone,omitempty"`
+	VisitID        uint      `json:"visit_id"`
+	VisitorName    string    `json:"visitor_name"`
+	VisitorCompany string    `json:"visitor_company,omitempty"`
+	AreaOfVisit    string    `json:"area_of_visit"`
+	Purpose        string    `json:"purpose"`
+	BadgeNumber    string    `json:"badge_number,omitempty"`
+	LocationID     uint      `json:"location_id"`
+	LocationName   string    `json:"location_name,omitempty"`
+	// Set when one message covers a group; VisitorName is then the lead's
+	GroupID   uint   `json:"group_id,omitempty"`
+	GroupName string `json:"group_name,omitempty"`
+	GroupSize int    `json:"group_size,omitempty"`
+}
+
+// visitors names who the message is about
+func (m Message) visitors() string {
+	switch {
+	case m.GroupSize == 2:
+		return m.VisitorName + " and 1 other"
+	case m.GroupSize > 2:
+		return fmt.Sprintf("%s and %d others", m.VisitorName, m.GroupSize-1)
+	}
+	return m.VisitorName
+}
+
+// Subject is a one-line summary of the message
+func (m Message) Subject() string {
+	group := m.GroupSize > 1
+	switch {
+	case m.Event == EventVisitorDeparted && group:
+		return fmt.Sprintf("%s have signed out", m.visitors())
+	case m.Event == EventVisitorDeparted:
+		return fmt.Sprintf("%s has signed out", m.VisitorName)
+	case group:
+		return fmt.Sprintf("Your visitors %s have arrived", m.visitors())
+	default:
+		return fmt.Sprintf("Your visitor %s has arrived", m.VisitorName)
+	}
+}
+
+// Text is the plain-text body of the message
+func (m Message) Text() string {
+	visitor := m.visitors()
+	if m.GroupName != "" {
+		visitor += " (" + m.GroupName + ")"
+	} else if m.VisitorCompany != "" {
+		visitor += " (" + m.VisitorCompany + ")"
+	}
+	at := m.Time.Format("15:04 on 2 Jan 2006")
+
+	if m.Event == EventVisitorDeparted {
+		return fmt.Sprintf("Hello %s,\n\n%s signed out at %s.\n", m.HostName, visitor, at)
+	}
+	text := fmt.Sprintf("Hello %s,\n\n%s signed in at %s", m.HostName, visitor, at)
+	if m.LocationName != "" {
+		text += " at " + m.LocationName
+	}
+	text += fmt.Sprintf(" to see you.\n\nArea: %s\nPurpose: %s\n", m.AreaOfVisit, m.Purpose)
+	if m.BadgeNumber != "" {
+		text += "Badge: " + m.BadgeNumber + "\n"
+	}
+	return text
+}
+
+// Notifier delivers a message through one channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg Message) error
+}
+
+const (
+	queueSize      = 256
+	maxAttempts    = 3
+	attemptTimeout = 10 * time.Second
+)
+
+// Dispatcher queues messages and delivers them to every notifier in the
+// background, retrying failed deliveries, so sign-in is never held up by a
+// slow mail server or webhook. Each notifier has its own queue and worker,
+// so one that is down does not delay the others.
+type Dispatcher struct {
+	notifiers []Notifier
+	queues    []chan Message // By notifier
+	retryWait time.Duration
+}
+
+// NewDispatcher starts a dispatcher for the given notifiers
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	return newDispatcher(2*time.Second, notifiers...)
+}
+
+func newDispatcher(retryWait time.Duration, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{
+		notifiers: notifiers,
+		queues:    make([]chan Message, len(notifiers)),
+		retryWait: retryWait,
+	}
+	for i := range notifiers {
+		d.queues[i] = make(chan Message, queueSize)
+		go d.run(i)
+	}
+	return d
+}
+
+// Dispatch queues a message for every notifier; a notifier whose queue is
+// full drops it with a log entry
+func (d *Dispatcher) Dispatch(msg Message) {
+	for i, queue := range d.queues {
+		select {
+		case queue <- msg:
+		default:
+			log.Printf("%s notification queue full, dropping %s for visit %d", d.notifiers[i].Name(), msg.Event, msg.VisitID)
+		}
+	}
+}
+
+// run delivers the messages queued for one notifier
+func (d *Dispatcher) run(i int) {
+	for msg := range d.queues[i] {
+		d.deliver(d.notifiers[i], msg)
+	}
+}
+
+func (d *Dispatcher) deliver(notifier Notifier, msg Message) {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), attemptTimeout)
+		err = notifier.Notify(ctx, msg)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt < maxAttempts {
+			time.Sleep(d.retryWait * time.Duration(attempt))
+		}
+	}
+	log.Printf("%s notification of %s for visit %d failed: %v", notifier.Name(), msg.Event, msg.VisitID, err)
+}
+
+// NotifiersFromEnv builds the notifiers configured in the environment.
+// It returns none when notifications are not configured.
+func NotifiersFromEnv() []Notifier {
+	var notifiers []Notifier
+	if addr := os.Getenv("NOTIFY_SMTP_ADDR"); addr != "" {
+		notifiers = append(notifiers, NewSMTPNotifier(SMTPConfig{
+			Addr:     addr,
+			From:     os.Getenv("NOTIFY_SMTP_FROM"),
+			Username: os.Getenv("NOTIFY_SMTP_USERNAME"),
+			Password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+		}))
+	}
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(url, os.Getenv("NOTIFY_WEBHOOK_SECRET")))
+	}
+	if os.Getenv("NOTIFY_LOG") == "true" {
+		notifiers = append(notifiers, LogNotifier{})
+	}
+	return notifiers
+}
+
+// LogNotifier writes messages to the server log, standing in for real
+// delivery during development
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string { return "log" }
+
+func (LogNotifier) Notify(ctx context.Context, msg Message) error {
+	log.Printf("Notify host %d (%s): %s", msg.HostID, msg.HostName, msg.Subject())
+	return nil
+}
@@ -0,0 +1,312 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testMessage() Message {
+	return Message{
+		Event:       EventVisitorArrived,
+		Time:        time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC),
+		HostID:      1,
+		HostName:    "Mary Wanjiku",
+		HostEmail:   "mary.wanjiku@example.com",
+		VisitID:     7,
+		VisitorName: "John Doe",
+		AreaOfVisit: "Terminal A",
+		Purpose:     "Meeting",
+		BadgeNumber: "B001",
+		LocationID:  1,
+	}
+}
+
+// sentMail is one call to a stubbed smtp.SendMail
+type sentMail struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+	msg  string
+}
+
+func stubSMTP(cfg SMTPConfig) (*SMTPNotifier, *[]sentMail) {
+	var sent []sentMail
+	n := NewSMTPNotifier(cfg)
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, sentMail{addr: addr, auth: a, from: from, to: to, msg: string(msg)})
+		return nil
+	}
+	return n, &sent
+}
+
+func TestSMTPNotifierComposesMessage(t *testing.T) {
+	n, sent := stubSMTP(SMTPConfig{Addr: "localhost:25"})
+	if err := n.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(*sent))
+	}
+	mail := (*sent)[0]
+	if mail.addr != "localhost:25" || mail.from != "logbook@localhost" || mail.auth != nil {
+		t.Errorf("envelope = %s %s auth=%v", mail.addr, mail.from, mail.auth)
+	}
+	if len(mail.to) != 1 || mail.to[0] != "mary.wanjiku@example.com" {
+		t.Errorf("to = %v", mail.to)
+	}
+
+	header, body, ok := strings.Cut(mail.msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator in %q", mail.msg)
+	}
+	for _, want := range []string{
+		"From: logbook@localhost",
+		"To: mary.wanjiku@example.com",
+		"Subject: Your visitor John Doe has arrived",
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(header+"\r\n", want+"\r\n") {
+			t.Errorf("header missing %q:\n%s", want, header)
+		}
+	}
+	if !strings.Contains(header, "\r\nDate: ") {
+		t.Errorf("header missing Date:\n%s", header)
+	}
+	if strings.Contains(strings.ReplaceAll(body, "\r\n", ""), "\n") {
+		t.Errorf("body has bare LF line endings: %q", body)
+	}
+	if !strings.Contains(body, "Badge: B001\r\n") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSMTPNotifierUsesAuthWhenConfigured(t *testing.T) {
+	n, sent := stubSMTP(SMTPConfig{Addr: "mail.example.com:587", From: "gate@example.com", Username: "gate", Password: "secret"})
+	if err := n.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(*sent) != 1 || (*sent)[0].auth == nil || (*sent)[0].from != "gate@example.com" {
+		t.Fatalf("sent = %+v, want one authenticated message from gate@example.com", *sent)
+	}
+}
+
+func TestSMTPNotifierRejectsHeaderInjection(t *testing.T) {
+	n, sent := stubSMTP(SMTPConfig{Addr: "localhost:25"})
+
+	msg := testMessage()
+	msg.HostEmail = "mary@example.com\r\nBcc: everyone@example.com"
+	if err := n.Notify(context.Background(), msg); err == nil {
+		t.Error("host email with CRLF was accepted")
+	}
+	if len(*sent) != 0 {
+		t.Errorf("sent %d messages for an invalid address", len(*sent))
+	}
+
+	// Line breaks in the visitor's name must not start new headers
+	msg = testMessage()
+	msg.VisitorName = "Eve\r\nBcc: everyone@example.com"
+	if err := n.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	header, _, _ := strings.Cut((*sent)[0].msg, "\r\n\r\n")
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("visitor name injected a header:\n%s", header)
+	}
+}
+
+func TestSMTPNotifierSkipsHostsWithoutEmail(t *testing.T) {
+	n, sent := stubSMTP(SMTPConfig{Addr: "localhost:25"})
+	msg := testMessage()
+	msg.HostEmail = ""
+	if err := n.Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(*sent) != 0 {
+		t.Errorf("sent %d messages, want none", len(*sent))
+	}
+}
+
+func TestWebhookNotifierSignsBody(t *testing.T) {
+	const secret = "webhook-secret"
+	var (
+		body      []byte
+		signature string
+		event     string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		event = r.Header.Get("X-Logbook-Event")
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	msg := testMessage()
+	if err := NewWebhookNotifier(server.URL, secret).Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var got Message
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if got.VisitID != msg.VisitID || got.HostEmail != msg.HostEmail || got.Event != msg.Event {
+		t.Errorf("body = %+v", got)
+	}
+	if event != string(EventVisitorArrived) {
+		t.Errorf("X-Logbook-Event = %q", event)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+	}
+}
+
+func TestWebhookNotifierReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Error("signed without a secret")
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(server.URL, "").Notify(context.Background(), testMessage()); err == nil {
+		t.Error("502 response was treated as delivered")
+	}
+}
+
+// flakyNotifier fails its first failures attempts
+type flakyNotifier struct {
+	name     string
+	failures int
+
+	mu        sync.Mutex
+	attempts  int
+	delivered chan Message
+}
+
+func newFlakyNotifier(name string, failures int) *flakyNotifier {
+	return &flakyNotifier{name: name, failures: failures, delivered: make(chan Message, 1)}
+}
+
+func (n *flakyNotifier) Name() string { return n.name }
+
+func (n *flakyNotifier) Notify(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attempts++
+	if n.attempts <= n.failures {
+		return errors.New("temporarily unavailable")
+	}
+	n.delivered <- msg
+	return nil
+}
+
+func (n *flakyNotifier) attemptCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.attempts
+}
+
+// newTestDispatcher is NewDispatcher without the wait between retries
+func newTestDispatcher(notifiers ...Notifier) *Dispatcher {
+	return newDispatcher(time.Millisecond, notifiers...)
+}
+
+// hungNotifier never answers until released, like a webhook that accepts
+// the connection and then stalls
+type hungNotifier struct {
+	release chan struct{}
+}
+
+func (hungNotifier) Name() string { return "hung" }
+
+func (n hungNotifier) Notify(ctx context.Context, msg Message) error {
+	<-n.release
+	return nil
+}
+
+func waitForDelivery(t *testing.T, n *flakyNotifier) Message {
+	t.Helper()
+	select {
+	case msg := <-n.delivered:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: no delivery after %d attempts", n.name, n.attemptCount())
+	}
+	return Message{}
+}
+
+func TestDispatcherRetriesFailedDeliveries(t *testing.T) {
+	flaky := newFlakyNotifier("flaky", maxAttempts-1)
+	d := newTestDispatcher(flaky)
+	d.Dispatch(testMessage())
+
+	if msg := waitForDelivery(t, flaky); msg.VisitID != 7 {
+		t.Errorf("delivered visit %d, want 7", msg.VisitID)
+	}
+	if got := flaky.attemptCount(); got != maxAttempts {
+		t.Errorf("attempts = %d, want %d", got, maxAttempts)
+	}
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	broken := newFlakyNotifier("broken", maxAttempts)
+	healthy := newFlakyNotifier("healthy", 0)
+	d := newTestDispatcher(broken, healthy)
+	d.Dispatch(testMessage())
+
+	// A notifier that keeps failing does not stop the others
+	waitForDelivery(t, healthy)
+	for deadline := time.Now().Add(5 * time.Second); broken.attemptCount() < maxAttempts && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // Leave time for an extra attempt
+	if got := broken.attemptCount(); got != maxAttempts {
+		t.Errorf("attempts = %d, want %d", got, maxAttempts)
+	}
+	select {
+	case <-broken.delivered:
+		t.Error("delivered after the last attempt")
+	default:
+	}
+}
+
+func TestDispatcherHungNotifierDoesNotDelayOthers(t *testing.T) {
+	hung := hungNotifier{release: make(chan struct{})}
+	defer close(hung.release)
+	healthy := newFlakyNotifier("healthy", 0)
+	d := newTestDispatcher(hung, healthy)
+
+	first, second := testMessage(), testMessage()
+	second.VisitID = 8
+	d.Dispatch(first)
+	d.Dispatch(second)
+
+	if msg := waitForDelivery(t, healthy); msg.VisitID != 7 {
+		t.Errorf("delivered visit %d, want 7", msg.VisitID)
+	}
+	if msg := waitForDelivery(t, healthy); msg.VisitID != 8 {
+		t.Errorf("delivered visit %d, want 8", msg.VisitID)
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds the mail server settings
+type SMTPConfig struct {
+	Addr     string // host:port
+	From     string
+	Username string // Optional; PLAIN auth is only used over TLS or to localhost
+	Password string
+}
+
+// SMTPNotifier emails the host. Hosts without an email address are skipped.
+type SMTPNotifier struct {
+	cfg SMTPConfig
+	// sendMail is smtp.SendMail; replaced to run against a local stand-in
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier returns a notifier that sends through the given server
+func NewSMTPNotifier(cfg SMTPConfig) *SMTPNotifier {
+	if cfg.From == "" {
+		cfg.From = "logbook@localhost"
+	}
+	return &SMTPNotifier{cfg: cfg, sendMail: smtp.SendMail}
+}
+
+func (n *SMTPNotifier) Name() string { return "smtp" }
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.HostEmail == "" {
+		return nil
+	}
+	if strings.ContainsAny(msg.HostEmail, "\r\n") {
+		return errors.New("invalid host email address")
+	}
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(n.cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %w", err)
+		}
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+
+	// smtp.SendMail takes no context, so give up waiting once it expires
+	done := make(chan error, 1)
+	go func() {
+		done <- n.sendMail(n.cfg.Addr, auth, n.cfg.From, []string{msg.HostEmail}, n.compose(msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// compose builds the RFC 5322 message
+func (n *SMTPNotifier) compose(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.HostEmail)
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(msg.Subject()))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text(), "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,480 @@
+// Package qr encodes short byte strings as QR codes (ISO/IEC 18004), in byte
+// mode at error correction level M, for versions 1 to 10. That holds up to
+// 213 bytes, plenty for the signed codes printed on visitor badges.
+package qr
+
+import "errors"
+
+// ErrTooLong is returned for data that does not fit in a version 10 code
+var ErrTooLong = errors.New("data too long for a QR code")
+
+// Code is an encoded QR symbol without its quiet zone
+type Code struct {
+	Size    int // Modules per side
+	modules [][]bool
+}
+
+// Black reports whether the module at column x, row y is dark
+func (c *Code) Black(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Error correction level M, indexed by version
+var (
+	eccPerBlock = [11]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+	numBlocks   = [11]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+)
+
+const maxVersion = 10
+
+// levelM is the two-bit format indicator of error correction level M
+const levelM = 0
+
+// Encode makes the smallest code that holds data
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		if 4+countBits(v)+8*len(data) <= dataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	e := newEncoder(version)
+	e.drawFunctionPatterns()
+	e.drawCodewords(e.interleave(e.dataCodewords(data)))
+
+	// Pick the mask that leaves the fewest confusing patterns
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		e.applyMask(mask)
+		e.drawFormatBits(mask)
+		if p := e.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		e.applyMask(mask) // Undo
+	}
+	e.applyMask(best)
+	e.drawFormatBits(best)
+
+	return &Code{Size: e.size, modules: e.modules}, nil
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// rawModules counts the modules available for data and error correction
+func rawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+func dataCodewords(version int) int {
+	return rawModules(version)/8 - eccPerBlock[version]*numBlocks[version]
+}
+
+type encoder struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newEncoder(version int) *encoder {
+	size := version*4 + 17
+	e := &encoder{version: version, size: size}
+	e.modules = make([][]bool, size)
+	e.isFunction = make([][]bool, size)
+	for i := range e.modules {
+		e.modules[i] = make([]bool, size)
+		e.isFunction[i] = make([]bool, size)
+	}
+	return e
+}
+
+func (e *encoder) setFunction(x, y int, dark bool) {
+	e.modules[y][x] = dark
+	e.isFunction[y][x] = true
+}
+
+func (e *encoder) drawFunctionPatterns() {
+	for i := 0; i < e.size; i++ {
+		e.setFunction(6, i, i%2 == 0)
+		e.setFunction(i, 6, i%2 == 0)
+	}
+
+	e.drawFinder(3, 3)
+	e.drawFinder(e.size-4, 3)
+	e.drawFinder(3, e.size-4)
+
+	pos := e.alignmentPositions()
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			// Skip the three corners taken by finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			e.drawAlignment(pos[i], pos[j])
+		}
+	}
+
+	e.drawFormatBits(0) // Reserve the area; redrawn once the mask is chosen
+	e.drawVersion()
+}
+
+func (e *encoder) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= e.size || y < 0 || y >= e.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			e.setFunction(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (e *encoder) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			e.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (e *encoder) alignmentPositions() []int {
+	if e.version == 1 {
+		return nil
+	}
+	n := e.version/7 + 2
+	step := (e.version*8 + n*3 + 5) / (n*4 - 4) * 2
+	pos := make([]int, n)
+	pos[0] = 6
+	for i, p := n-1, e.size-7; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// formatBits returns the 15 bit format information for level M and mask:
+// the level and mask protected by a BCH code, then masked
+func formatBits(mask int) int {
+	data := levelM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormatBits writes the format information twice
+func (e *encoder) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+
+	for i := 0; i <= 5; i++ {
+		e.setFunction(8, i, bit(bits, i))
+	}
+	e.setFunction(8, 7, bit(bits, 6))
+	e.setFunction(8, 8, bit(bits, 7))
+	e.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		e.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		e.setFunction(e.size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		e.setFunction(8, e.size-15+i, bit(bits, i))
+	}
+	e.setFunction(8, e.size-8, true) // Always dark
+}
+
+// versionBits returns the 18 bit version information: the version protected
+// by a BCH code
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	return version<<12 | rem
+}
+
+// drawVersion writes the version number for versions 7 and up
+func (e *encoder) drawVersion() {
+	if e.version < 7 {
+		return
+	}
+	bits := versionBits(e.version)
+	for i := 0; i < 18; i++ {
+		a, b := e.size-11+i%3, i/3
+		e.setFunction(a, b, bit(bits, i))
+		e.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// dataCodewords encodes data in byte mode, padded to the version's capacity
+func (e *encoder) dataCodewords(data []byte) []byte {
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), countBits(e.version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := dataCodewords(e.version) * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	out := make([]byte, len(bb)/8)
+	for i, b := range bb {
+		if b {
+			out[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return out
+}
+
+// interleave splits the data into blocks, adds error correction to each and
+// interleaves the result
+func (e *encoder) interleave(data []byte) []byte {
+	blocks := numBlocks[e.version]
+	eccLen := eccPerBlock[e.version]
+	raw := rawModules(e.version) / 8
+	shortBlocks := blocks - raw%blocks
+	shortLen := raw / blocks
+	divisor := rsDivisor(eccLen)
+
+	var all [][]byte
+	k := 0
+	for i := 0; i < blocks; i++ {
+		n := shortLen - eccLen
+		if i >= shortBlocks {
+			n++
+		}
+		block := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < shortBlocks {
+			block = append(block, 0) // Placeholder, skipped below
+		}
+		all = append(all, append(block, ecc...))
+	}
+
+	var out []byte
+	for i := range all[0] {
+		for j, block := range all {
+			if i != shortLen-eccLen || j >= shortBlocks {
+				out = append(out, block[i])
+			}
+		}
+	}
+	return out
+}
+
+// drawCodewords places the bits in the zigzag order of the standard
+func (e *encoder) drawCodewords(data []byte) {
+	i := 0
+	for right := e.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		for vert := 0; vert < e.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = e.size - 1 - vert
+				}
+				if !e.isFunction[y][x] && i < len(data)*8 {
+					e.modules[y][x] = bit(int(data[i>>3]), 7-i&7)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (e *encoder) applyMask(mask int) {
+	for y := 0; y < e.size; y++ {
+		for x := 0; x < e.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !e.isFunction[y][x] {
+				e.modules[y][x] = !e.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the four rules of the standard
+func (e *encoder) penalty() int {
+	n := e.size
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return e.modules[x][y]
+		}
+		return e.modules[y][x]
+	}
+
+	score := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < n; y++ {
+			// Runs of five or more modules of one colour
+			run := 1
+			for x := 1; x < n; x++ {
+				if at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+
+			// Patterns that look like finders
+			for x := 0; x+11 <= n; x++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if at(x+k, y, vertical) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of one colour
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if e.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := e.modules[y][x]
+				if c == e.modules[y][x+1] && c == e.modules[y+1][x] && c == e.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	// Imbalance between dark and light
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	if k > 0 {
+		score += k * 10
+	}
+	return score
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bb = append(*bb, value>>i&1 != 0)
+	}
+}
+
+// rsDivisor returns the generator polynomial for the given degree
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder computes the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func bit(x, i int) bool {
+	return x>>i&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
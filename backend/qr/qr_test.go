@@ -0,0 +1,263 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Format information for level M, masks 0 to 7 (ISO/IEC 18004 table C.1)
+var formatTable = [8]int{0x5412, 0x5125, 0x5E7C, 0x5B4B, 0x45F9, 0x40CE, 0x4F97, 0x4AA0}
+
+// Version information for versions 7 to 10 (ISO/IEC 18004 table D.1)
+var versionTable = map[int]int{7: 0x07C94, 8: 0x085BC, 9: 0x09A99, 10: 0x0A4D3}
+
+func TestFormatBits(t *testing.T) {
+	for mask, want := range formatTable {
+		if got := formatBits(mask); got != want {
+			t.Errorf("formatBits(%d) = %#x, want %#x", mask, got, want)
+		}
+	}
+}
+
+func TestVersionBits(t *testing.T) {
+	for version, want := range versionTable {
+		if got := versionBits(version); got != want {
+			t.Errorf("versionBits(%d) = %#x, want %#x", version, got, want)
+		}
+	}
+}
+
+func TestReedSolomon(t *testing.T) {
+	tests := []struct {
+		name      string
+		data, ecc []byte
+	}{
+		// ISO/IEC 18004 annex I: "01234567" as a 1-M symbol
+		{
+			"01234567",
+			[]byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11},
+			[]byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55},
+		},
+		// "HELLO WORLD" as a 1-M symbol
+		{
+			"HELLO WORLD",
+			[]byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			[]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+		},
+	}
+	for _, tt := range tests {
+		if got := rsRemainder(tt.data, rsDivisor(len(tt.ecc))); !bytes.Equal(got, tt.ecc) {
+			t.Errorf("%s: ecc = % x, want % x", tt.name, got, tt.ecc)
+		}
+	}
+}
+
+func TestDataCodewords(t *testing.T) {
+	// Byte mode indicator, a count of 2, "hi", the terminator, then padding
+	want := []byte{0x40, 0x26, 0x86, 0x90, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	if got := newEncoder(1).dataCodewords([]byte("hi")); !bytes.Equal(got, want) {
+		t.Errorf("dataCodewords = % x, want % x", got, want)
+	}
+}
+
+func TestEncodePicksSmallestVersion(t *testing.T) {
+	tests := []struct {
+		length, size int
+	}{
+		{14, 21}, // Capacity of version 1-M
+		{15, 25},
+		{213, 57}, // Capacity of version 10-M
+	}
+	for _, tt := range tests {
+		code, err := Encode(bytes.Repeat([]byte{'a'}, tt.length))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", tt.length, err)
+		}
+		if code.Size != tt.size {
+			t.Errorf("Encode(%d bytes) size = %d, want %d", tt.length, code.Size, tt.size)
+		}
+	}
+	if _, err := Encode(make([]byte, 214)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("err = %v, want ErrTooLong", err)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("hi"),
+		[]byte("LB1.2.1792401526.SKUHhyFEWjJE82XbvhqpcA"),
+	}
+	// Every length exercises each version and block layout
+	for n := 1; n <= 213; n++ {
+		inputs = append(inputs, []byte(strings.Repeat("0123456789abcdef", 14)[:n]))
+	}
+	for _, data := range inputs {
+		code, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Encode(%q): %v", data, err)
+		}
+		if got := decode(t, code); !bytes.Equal(got, data) {
+			t.Fatalf("decoded %q, want %q", got, data)
+		}
+	}
+}
+
+// maskFuncs are the data masks of the standard, by mask number
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// decode reads a code back as a scanner would: the format and version
+// information, the unmasked codewords, the blocks, whose error correction
+// must check out, and finally the byte mode segment
+func decode(t *testing.T, code *Code) []byte {
+	t.Helper()
+	size := code.Size
+	version := (size - 17) / 4
+
+	// Both copies of the format information name the same mask
+	var first, second int
+	read := func(bits *int, i, x, y int) {
+		if code.Black(x, y) {
+			*bits |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		read(&first, i, 8, i)
+	}
+	read(&first, 6, 8, 7)
+	read(&first, 7, 8, 8)
+	read(&first, 8, 7, 8)
+	for i := 9; i < 15; i++ {
+		read(&first, i, 14-i, 8)
+	}
+	for i := 0; i < 8; i++ {
+		read(&second, i, size-1-i, 8)
+	}
+	for i := 8; i < 15; i++ {
+		read(&second, i, 8, size-15+i)
+	}
+	if first != second {
+		t.Fatalf("format copies differ: %#x and %#x", first, second)
+	}
+	mask := -1
+	for m, bits := range formatTable {
+		if bits == first {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format %#x is not level M", first)
+	}
+	if !code.Black(8, size-8) {
+		t.Fatal("dark module is light")
+	}
+
+	if version >= 7 {
+		var bits int
+		for i := 0; i < 18; i++ {
+			read(&bits, i, size-11+i%3, i/3)
+		}
+		if bits != versionTable[version] {
+			t.Fatalf("version information %#x, want %#x", bits, versionTable[version])
+		}
+	}
+
+	// Read the data modules in zigzag order, removing the mask
+	e := newEncoder(version)
+	e.drawFunctionPatterns()
+	raw := make([]byte, rawModules(version)/8)
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if e.isFunction[y][x] || i >= len(raw)*8 {
+					continue
+				}
+				if code.Black(x, y) != maskFuncs[mask](x, y) {
+					raw[i>>3] |= 1 << (7 - i&7)
+				}
+				i++
+			}
+		}
+	}
+
+	// Deinterleave; the last blocks may hold one more data codeword
+	blocks, eccLen := numBlocks[version], eccPerBlock[version]
+	shortBlocks := blocks - len(raw)%blocks
+	shortData := len(raw)/blocks - eccLen
+	data := make([][]byte, blocks)
+	ecc := make([][]byte, blocks)
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range data {
+			if i < shortData || j >= shortBlocks {
+				data[j] = append(data[j], raw[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := range ecc {
+			ecc[j] = append(ecc[j], raw[k])
+			k++
+		}
+	}
+
+	// A valid block evaluates to zero at each root of the generator
+	var codewords []byte
+	for j := range data {
+		block := append(append([]byte{}, data[j]...), ecc[j]...)
+		root := byte(1)
+		for r := 0; r < eccLen; r++ {
+			var syndrome byte
+			for _, b := range block {
+				syndrome = gfMultiply(syndrome, root) ^ b
+			}
+			if syndrome != 0 {
+				t.Fatalf("block %d: syndrome %d is %#x", j, r, syndrome)
+			}
+			root = gfMultiply(root, 0x02)
+		}
+		codewords = append(codewords, data[j]...)
+	}
+
+	// Byte mode segment
+	pos := 0
+	next := func(n int) int {
+		v := 0
+		for ; n > 0; n-- {
+			v = v<<1 | int(codewords[pos>>3]>>(7-pos&7)&1)
+			pos++
+		}
+		return v
+	}
+	if m := next(4); m != 0x4 {
+		t.Fatalf("mode %#x, want byte mode", m)
+	}
+	countLen := 8
+	if version >= 10 {
+		countLen = 16
+	}
+	out := make([]byte, next(countLen))
+	for i := range out {
+		out[i] = byte(next(8))
+	}
+	return out
+}
@@ -15,6 +15,10 @@ func SetupRoutes(router *gin.Engine) {
 	{
 		// Authentication
 		api.POST("/auth/login", handlers.Login)
+
+		// OpenID Connect single sign-on
+		api.GET("/auth/oidc/login", handlers.OIDCLogin)
+		api.GET("/auth/oidc/callback", handlers.OIDCCallback)
 	}
 
 	// Protected routes (require authentication)
@@ -23,13 +27,23 @@ func SetupRoutes(router *gin.Engine) {
 	{
 		// Get current user info
 		protected.GET("/auth/me", handlers.GetCurrentUser)
+		protected.POST("/auth/logout", handlers.Logout)
+
+		// Current user's sessions
+		protected.GET("/auth/sessions", handlers.ListMySessions)
+		protected.DELETE("/auth/sessions/:id", handlers.RevokeMySession)
 
 		// Visitor routes
 		visitors := protected.Group("/visitors")
 		{
 			// All authenticated users can view visitors
 			visitors.GET("", handlers.ListVisitors)
+			visitors.GET("/lookup", handlers.LookupVisitor)
+
+			// Operators who sign visitors in can read their documents' MRZ
+			visitors.POST("/mrz", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin), handlers.ParseVisitorMRZ)
 			visitors.GET("/:id", handlers.GetVisitor)
+			visitors.GET("/:id/visits", handlers.ListVisitorVisits)
 
 			// Data entry operators and admins can create visitors
 			visitors.POST("", middleware.RequireDataEntry(), handlers.CreateVisitor)
@@ -37,12 +51,179 @@ func SetupRoutes(router *gin.Engine) {
 			// Dashboard operators and admins can sign in/out visitors
 			visitors.POST("/:id/signin", middleware.RequireVisitorDashboard(), handlers.SignInVisitor)
 			visitors.POST("/:id/signout", middleware.RequireVisitorDashboard(), handlers.SignOutVisitor)
+			visitors.POST("/signout-by-badge", middleware.RequireVisitorDashboard(), handlers.SignOutVisitorByBadge)
+			visitors.POST("/signout-by-qr", middleware.RequireVisitorDashboard(), handlers.SignOutVisitorByQR)
 
 			// Only admins can update and delete visitors
 			visitors.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateVisitor)
 			visitors.DELETE("/:id", middleware.RequireAdmin(), handlers.DeleteVisitor)
 		}
 
+		// Visit history and reports
+		visits := protected.Group("/visits")
+		{
+			// All authenticated users can view visits
+			visits.GET("", handlers.ListVisits)
+			visits.GET("/report", handlers.GetVisitReport)
+			visits.GET("/:id", handlers.GetVisit)
+			visits.GET("/:id/history", handlers.GetVisitStatusHistory)
+
+			// Dashboard operators and admins can sign out a specific visit
+			visits.POST("/:id/signout", middleware.RequireVisitorDashboard(), handlers.SignOutVisit)
+
+			// Only admins can correct and delete visits
+			visits.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateVisit)
+			visits.DELETE("/:id", middleware.RequireAdmin(), handlers.DeleteVisit)
+
+			// Visitor operators can print temporary badges
+			visits.GET("/:id/badge", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin), handlers.PrintVisitBadge)
+
+			// Visitor photos and ID document scans, for visitor operators
+			images := visits.Group("/:id/images", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin))
+			images.GET("", handlers.ListVisitImages)
+			images.POST("", handlers.UploadVisitImage)
+			images.GET("/:imageId", handlers.GetVisitImage)
+			images.DELETE("/:imageId", middleware.RequireAdmin(), handlers.DeleteVisitImage)
+
+			// Who escorted the visitor, and handovers between escorts
+			visits.GET("/:id/escorts", handlers.ListVisitEscorts)
+			visits.POST("/:id/escort", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin), handlers.HandOverEscort)
+
+			// Visitor operators confirm or turn away visitors who checked in at a kiosk
+			visits.POST("/:id/confirm", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin), handlers.ConfirmKioskVisit)
+			visits.POST("/:id/deny", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin), handlers.DenyVisit)
+		}
+
+		// Escort assignments across visits
+		protected.GET("/escorts", handlers.ListEscortAssignments)
+
+		// Equipment declared by visitors, and the items that did not leave with them
+		items := protected.Group("/items")
+		{
+			items.GET("", handlers.ListDeclaredItems)
+			items.GET("/outstanding", handlers.ListOutstandingItems)
+		}
+
+		// Host directory
+		hosts := protected.Group("/hosts")
+		{
+			// All authenticated users can look up hosts
+			hosts.GET("", handlers.ListHosts)
+			hosts.GET("/:id", handlers.GetHost)
+
+			// Only admins maintain the directory
+			hosts.POST("", middleware.RequireAdmin(), handlers.CreateHost)
+			hosts.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateHost)
+			hosts.DELETE("/:id", middleware.RequireAdmin(), handlers.DeleteHost)
+		}
+
+		// Visitors registered in advance by their hosts
+		preregistrations := protected.Group("/preregistrations")
+		preregistrations.Use(middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardVisitor, models.RoleAdmin))
+		{
+			preregistrations.GET("", handlers.ListPreregistrations)
+			preregistrations.POST("", handlers.CreatePreregistration)
+			preregistrations.POST("/:id/arrive", handlers.ArrivePreregistration)
+			preregistrations.POST("/:id/cancel", handlers.CancelPreregistration)
+			preregistrations.POST("/:id/deny", handlers.DenyPreregistration)
+
+			// Only admins approve visits that require it
+			preregistrations.POST("/:id/approve", middleware.RequireAdmin(), handlers.ApprovePreregistration)
+			preregistrations.POST("/:id/reject", middleware.RequireAdmin(), handlers.RejectPreregistration)
+		}
+
+		// Safety inductions and NDAs visitors accept before signing in
+		ackDocuments := protected.Group("/ack-documents")
+		{
+			// All authenticated users can read the documents to show visitors
+			ackDocuments.GET("", handlers.ListAckDocuments)
+			ackDocuments.GET("/required", handlers.RequiredAcknowledgements)
+			ackDocuments.GET("/:id", handlers.GetAckDocument)
+			ackDocuments.GET("/:id/versions", handlers.ListAckDocumentVersions)
+
+			// Only admins publish documents
+			ackDocuments.POST("", middleware.RequireAdmin(), handlers.CreateAckDocument)
+			ackDocuments.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateAckDocument)
+		}
+
+		// Acknowledgement records for audits (admin only)
+		acknowledgements := protected.Group("/acknowledgements")
+		acknowledgements.Use(middleware.RequireRole(models.RoleAdmin))
+		{
+			acknowledgements.GET("", handlers.ListAcknowledgements)
+			acknowledgements.GET("/:id", handlers.GetAcknowledgement)
+			acknowledgements.GET("/:id/signature", handlers.GetAcknowledgementSignature)
+		}
+
+		// Group visits: delegations and crews signed in and out together
+		groups := protected.Group("/groups")
+		{
+			groups.GET("", handlers.ListVisitGroups)
+			groups.GET("/:id", handlers.GetVisitGroup)
+			groups.POST("", middleware.RequireDataEntry(), handlers.CreateVisitGroup)
+			groups.POST("/:id/signout", middleware.RequireVisitorDashboard(), handlers.SignOutVisitGroup)
+		}
+
+		// Badge inventory
+		badges := protected.Group("/badges")
+		{
+			// All authenticated users can view badges
+			badges.GET("", handlers.ListBadges)
+			badges.GET("/outstanding", handlers.ListOutstandingBadges)
+			badges.GET("/:id", handlers.GetBadge)
+
+			// Dashboard operators and admins track lost badges
+			badges.POST("/:id/lost", middleware.RequireVisitorDashboard(), handlers.MarkBadgeLost)
+			badges.POST("/:id/recovered", middleware.RequireVisitorDashboard(), handlers.MarkBadgeRecovered)
+
+			// Only admins manage the inventory
+			badges.POST("", middleware.RequireAdmin(), handlers.CreateBadge)
+			badges.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateBadge)
+			badges.DELETE("/:id", middleware.RequireAdmin(), handlers.DeleteBadge)
+		}
+
+		// Areas visitors are signed in to, with their access rules
+		areas := protected.Group("/areas")
+		{
+			// All authenticated users can view the areas and their occupancy
+			areas.GET("", handlers.ListAreas)
+			areas.GET("/occupancy", handlers.GetAreaOccupancy)
+			areas.GET("/:id", handlers.GetArea)
+
+			// Only admins manage the areas
+			areas.POST("", middleware.RequireAdmin(), handlers.CreateArea)
+			areas.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateArea)
+			areas.DELETE("/:id", middleware.RequireAdmin(), handlers.DeleteArea)
+		}
+
+		// Visitor parking bays, assigned at sign-in and freed at sign-out
+		parking := protected.Group("/parking-bays")
+		{
+			// All authenticated users can view the bays and their occupancy
+			parking.GET("", handlers.ListParkingBays)
+			parking.GET("/occupancy", handlers.GetParkingOccupancy)
+			parking.GET("/:id", handlers.GetParkingBay)
+
+			// Only admins manage the inventory
+			parking.POST("", middleware.RequireAdmin(), handlers.CreateParkingBay)
+			parking.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateParkingBay)
+			parking.DELETE("/:id", middleware.RequireAdmin(), handlers.DeleteParkingBay)
+		}
+
+		// Overdue-visit and unreturned-badge alerts raised by the background jobs
+		alerts := protected.Group("/alerts")
+		{
+			alerts.GET("", handlers.ListAlerts)
+			alerts.POST("/:id/acknowledge", middleware.RequireVisitorDashboard(), handlers.AcknowledgeAlert)
+		}
+
+		// End-of-day sweep reports
+		sweeps := protected.Group("/sweeps")
+		{
+			sweeps.GET("", handlers.ListSweepReports)
+			sweeps.GET("/:id", handlers.GetSweepReport)
+		}
+
 		// Cargo routes
 		cargo := protected.Group("/cargo")
 		{
@@ -52,6 +233,7 @@ func SetupRoutes(router *gin.Engine) {
 
 			// Data entry operators and admins can create cargo
 			cargo.POST("", middleware.RequireDataEntry(), handlers.CreateCargo)
+			cargo.POST("/:id/release", middleware.RequireRole(models.RoleDataEntry, models.RoleDashboardCargo, models.RoleAdmin), handlers.ReleaseCargo)
 
 			// Only admins can update and delete cargo
 			cargo.PUT("/:id", middleware.RequireAdmin(), handlers.UpdateCargo)
@@ -85,6 +267,42 @@ func SetupRoutes(router *gin.Engine) {
 			users.POST("", handlers.CreateUser)
 			users.PUT("/:id", handlers.UpdateUser)
 			users.DELETE("/:id", handlers.DeleteUser)
+			users.DELETE("/:id/sessions", handlers.RevokeUserSessions)
+		}
+
+		// Session oversight across all users (admin only)
+		sessions := protected.Group("/sessions")
+		sessions.Use(middleware.RequireRole(models.RoleAdmin))
+		{
+			sessions.GET("", handlers.ListSessions)
+			sessions.DELETE("/:id", handlers.RevokeSession)
+		}
+
+		// Watchlist screening entries (admin only)
+		watchlist := protected.Group("/watchlist")
+		watchlist.Use(middleware.RequireRole(models.RoleAdmin))
+		{
+			watchlist.GET("", handlers.ListWatchlist)
+			watchlist.GET("/:id", handlers.GetWatchlistEntry)
+			watchlist.POST("", handlers.CreateWatchlistEntry)
+			watchlist.PUT("/:id", handlers.UpdateWatchlistEntry)
+			watchlist.DELETE("/:id", handlers.DeleteWatchlistEntry)
+		}
+
+		// Audit log (admin only)
+		protected.GET("/audit", middleware.RequireRole(models.RoleAdmin), handlers.ListAuditLogs)
+
+		// Muster roll and evacuation drills, for any user at the location
+		muster := protected.Group("/locations/:id")
+		{
+			muster.GET("/muster", handlers.GetMusterRoll)
+			muster.GET("/muster/export", handlers.ExportMusterRoll)
+			muster.POST("/muster/accounted", middleware.RequireVisitorDashboard(), handlers.MarkAccounted)
+			muster.GET("/drills", handlers.ListDrills)
+
+			// Dashboard operators and admins run drills
+			muster.POST("/drill", middleware.RequireVisitorDashboard(), handlers.StartDrill)
+			muster.POST("/drill/end", middleware.RequireVisitorDashboard(), handlers.EndDrill)
 		}
 
 		// Location management routes (admin only)
@@ -96,6 +314,56 @@ func SetupRoutes(router *gin.Engine) {
 			locations.POST("", handlers.CreateLocation)
 			locations.PUT("/:id", handlers.UpdateLocation)
 			locations.DELETE("/:id", handlers.DeleteLocation)
+			locations.POST("/:id/sweep", handlers.SweepLocation)
 		}
+
+		// Service account and API key management routes (admin only)
+		serviceAccounts := protected.Group("/service-accounts")
+		serviceAccounts.Use(middleware.RequireRole(models.RoleAdmin))
+		{
+			serviceAccounts.GET("", handlers.ListServiceAccounts)
+			serviceAccounts.GET("/:id", handlers.GetServiceAccount)
+			serviceAccounts.POST("", handlers.CreateServiceAccount)
+			serviceAccounts.PUT("/:id", handlers.UpdateServiceAccount)
+			serviceAccounts.DELETE("/:id", handlers.DeleteServiceAccount)
+
+			serviceAccounts.GET("/:id/keys", handlers.ListAPIKeys)
+			serviceAccounts.POST("/:id/keys", handlers.CreateAPIKey)
+			serviceAccounts.DELETE("/:id/keys/:keyId", handlers.RevokeAPIKey)
+		}
+	}
+
+	// Self-service kiosks at reception (API key with kiosk, bound to one location)
+	kiosk := api.Group("/kiosk")
+	kiosk.Use(middleware.KioskAuth())
+	{
+		kiosk.GET("/directory", handlers.GetKioskDirectory)
+		kiosk.POST("/visits", handlers.SubmitKioskVisit)
+		kiosk.GET("/visits/:id", handlers.GetKioskVisit)
+		kiosk.POST("/visits/:id/acknowledgements", handlers.AcknowledgeKioskVisit)
+		kiosk.GET("/preregistrations", handlers.LookupKioskPreregistrations)
+		kiosk.POST("/preregistrations/:id/checkin", handlers.CheckInKioskPreregistration)
+		kiosk.POST("/signout", handlers.KioskSignOut)
+	}
+
+	// SCIM 2.0 provisioning for identity providers (API key with scim:provision)
+	scim := router.Group("/scim/v2")
+	scim.Use(middleware.SCIMAuth())
+	{
+		scim.GET("/ServiceProviderConfig", handlers.SCIMServiceProviderConfig)
+
+		scim.GET("/Users", handlers.SCIMListUsers)
+		scim.GET("/Users/:id", handlers.SCIMGetUser)
+		scim.POST("/Users", handlers.SCIMCreateUser)
+		scim.PUT("/Users/:id", handlers.SCIMReplaceUser)
+		scim.PATCH("/Users/:id", handlers.SCIMPatchUser)
+		scim.DELETE("/Users/:id", handlers.SCIMDeleteUser)
+
+		scim.GET("/Groups", handlers.SCIMListGroups)
+		scim.GET("/Groups/:id", handlers.SCIMGetGroup)
+		scim.POST("/Groups", handlers.SCIMGroupsReadOnly)
+		scim.PUT("/Groups/:id", handlers.SCIMReplaceGroup)
+		scim.PATCH("/Groups/:id", handlers.SCIMPatchGroup)
+		scim.DELETE("/Groups/:id", handlers.SCIMGroupsReadOnly)
 	}
 }
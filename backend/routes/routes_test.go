@@ -0,0 +1,157 @@
+package routes
+
+import (
+	"digital-logbook/middleware"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes outside AuthMiddleware: public, or authenticated by their own middleware
+var unprotectedRoutes = map[string]bool{
+	"POST /api/auth/login":        true,
+	"GET /api/auth/oidc/login":    true,
+	"GET /api/auth/oidc/callback": true,
+}
+
+var unprotectedPrefixes = []string{
+	"/api/kiosk/", // KioskAuth
+	"/scim/v2/",   // SCIMAuth
+}
+
+// apiKeyExcluded lists the protected routes that deliberately reject API keys.
+// A new protected route must be added to the middleware's API key permissions
+// or here.
+var apiKeyExcluded = map[string]bool{
+	// A person's own sign-in
+	"GET /api/auth/me":              true,
+	"POST /api/auth/logout":         true,
+	"GET /api/auth/sessions":        true,
+	"DELETE /api/auth/sessions/:id": true,
+
+	// Corrections to the visit record
+	"PUT /api/visitors/:id":    true,
+	"DELETE /api/visitors/:id": true,
+	"PUT /api/visits/:id":      true,
+	"DELETE /api/visits/:id":   true,
+
+	// Photos and ID document scans
+	"GET /api/visits/:id/images":             true,
+	"POST /api/visits/:id/images":            true,
+	"GET /api/visits/:id/images/:imageId":    true,
+	"DELETE /api/visits/:id/images/:imageId": true,
+
+	// Decisions an operator on site is accountable for
+	"POST /api/visits/:id/escort":              true,
+	"POST /api/preregistrations/:id/approve":   true,
+	"POST /api/preregistrations/:id/reject":    true,
+	"POST /api/preregistrations/:id/cancel":    true,
+	"POST /api/badges/:id/lost":                true,
+	"POST /api/badges/:id/recovered":           true,
+	"POST /api/alerts/:id/acknowledge":         true,
+	"POST /api/locations/:id/muster/accounted": true,
+	"POST /api/locations/:id/drill":            true,
+	"POST /api/locations/:id/drill/end":        true,
+
+	// Site setup
+	"POST /api/ack-documents":            true,
+	"PUT /api/ack-documents/:id":         true,
+	"POST /api/areas":                    true,
+	"PUT /api/areas/:id":                 true,
+	"DELETE /api/areas/:id":              true,
+	"POST /api/parking-bays":             true,
+	"PUT /api/parking-bays/:id":          true,
+	"DELETE /api/parking-bays/:id":       true,
+	"POST /api/hosts":                    true,
+	"PUT /api/hosts/:id":                 true,
+	"DELETE /api/hosts/:id":              true,
+	"POST /api/badges":                   true,
+	"PUT /api/badges/:id":                true,
+	"DELETE /api/badges/:id":             true,
+	"PUT /api/cargo/:id":                 true,
+	"DELETE /api/cargo/:id":              true,
+	"POST /api/fitness/members":          true,
+	"PUT /api/fitness/members/:id":       true,
+	"DELETE /api/fitness/members/:id":    true,
+	"DELETE /api/fitness/attendance/:id": true,
+
+	// Administration
+	"GET /api/acknowledgements":                    true,
+	"GET /api/acknowledgements/:id":                true,
+	"GET /api/acknowledgements/:id/signature":      true,
+	"GET /api/audit":                               true,
+	"GET /api/users":                               true,
+	"GET /api/users/:id":                           true,
+	"POST /api/users":                              true,
+	"PUT /api/users/:id":                           true,
+	"DELETE /api/users/:id":                        true,
+	"DELETE /api/users/:id/sessions":               true,
+	"GET /api/sessions":                            true,
+	"DELETE /api/sessions/:id":                     true,
+	"GET /api/watchlist":                           true,
+	"GET /api/watchlist/:id":                       true,
+	"POST /api/watchlist":                          true,
+	"PUT /api/watchlist/:id":                       true,
+	"DELETE /api/watchlist/:id":                    true,
+	"GET /api/locations":                           true,
+	"GET /api/locations/:id":                       true,
+	"POST /api/locations":                          true,
+	"PUT /api/locations/:id":                       true,
+	"DELETE /api/locations/:id":                    true,
+	"POST /api/locations/:id/sweep":                true,
+	"GET /api/service-accounts":                    true,
+	"GET /api/service-accounts/:id":                true,
+	"POST /api/service-accounts":                   true,
+	"PUT /api/service-accounts/:id":                true,
+	"DELETE /api/service-accounts/:id":             true,
+	"GET /api/service-accounts/:id/keys":           true,
+	"POST /api/service-accounts/:id/keys":          true,
+	"DELETE /api/service-accounts/:id/keys/:keyId": true,
+}
+
+func protected(route gin.RouteInfo) bool {
+	if unprotectedRoutes[route.Method+" "+route.Path] {
+		return false
+	}
+	for _, prefix := range unprotectedPrefixes {
+		if strings.HasPrefix(route.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProtectedRoutesDecideOnAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupRoutes(router)
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		if !protected(route) {
+			continue
+		}
+
+		_, mapped := middleware.APIKeyRoutePermission(route.Method, route.Path)
+		switch {
+		case mapped && apiKeyExcluded[key]:
+			t.Errorf("%s has an API key permission but is listed as excluded", key)
+		case !mapped && !apiKeyExcluded[key]:
+			t.Errorf("%s needs an API key permission or to be listed as excluded", key)
+		}
+	}
+
+	for key := range apiKeyExcluded {
+		if !registered[key] {
+			t.Errorf("excluded route %s is not registered", key)
+		}
+	}
+	for key := range unprotectedRoutes {
+		if !registered[key] {
+			t.Errorf("unprotected route %s is not registered", key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package storage keeps uploaded files such as visitor photos and ID scans
+// out of the database, behind a pluggable blob store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"regexp"
+)
+
+// ErrNotFound is returned when no blob is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that could escape the store
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore saves and serves opaque blobs by key. Keys are slash-separated
+// paths made of letters, digits, dots, dashes and underscores.
+type BlobStore interface {
+	Name() string
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*(/[A-Za-z0-9_-][A-Za-z0-9._-]*)*$`)
+
+// ValidKey reports whether key is safe to use with any store
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// DefaultDir is where LocalStore keeps blobs when BLOB_STORE_DIR is unset
+const DefaultDir = "data/blobs"
+
+// FromEnv returns the blob store configured in the environment. Only the
+// local filesystem is built in; BLOB_STORE_DIR sets its directory.
+func FromEnv() (BlobStore, error) {
+	dir := os.Getenv("BLOB_STORE_DIR")
+	if dir == "" {
+		dir = DefaultDir
+	}
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Blob store: local directory %s", store.Root)
+	return store, nil
+}
@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { visitorService } from '@/services/visitor.service';
+import { visitService } from '@/services/visit.service';
 import { formatTimestamp } from '@/utils/dateFormatter';
 import { cargoService } from '@/services/cargo.service';
 import { locationService } from '@/services/location.service';
@@ -26,11 +26,9 @@ const Dashboard = () => {
                 let totalCargo = 0;
 
                 if (hasAnyRole(['dashboard_visitor', 'data_entry', 'admin'])) {
-                    const visitors = await visitorService.getAll({ location_id: location.id });
-                    activeVisitors = visitors.filter(v => v.status === 'signed_in').length;
-                    visitorsToday = visitors.filter(v => 
-                        v.sign_in_time && v.sign_in_time.startsWith(today)
-                    ).length;
+                    const report = await visitService.getReport({ location_id: location.id, from: today });
+                    activeVisitors = (await visitService.getAll({ location_id: location.id, status: 'signed_in' })).length;
+                    visitorsToday = report.total_visits;
                 }
 
                 if (hasAnyRole(['dashboard_cargo', 'data_entry', 'admin'])) {
@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { visitorService } from '@/services/visitor.service';
+import { visitService } from '@/services/visit.service';
 import { cargoService } from '@/services/cargo.service';
 import { locationService } from '@/services/location.service';
 import { useAuth } from '@/contexts/AuthContext';
@@ -52,12 +52,14 @@ const Reports = () => {
             }
 
             const params = locationFilter ? { location_id: locationFilter } : {};
-            const allVisitors = await visitorService.getAll(params);
+            const allVisits = await visitService.getAll({ ...params, from: startDate.toISOString() });
             const allCargo = await cargoService.getAll(params);
 
-            const filteredVisitors = allVisitors.filter(v => 
-                new Date(v.sign_in_time) >= startDate
-            );
+            const filteredVisitors = allVisits.map(v => ({
+                ...v,
+                name: v.visitor?.name || '',
+                id_number: v.visitor?.id_number || ''
+            }));
             const filteredCargo = allCargo.filter(c => 
                 new Date(c.time_in || c.created_at) >= startDate
             );
@@ -68,7 +70,7 @@ const Reports = () => {
             setStats({
                 totalVisitors: filteredVisitors.length,
                 activeVisitors: filteredVisitors.filter(v => v.status === 'signed_in').length,
-                signedOutVisitors: filteredVisitors.filter(v => v.status === 'signed_out').length,
+                signedOutVisitors: filteredVisitors.filter(v => v.status === 'signed_out' || v.status === 'auto_signed_out').length,
                 totalCargo: filteredCargo.length,
                 knownCargo: filteredCargo.filter(c => c.category === 'known').length,
                 unknownCargo: filteredCargo.filter(c => c.category === 'unknown').length
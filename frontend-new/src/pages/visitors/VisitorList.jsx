@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import { useNavigate } from 'react-router-dom';
 import { visitorService } from '@/services/visitor.service';
+import { visitService } from '@/services/visit.service';
 import { locationService } from '@/services/location.service';
 import { useAuth } from '@/contexts/AuthContext';
 import { InputModal, ConfirmModal } from '@/components/ui/modal';
@@ -24,6 +25,8 @@ const VisitorList = () => {
     const [deleteModal, setDeleteModal] = useState({ open: false, id: null });
     const [bulkDeleteModal, setBulkDeleteModal] = useState(false);
     const [badgeNumber, setBadgeNumber] = useState('');
+    const [badgeSignOutModal, setBadgeSignOutModal] = useState(false);
+    const [returnedBadge, setReturnedBadge] = useState('');
     const [showColumnMenu, setShowColumnMenu] = useState(false);
     const [visibleColumns, setVisibleColumns] = useState({
         name: true,
@@ -62,8 +65,13 @@ const VisitorList = () => {
         try {
             const params = filter !== 'all' ? { status: filter } : {};
             if (locationFilter) params.location_id = locationFilter;
-            const data = await visitorService.getAll(params);
-            setVisitors(data);
+            // Each row is one visit, flattened with the visitor's profile
+            const data = await visitService.getAll(params);
+            setVisitors(data.map(v => ({
+                ...v,
+                name: v.visitor?.name || '',
+                id_number: v.visitor?.id_number || ''
+            })));
         } catch (error) {
             console.error('Error fetching visitors:', error);
         } finally {
@@ -83,7 +91,7 @@ const VisitorList = () => {
     const handleSignIn = async () => {
         if (!badgeNumber) return;
         try {
-            await visitorService.signIn(signInModal.id, badgeNumber);
+            await visitorService.signIn(signInModal.id, { badge_number: badgeNumber });
             setSignInModal({ open: false, id: null });
             setBadgeNumber('');
             fetchVisitors();
@@ -94,7 +102,7 @@ const VisitorList = () => {
 
     const handleSignOut = async () => {
         try {
-            await visitorService.signOut(signOutModal.id);
+            await visitService.signOut(signOutModal.id);
             setSignOutModal({ open: false, id: null });
             fetchVisitors();
         } catch (error) {
@@ -102,9 +110,22 @@ const VisitorList = () => {
         }
     };
 
+    const handleSignOutByBadge = async () => {
+        if (!returnedBadge) return;
+        try {
+            const visitor = await visitorService.signOutByBadge(returnedBadge, locationFilter ? Number(locationFilter) : undefined);
+            setBadgeSignOutModal(false);
+            setReturnedBadge('');
+            alert(`${visitor.name} signed out`);
+            fetchVisitors();
+        } catch (error) {
+            alert(error.response?.data?.error || 'Failed to sign out visitor');
+        }
+    };
+
     const handleDelete = async () => {
         try {
-            await visitorService.delete(deleteModal.id);
+            await visitService.delete(deleteModal.id);
             setDeleteModal({ open: false, id: null });
             fetchVisitors();
         } catch (error) {
@@ -137,7 +158,7 @@ const VisitorList = () => {
 
     const handleBulkDelete = async () => {
         try {
-            await Promise.all(selectedIds.map(id => visitorService.delete(id)));
+            await Promise.all(selectedIds.map(id => visitService.delete(id)));
             setBulkDeleteModal(false);
             setSelectedIds([]);
             fetchVisitors();
@@ -201,6 +222,10 @@ const VisitorList = () => {
                             )}
                         </div>
                     )}
+                    <button className="btn-outline-shadow" onClick={() => setBadgeSignOutModal(true)}>
+                        <LogOut className="h-4 w-4" />
+                        Sign Out by Badge
+                    </button>
                     <button className="cta-button" onClick={() => navigate('/visitors/new')}>
                         <UserPlus className="h-4 w-4" />
                         New Visitor
@@ -340,7 +365,7 @@ const VisitorList = () => {
                                     <td className="table-cell">
                                         <div className="actions-group">
                                             {visitor.status === 'pending' && (
-                                                <button className="action-btn" onClick={() => setSignInModal({ open: true, id: visitor.id })}>
+                                                <button className="action-btn" onClick={() => setSignInModal({ open: true, id: visitor.visitor_id })}>
                                                     <LogIn className="h-4 w-4" />
                                                 </button>
                                             )}
@@ -377,6 +402,18 @@ const VisitorList = () => {
                 submitText="Sign In"
             />
 
+            <InputModal
+                isOpen={badgeSignOutModal}
+                onClose={() => { setBadgeSignOutModal(false); setReturnedBadge(''); }}
+                onSubmit={handleSignOutByBadge}
+                title="Sign Out by Badge"
+                label="Returned Badge Number"
+                placeholder="Scan or enter badge number"
+                value={returnedBadge}
+                onChange={setReturnedBadge}
+                submitText="Sign Out"
+            />
+
             <ConfirmModal
                 isOpen={signOutModal.open}
                 onClose={() => setSignOutModal({ open: false, id: null })}
@@ -12,6 +12,7 @@ const VisitorSignIn = () => {
     const [filteredVisitors, setFilteredVisitors] = useState([]);
     const dropdownRef = useRef(null);
     const [formData, setFormData] = useState({
+        visitor_id: null,
         first_name: '',
         middle_name: '',
         last_name: '',
@@ -44,7 +45,8 @@ const VisitorSignIn = () => {
     }, []);
 
     const handleIdChange = (value) => {
-        setFormData({ ...formData, id_number: value });
+        // Editing the ID number unlinks any returning visitor that was selected
+        setFormData({ ...formData, id_number: value, visitor_id: null });
         if (value.length > 0) {
             const matches = allVisitors.filter(v => v.id_number?.startsWith(value));
             setFilteredVisitors(matches);
@@ -54,18 +56,29 @@ const VisitorSignIn = () => {
         }
     };
 
-    const handleSelectVisitor = (visitor) => {
-        setFormData({
-            first_name: visitor.name?.split(' ')[0] || '',
-            middle_name: visitor.name?.split(' ')[1] || '',
-            last_name: visitor.name?.split(' ').slice(2).join(' ') || '',
-            id_number: visitor.id_number || '',
-            badge_number: '',
-            area_of_visit: visitor.area_of_visit || '',
-            purpose: visitor.purpose || ''
-        });
+    const handleSelectVisitor = async (visitor) => {
         setShowDropdown(false);
-        showToast('Data loaded from previous visit', 'success');
+        try {
+            const { visitor: profile, last_visit: lastVisit, visit_count: visitCount, flags } = await visitorService.lookup(visitor.id_number);
+            setFormData({
+                visitor_id: profile.id,
+                first_name: profile.name?.split(' ')[0] || '',
+                middle_name: profile.name?.split(' ')[1] || '',
+                last_name: profile.name?.split(' ').slice(2).join(' ') || '',
+                id_number: profile.id_number || '',
+                company_from: profile.company_from || '',
+                badge_number: '',
+                area_of_visit: lastVisit?.area_of_visit || '',
+                purpose: lastVisit?.purpose || ''
+            });
+            if (flags.includes('on_site')) {
+                showToast('This visitor is already signed in', 'error');
+            } else {
+                showToast(`Data loaded from previous visit (${visitCount} visits)`, 'success');
+            }
+        } catch (error) {
+            showToast('Failed to load previous visit', 'error');
+        }
     };
 
     const handleSubmit = async (e) => {
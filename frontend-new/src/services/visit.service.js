@@ -0,0 +1,35 @@
+import api from './api';
+
+export const visitService = {
+    getAll: async (filters = {}) => {
+        const params = new URLSearchParams(filters);
+        const response = await api.get(`/visits?${params}`);
+        return response.data;
+    },
+
+    getById: async (id) => {
+        const response = await api.get(`/visits/${id}`);
+        return response.data;
+    },
+
+    getReport: async (filters = {}) => {
+        const params = new URLSearchParams(filters);
+        const response = await api.get(`/visits/report?${params}`);
+        return response.data;
+    },
+
+    signOut: async (id) => {
+        const response = await api.post(`/visits/${id}/signout`);
+        return response.data;
+    },
+
+    update: async (id, visitData) => {
+        const response = await api.put(`/visits/${id}`, visitData);
+        return response.data;
+    },
+
+    delete: async (id) => {
+        const response = await api.delete(`/visits/${id}`);
+        return response.data;
+    }
+};
@@ -12,13 +12,23 @@ export const visitorService = {
         return response.data;
     },
 
+    lookup: async (idNumber) => {
+        const response = await api.get(`/visitors/lookup?${new URLSearchParams({ id_number: idNumber })}`);
+        return response.data;
+    },
+
     getById: async (id) => {
         const response = await api.get(`/visitors/${id}`);
         return response.data;
     },
 
-    signIn: async (id, badgeNumber) => {
-        const response = await api.post(`/visitors/${id}/signin`, { badge_number: badgeNumber });
+    getVisits: async (id) => {
+        const response = await api.get(`/visitors/${id}/visits`);
+        return response.data;
+    },
+
+    signIn: async (id, visitData) => {
+        const response = await api.post(`/visitors/${id}/signin`, visitData);
         return response.data;
     },
 
@@ -27,6 +37,11 @@ export const visitorService = {
         return response.data;
     },
 
+    signOutByBadge: async (badgeNumber, locationId) => {
+        const response = await api.post('/visitors/signout-by-badge', { badge_number: badgeNumber, location_id: locationId });
+        return response.data;
+    },
+
     update: async (id, visitorData) => {
         const response = await api.put(`/visitors/${id}`, visitorData);
         return response.data;